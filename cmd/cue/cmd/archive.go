@@ -0,0 +1,104 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/internal/archive"
+	"cuelang.org/go/internal/filetypes"
+)
+
+// An archiveArgs holds the command-line arguments that refer to the contents
+// of a single archive or, if filename is empty, the arguments that do not
+// refer to an archive.
+type archiveArgs struct {
+	filename string
+	args     []string
+}
+
+// splitArchiveArgs groups the arguments of the form archive[:arg] by archive,
+// and groups the other arguments together. The groups are ordered by the
+// position of their first argument. If there are no arguments, there is a
+// single group for the current directory.
+func splitArchiveArgs(args []string) (groups []*archiveArgs) {
+	index := map[string]*archiveArgs{}
+	for _, arg := range args {
+		filename, sub, ok := filetypes.SplitArchive(arg)
+		if !ok {
+			filename, sub = "", arg
+		}
+		a := index[filename]
+		if a == nil {
+			a = &archiveArgs{filename: filename}
+			index[filename] = a
+			groups = append(groups, a)
+		}
+		a.args = append(a.args, sub)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, &archiveArgs{})
+	}
+	return groups
+}
+
+// load loads the instances for the arguments of a group. The contents of an
+// archive are mounted as an overlay at the location of the archive file
+// itself, which then acts as the module root and the directory relative to
+// which the arguments are interpreted.
+func (a *archiveArgs) load(cmd *Command, cfg *load.Config) []*build.Instance {
+	if a.filename == "" {
+		return load.Instances(a.args, cfg)
+	}
+	files, err := archive.ReadFile(a.filename)
+	exitOnErr(cmd, err, true)
+
+	root, err := filepath.Abs(a.filename)
+	exitOnErr(cmd, err, true)
+
+	c := *cfg
+	c.Dir = root
+	c.ModuleRoot = root
+	c.Overlay = map[string]load.Source{}
+	for filename, src := range cfg.Overlay {
+		c.Overlay[filename] = src
+	}
+	for _, f := range files {
+		filename := filepath.Join(root, filepath.FromSlash(f.Name))
+		c.Overlay[filename] = load.FromBytes(f.Data)
+	}
+	return load.Instances(a.args, &c)
+}
+
+// containingArchive returns the name of the archive file in which the given
+// file is located, or "" if it is not located in an archive.
+func containingArchive(filename string) string {
+	dir := filepath.Dir(filename)
+	for {
+		if filetypes.ArchiveFormat(dir) != "" {
+			if fi, err := os.Stat(dir); err == nil && fi.Mode().IsRegular() {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // TODO: generate long description from documentation.
@@ -130,6 +131,23 @@ the second to standard output (i.e. it echos it again).
 		}
 	}
 
+The --dryrun flag prints the tasks of a command without running
+them. The tasks are listed in the order in which they could run,
+each with its kind, the tasks it depends on, and its inputs. Inputs
+that are not yet known, such as those depending on the output of
+other tasks, are printed as their type or expression. With
+--format dot or --format mermaid, the tasks and their
+dependencies are printed as a graph instead.
+
+	$ cue cmd --dryrun prompter
+	ask: tool/cli.Ask
+	  prompt: "What is your name?"
+	  response: string
+
+	echo: tool/exec.Run
+	  after: ask
+	  ...
+
 Run "cue help commands" for more details on tasks and commands.
 `,
 		RunE: mkRunE(c, func(cmd *Command, args []string) error {
@@ -151,6 +169,16 @@ Ensure commands are defined in a "_tool.cue" file.
 	cmd.Flags().SetInterspersed(false)
 	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
 		"set the value of a tagged field")
+	cmd.Flags().BoolP(string(flagDryrun), "n", false,
+		"print the tasks of the command in dependency order without running them")
+	cmd.Flags().String(string(flagFormat), "text",
+		`output format of --dryrun: "text", "dot" or "mermaid"`)
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "dry-run" {
+			name = string(flagDryrun)
+		}
+		return pflag.NormalizedName(name)
+	})
 
 	return cmd
 }
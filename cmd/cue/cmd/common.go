@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -28,6 +29,7 @@ import (
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
 	"cuelang.org/go/cue/load"
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
@@ -83,7 +85,32 @@ func exitOnErr(cmd *Command, err error, fatal bool) {
 	if err == nil {
 		return
 	}
+	switch flagErrorsFormat.String(cmd) {
+	case "json":
+		w := &bytes.Buffer{}
+		_ = errors.PrintJSON(w, err, errorsConfig())
+		_, _ = cmd.Stderr().Write(w.Bytes())
+	case "sarif":
+		// Reported all at once by Command.Run.
+		cmd.errs = append(cmd.errs, err)
+		cmd.hasErr = true
+	default:
+		printError(cmd.Stderr(), err)
+	}
+	if fatal {
+		exit()
+	}
+}
 
+// printError prints the details of err to w in a single write.
+func printError(out io.Writer, err error) {
+	w := &bytes.Buffer{}
+	errors.Print(w, err, errorsConfig())
+
+	_, _ = out.Write(w.Bytes())
+}
+
+func errorsConfig() *errors.Config {
 	// Link x/text as our localizer.
 	p := message.NewPrinter(getLang())
 	format := func(w io.Writer, format string, args ...interface{}) {
@@ -92,22 +119,21 @@ func exitOnErr(cmd *Command, err error, fatal bool) {
 
 	cwd, _ := os.Getwd()
 
-	w := &bytes.Buffer{}
-	errors.Print(w, err, &errors.Config{
+	return &errors.Config{
 		Format:  format,
 		Cwd:     cwd,
 		ToSlash: inTest,
-	})
-
-	b := w.Bytes()
-	_, _ = cmd.Stderr().Write(b)
-	if fatal {
-		exit()
 	}
 }
 
 func loadFromArgs(cmd *Command, args []string, cfg *load.Config) []*build.Instance {
-	binst := load.Instances(args, cfg)
+	if s := cmd.stats; s != nil {
+		defer s.addTime(&s.load, time.Now())
+	}
+	var binst []*build.Instance
+	for _, a := range splitArchiveArgs(args) {
+		binst = append(binst, a.load(cmd, cfg)...)
+	}
 	if len(binst) == 0 {
 		return nil
 	}
@@ -115,6 +141,12 @@ func loadFromArgs(cmd *Command, args []string, cfg *load.Config) []*build.Instan
 	return binst
 }
 
+// importable reports whether b can be imported by its import path, which is
+// not the case for packages outside a module or in a module without a name.
+func importable(b *build.Instance) bool {
+	return b.ImportPath != "" && !strings.HasPrefix(b.ImportPath, ":")
+}
+
 // A buildPlan defines what should be done based on command line
 // arguments and flags.
 //
@@ -136,8 +168,9 @@ type buildPlan struct {
 	// flags.
 	imported []*ast.File
 
-	expressions []ast.Expr // only evaluate these expressions within results
-	schema      ast.Expr   // selects schema in instance for orphaned values
+	expressions []expression // only evaluate these expressions within results
+	hasQuery    bool         // some expressions may select multiple values
+	schema      ast.Expr     // selects schema in instance for orphaned values
 
 	// outFile defines the file to output to. Default is CUE stdout.
 	outFile *build.File
@@ -158,6 +191,7 @@ func (b *buildPlan) instances() iterator {
 	}
 	if len(b.expressions) > 0 {
 		return &expressionIter{
+			cmd:  b.cmd,
 			iter: i,
 			expr: b.expressions,
 			i:    len(b.expressions),
@@ -311,10 +345,54 @@ func (i *streamingIterator) err() error {
 	return i.e
 }
 
+// An expression selects values with the --expression flag. It is either a
+// CUE expression or a path with wildcards or filters, which may select any
+// number of values.
+type expression struct {
+	src   string
+	expr  ast.Expr
+	query cue.Path
+}
+
+func parseExpression(s string) (expression, error) {
+	expr, err := parser.ParseExpr("--expression", s)
+	if err != nil {
+		// Paths with wildcards and filters are not valid expressions.
+		p := cue.ParsePath(s)
+		if p.Err() == nil {
+			return expression{src: p.String(), query: p}, nil
+		}
+		if strings.Contains(s, "*") || strings.Contains(s, "[?") {
+			// Report why the query is invalid rather than the expression.
+			return expression{}, p.Err()
+		}
+		return expression{}, err
+	}
+	b, _ := format.Node(expr)
+	return expression{src: string(b), expr: expr}, nil
+}
+
+func (e expression) values(v cue.Value) ([]cue.Value, error) {
+	if e.expr != nil {
+		return []cue.Value{internal.EvalExpr(v, e.expr).(cue.Value)}, nil
+	}
+	if v.Err() != nil {
+		return []cue.Value{v}, nil
+	}
+	a, err := v.Query(e.query)
+	if err == nil && len(a) == 0 {
+		err = errors.Newf(token.NoPos, "no values matched %s", e.src)
+	}
+	return a, err
+}
+
 type expressionIter struct {
+	cmd  *Command
 	iter iterator
-	expr []ast.Expr
-	i    int
+	expr []expression
+	i    int         // index of the current expression
+	a    []cue.Value // values selected by the current expression
+	j    int         // index of the current value in a
 }
 
 func (i *expressionIter) err() error { return i.iter.err() }
@@ -322,24 +400,35 @@ func (i *expressionIter) close()     { i.iter.close() }
 func (i *expressionIter) id() string { return i.iter.id() }
 
 func (i *expressionIter) scan() bool {
-	i.i++
-	if i.i < len(i.expr) {
-		return true
-	}
-	if !i.iter.scan() {
-		return false
+	for {
+		i.j++
+		if i.j < len(i.a) {
+			return true
+		}
+		i.i++
+		if i.i >= len(i.expr) {
+			if !i.iter.scan() {
+				return false
+			}
+			i.i = 0
+		}
+		a, err := i.expr[i.i].values(i.iter.value())
+		exitOnErr(i.cmd, err, true)
+		i.a, i.j = a, -1
 	}
-	i.i = 0
-	return true
 }
 
 func (i *expressionIter) file() *ast.File { return nil }
 
-func (i *expressionIter) value() cue.Value {
-	if len(i.expr) == 0 {
-		return i.iter.value()
+func (i *expressionIter) value() cue.Value { return i.a[i.j] }
+
+// label describes the current value: the expression that selected it or,
+// for queries, its path.
+func (i *expressionIter) label() string {
+	if e := i.expr[i.i]; e.expr != nil {
+		return e.src
 	}
-	return internal.EvalExpr(i.iter.value(), i.expr[i.i]).(cue.Value)
+	return i.a[i.j].Path().String()
 }
 
 type config struct {
@@ -369,6 +458,7 @@ func newBuildPlan(cmd *Command, args []string, cfg *config) (p *buildPlan, err e
 	}
 
 	cfg.loadCfg.Tags = flagInject.StringArray(cmd)
+	cfg.loadCfg.Vendor = flagVendor.Bool(cmd)
 
 	return p, nil
 }
@@ -380,6 +470,7 @@ func parseArgs(cmd *Command, args []string, cfg *config) (p *buildPlan, err erro
 	}
 
 	builds := loadFromArgs(cmd, args, cfg.loadCfg)
+	cmd.loaded = append(cmd.loaded, builds...)
 	if builds == nil {
 		return nil, errors.Newf(token.NoPos, "invalid args")
 	}
@@ -475,7 +566,7 @@ func parseArgs(cmd *Command, args []string, cfg *config) (p *buildPlan, err erro
 			"cannot use --schema/-d flag more than one schema")
 	}
 
-	if len(p.expressions) > 1 {
+	if len(p.expressions) > 1 || p.hasQuery {
 		p.encConfig.Stream = true
 	}
 	return p, nil
@@ -502,12 +593,13 @@ func (b *buildPlan) parseFlags() (err error) {
 		return err
 	}
 
-	for _, e := range flagExpression.StringArray(b.cmd) {
-		expr, err := parser.ParseExpr("--expression", e)
+	for _, s := range flagExpression.StringArray(b.cmd) {
+		e, err := parseExpression(s)
 		if err != nil {
 			return err
 		}
-		b.expressions = append(b.expressions, expr)
+		b.hasQuery = b.hasQuery || e.expr == nil
+		b.expressions = append(b.expressions, e)
 	}
 	if s := flagSchema.String(b.cmd); s != "" {
 		b.schema, err = parser.ParseExpr("--schema", s)
@@ -527,6 +619,7 @@ func (b *buildPlan) parseFlags() (err error) {
 		AllErrors: flagAllErrors.Bool(b.cmd),
 		PkgName:   flagPackage.String(b.cmd),
 		Strict:    flagStrict.Bool(b.cmd),
+		Force:     b.cmd.overwrite,
 	}
 	return nil
 }
@@ -535,13 +628,18 @@ func buildInstances(cmd *Command, binst []*build.Instance) []*cue.Instance {
 	// TODO:
 	// If there are no files and User is true, then use those?
 	// Always use all files in user mode?
-	instances := cue.Build(binst)
+	instances := compileInstances(cmd, binst)
 	for _, inst := range instances {
 		// TODO: consider merging errors of multiple files, but ensure
 		// duplicates are removed.
 		exitIfErr(cmd, inst, inst.Err, true)
 	}
 
+	if s := cmd.stats; s != nil {
+		defer s.addTime(&s.evaluate, time.Now())
+		s.profile(binst, instances)
+	}
+
 	if flagIgnore.Bool(cmd) {
 		return instances
 	}
@@ -555,6 +653,16 @@ func buildInstances(cmd *Command, binst []*build.Instance) []*cue.Instance {
 	return instances
 }
 
+// compileInstances builds the given instances without evaluating them. Unlike
+// buildInstances, it leaves it to the caller to report the errors of each
+// instance.
+func compileInstances(cmd *Command, binst []*build.Instance) []*cue.Instance {
+	if s := cmd.stats; s != nil {
+		defer s.addTime(&s.compile, time.Now())
+	}
+	return cue.Build(binst)
+}
+
 func buildToolInstances(cmd *Command, binst []*build.Instance) ([]*cue.Instance, error) {
 	instances := cue.Build(binst)
 	for _, inst := range instances {
@@ -575,8 +683,9 @@ func buildToolInstances(cmd *Command, binst []*build.Instance) ([]*cue.Instance,
 func buildTools(cmd *Command, tags, args []string) (*cue.Instance, error) {
 
 	cfg := &load.Config{
-		Tags:  tags,
-		Tools: true,
+		Tags:   tags,
+		Tools:  true,
+		Vendor: flagVendor.Bool(cmd),
 	}
 	binst := loadFromArgs(cmd, args, cfg)
 	if len(binst) == 0 {
@@ -0,0 +1,157 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+const compatDoc = `compat checks the compatibility of two versions of a schema
+
+Each of the two arguments, the old and the new version, is a package,
+a CUE file, or an archive (see 'cue help inputs'), and must evaluate
+to a single value. The --expression flag selects the value to compare
+within each of them, typically a definition.
+
+The new version is backward compatible if it accepts all values
+accepted by the old version, and forward compatible if the old version
+accepts all values accepted by the new version. The compatibility in
+each direction is printed to standard output.
+
+The --require flag selects the kinds of compatibility that must hold:
+
+  backward  the new version must be backward compatible (default)
+  forward   the new version must be forward compatible
+  full      the new version must be both
+  none      only report the compatibility
+
+Each path at which a required kind of compatibility breaks is reported
+as an error and results in a non-zero exit status. The breaks of other
+kinds are listed below the compatibility they break.
+
+Compatibility is checked by subsumption, taking closedness into account
+but ignoring default values. Disjunctions are compared disjunct by
+disjunct and pattern constraints are compared pattern by pattern.
+Breaks that could not be verified exactly are marked as possible.
+
+Examples:
+
+  # Check that a new release of a schema does not break its users.
+  $ cue compat -e '#Config' schema-1.0.zip ./schema
+`
+
+func newCompatCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compat <old> <new>",
+		Short: "check the compatibility of two versions of a schema",
+		Long:  compatDoc,
+		RunE:  mkRunE(c, runCompat),
+	}
+
+	cmd.Flags().StringArrayP(string(flagExpression), "e", nil,
+		"compare this expression only")
+	cmd.Flags().String(string(flagRequire), "backward",
+		`kind of compatibility required: "backward", "forward", "full" or "none"`)
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runCompat(cmd *Command, args []string) error {
+	if len(args) != 2 {
+		return errors.Newf(token.NoPos, "compat requires exactly two arguments")
+	}
+
+	var backward, forward bool
+	switch require := flagRequire.String(cmd); require {
+	case "backward":
+		backward = true
+	case "forward":
+		forward = true
+	case "full":
+		backward, forward = true, true
+	case "none":
+	default:
+		return errors.Newf(token.NoPos,
+			`invalid --require %q: must be "backward", "forward", "full" or "none"`,
+			require)
+	}
+
+	_, prev := loadDiffValue(cmd, args[0])
+	_, next := loadDiffValue(cmd, args[1])
+
+	c := cue.CheckCompatibility(prev, next)
+
+	w := cmd.OutOrStdout()
+	printCompat(cmd, w, "backward", c.Backward, backward)
+	printCompat(cmd, w, "forward", c.Forward, forward)
+	return nil
+}
+
+// printCompat prints the compatibility of the given kind and reports its
+// breaks as errors if it is required or lists them otherwise.
+func printCompat(cmd *Command, w io.Writer, kind string, breaks []cue.Break, required bool) {
+	if len(breaks) == 0 {
+		fmt.Fprintf(w, "%s compatible: yes\n", kind)
+		return
+	}
+	fmt.Fprintf(w, "%s compatible: no\n", kind)
+
+	var errs errors.Error
+	for _, b := range breaks {
+		err := &compatError{b.Err, "not " + kind + " compatible"}
+		if b.Inexact {
+			err.prefix = "possibly " + err.prefix
+		}
+		if required {
+			errs = errors.Append(errs, err)
+			continue
+		}
+		format, args := err.Msg()
+		msg := fmt.Sprintf(format, args...)
+		if path := strings.Join(err.Path(), "."); path != "" {
+			msg = path + ": " + msg
+		}
+		fmt.Fprintf(w, "    %s\n", msg)
+	}
+	exitOnErr(cmd, errs, false)
+}
+
+// A compatError prefixes the message of a break with the kind of
+// compatibility it breaks.
+type compatError struct {
+	err    errors.Error
+	prefix string
+}
+
+func (e *compatError) Position() token.Pos         { return e.err.Position() }
+func (e *compatError) InputPositions() []token.Pos { return e.err.InputPositions() }
+func (e *compatError) Path() []string              { return e.err.Path() }
+func (e *compatError) Error() string               { return errors.String(e) }
+
+func (e *compatError) Msg() (format string, args []interface{}) {
+	format, args = e.err.Msg()
+	return e.prefix + ": " + format, args
+}
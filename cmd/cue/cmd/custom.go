@@ -29,6 +29,7 @@ import (
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal"
 	itask "cuelang.org/go/internal/task"
 	_ "cuelang.org/go/pkg/tool/cli" // Register tasks
@@ -113,6 +114,18 @@ func doTasks(cmd *Command, typ, command string, root *cue.Instance) error {
 
 	c := flow.New(cfg, root, newTaskFunc(cmd))
 
+	// The flags of the cmd command are parsed before the custom command is
+	// added.
+	flags := cmd.cmd.Flags()
+	if dryrun, _ := flags.GetBool(string(flagDryrun)); dryrun {
+		exitIfErr(cmd, root, c.Err(), true)
+		format, _ := flags.GetString(string(flagFormat))
+		return printPlan(cmd.OutOrStdout(), c, cfg.Root, format)
+	}
+	if flags.Changed(string(flagFormat)) {
+		return errors.Newf(token.NoPos, "--format requires --dryrun")
+	}
+
 	err := c.Run(context.Background())
 	exitIfErr(cmd, root, err, true)
 
@@ -0,0 +1,167 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/diff"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+)
+
+const diffDoc = `diff reports the differences between two configurations
+
+Each of the two arguments is a package, a CUE or data file, or an
+archive (see 'cue help inputs'), and must evaluate to a single
+value. The --expression flag selects the value to compare within
+each of them.
+
+The values are compared semantically: the order of fields and the
+formatting of the sources are ignored. By default, the differences
+are printed in a human-readable form that marks removed fields with
+a - and added fields with a +. With the --concrete flag, defaults
+are resolved and only differences between concrete values are
+reported.
+
+The --patch flag prints a patch that transforms the first value into
+the second instead:
+
+  json    a JSON Patch (RFC 6902)
+  merge   a JSON merge patch (RFC 7386)
+
+Patches only consider regular fields of concrete values. By default
+they are printed as JSON; use --out to select another format.
+
+Like diff(1), the exit status is 0 if the values are equal and 1 if
+they differ.
+
+Examples:
+
+  # Review the changes between two releases of a configuration.
+  $ cue diff release-1.zip release-2.zip
+
+  # Compute a JSON Patch between two data files.
+  $ cue diff --patch json old.json new.yaml
+`
+
+func newDiffCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "report the differences between two configurations",
+		Long:  diffDoc,
+		RunE:  mkRunE(c, runDiff),
+	}
+
+	addOutFlags(cmd.Flags(), true)
+
+	cmd.Flags().StringArrayP(string(flagExpression), "e", nil,
+		"compare this expression only")
+	cmd.Flags().BoolP(string(flagConcrete), "c", false,
+		"resolve defaults and compare concrete values only")
+	cmd.Flags().String(string(flagPatch), "",
+		`print a patch of the given kind ("json" or "merge")`)
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runDiff(cmd *Command, args []string) error {
+	if len(args) != 2 {
+		return errors.Newf(token.NoPos, "diff requires exactly two arguments")
+	}
+
+	patch := flagPatch.String(cmd)
+	switch patch {
+	case "", "json", "merge":
+	default:
+		return errors.Newf(token.NoPos,
+			`invalid patch kind %q: must be "json" or "merge"`, patch)
+	}
+
+	b, x := loadDiffValue(cmd, args[0])
+	_, y := loadDiffValue(cmd, args[1])
+
+	profile := diff.Schema
+	if patch != "" || flagConcrete.Bool(cmd) {
+		profile = diff.Final
+	}
+	kind, es := profile.Diff(x, y)
+
+	var expr ast.Expr
+	switch patch {
+	case "":
+		w := cmd.OutOrStdout()
+		switch {
+		case kind == diff.Identity:
+		case es == nil:
+			fmt.Fprintf(w, "- %-v\n+ %-v\n", x, y)
+		default:
+			exitOnErr(cmd, diff.Print(w, es), true)
+		}
+
+	case "json":
+		expr = profile.JSONPatch(x, y)
+
+	case "merge":
+		expr = profile.MergePatch(x, y)
+	}
+
+	if expr != nil {
+		var r cue.Runtime
+		inst, err := r.CompileExpr(expr)
+		exitOnErr(cmd, err, true)
+
+		enc, err := encoding.NewEncoder(b.outFile, b.encConfig)
+		exitOnErr(cmd, err, true)
+		err = enc.Encode(inst.Value())
+		exitOnErr(cmd, err, true)
+		exitOnErr(cmd, enc.Close(), true)
+	}
+
+	if kind != diff.Identity {
+		exit()
+	}
+	return nil
+}
+
+// loadDiffValue loads the single value denoted by a command-line argument.
+func loadDiffValue(cmd *Command, arg string) (*buildPlan, cue.Value) {
+	b, err := parseArgs(cmd, []string{arg}, &config{outMode: filetypes.Export})
+	exitOnErr(cmd, err, true)
+
+	var v cue.Value
+	n := 0
+	iter := b.instances()
+	defer iter.close()
+	for ; iter.scan(); n++ {
+		v = iter.value()
+	}
+	exitOnErr(cmd, iter.err(), true)
+
+	if n != 1 {
+		exitOnErr(cmd, errors.Newf(token.NoPos,
+			"%s: found %d values; must evaluate to a single value", arg, n), true)
+	}
+	return b, v
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/docgen"
+)
+
+const docDoc = `doc generates documentation for CUE packages
+
+Each argument is a package, as with the other commands, or the import
+path of a builtin package, such as "strings" or "encoding/json". If no
+arguments are given, the package in the current directory is
+documented.
+
+The documentation of a package lists its definitions and fields with
+their types, defaults, constraints, attributes and doc comments.
+References to definitions and to the packages being documented link to
+their documentation. References to other builtin packages link to
+pkg.go.dev.
+
+The --format flag selects the output format:
+
+  markdown  GitHub flavored Markdown (default)
+  html      static HTML
+
+By default, the documentation of all packages is written to standard
+output as a single document. With --outdir, each package is written to
+its own page in the given directory, along with an index page.
+
+Examples:
+
+  # Document the package in the current directory.
+  $ cue doc
+
+  # Generate a static site for a schema and the builtin packages it uses.
+  $ cue doc --format html --outdir site ./schema strings net
+`
+
+func newDocCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doc [packages]",
+		Short: "generate documentation for packages",
+		Long:  docDoc,
+		RunE:  mkRunE(c, runDoc),
+	}
+
+	cmd.Flags().String(string(flagFormat), "markdown",
+		`output format: "markdown" or "html"`)
+	cmd.Flags().String(string(flagOutDir), "",
+		"write a page per package to this directory")
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runDoc(cmd *Command, args []string) error {
+	site := &docgen.Site{}
+	switch format := flagFormat.String(cmd); format {
+	case "markdown":
+		site.Format = docgen.Markdown
+	case "html":
+		site.Format = docgen.HTML
+	default:
+		return errors.Newf(token.NoPos,
+			`invalid --format %q: must be "markdown" or "html"`, format)
+	}
+
+	var builtins, pkgs []string
+	for _, arg := range args {
+		if docgen.IsBuiltin(arg) {
+			builtins = append(builtins, arg)
+		} else {
+			pkgs = append(pkgs, arg)
+		}
+	}
+
+	if len(pkgs) > 0 || len(builtins) == 0 {
+		b, err := parseArgs(cmd, pkgs, &config{})
+		exitOnErr(cmd, err, true)
+		if len(b.orphaned) > 0 {
+			return errors.Newf(token.NoPos, "doc only supports CUE packages")
+		}
+		for i, inst := range buildInstances(cmd, b.insts) {
+			binst := b.insts[i]
+			p := docgen.Extract(binst.ImportPath, binst.Files, inst.Value())
+			if !importable(binst) {
+				p.Dir = binst.DisplayPath
+			}
+			site.Packages = append(site.Packages, p)
+		}
+	}
+
+	for _, path := range builtins {
+		p, err := docgen.Builtin(path)
+		exitOnErr(cmd, err, true)
+		site.Packages = append(site.Packages, p)
+	}
+
+	if dir := flagOutDir.String(cmd); dir != "" {
+		_, err := site.WritePages(dir)
+		return err
+	}
+	return site.WriteDocument(cmd.OutOrStdout())
+}
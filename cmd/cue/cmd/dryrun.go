@@ -0,0 +1,156 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/depgraph"
+	"cuelang.org/go/tools/flow"
+)
+
+// printPlan writes the tasks of c in the given format without running them.
+// The tasks of the command rooted at root are reported relative to root.
+func printPlan(w io.Writer, c *flow.Controller, root cue.Path, format string) error {
+	tasks := sortTasks(c.Tasks())
+	name := func(t *flow.Task) string {
+		return relTaskPath(t.Path(), root)
+	}
+
+	switch format {
+	case "text":
+		for i, t := range tasks {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			writeTask(w, t, name)
+		}
+		return nil
+
+	case "dot", "mermaid":
+		g := &depgraph.Graph{}
+		for _, t := range tasks {
+			g.Nodes = append(g.Nodes, &depgraph.Node{ID: name(t), Path: name(t)})
+		}
+		for _, t := range tasks {
+			for _, d := range dependencies(t) {
+				g.Edges = append(g.Edges, &depgraph.Edge{From: name(t), To: name(d)})
+			}
+		}
+		if format == "dot" {
+			return g.WriteDOT(w)
+		}
+		return g.WriteMermaid(w)
+	}
+	return errors.Newf(token.NoPos,
+		`invalid --format %q: must be "text", "dot" or "mermaid"`, format)
+}
+
+// sortTasks sorts the tasks of a Controller such that each task comes after
+// its dependencies. Of the tasks that are ready at any point, the one that was
+// defined first is listed first. The tasks may not have cyclic dependencies.
+func sortTasks(tasks []*flow.Task) []*flow.Task {
+	done := map[*flow.Task]bool{}
+	sorted := make([]*flow.Task, 0, len(tasks))
+	for len(sorted) < len(tasks) {
+		n := len(sorted)
+	outer:
+		for _, t := range tasks {
+			if done[t] {
+				continue
+			}
+			for _, d := range t.Dependencies() {
+				if !done[d] {
+					continue outer
+				}
+			}
+			done[t] = true
+			sorted = append(sorted, t)
+			break
+		}
+		if n == len(sorted) {
+			panic("cycle in tasks")
+		}
+	}
+	return sorted
+}
+
+// dependencies returns the dependencies of t in the order in which they were
+// defined.
+func dependencies(t *flow.Task) []*flow.Task {
+	deps := append([]*flow.Task(nil), t.Dependencies()...)
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Index() < deps[j].Index()
+	})
+	return deps
+}
+
+// writeTask writes the path and kind of t, the tasks it depends on, and
+// its inputs. Concrete inputs are written as their value, others as their
+// type or expression.
+func writeTask(w io.Writer, t *flow.Task, name func(t *flow.Task) string) {
+	v := t.Value()
+	kind, err := v.Lookup("$id").String()
+	if err != nil {
+		kind, _ = v.Lookup("kind").String()
+	}
+	fmt.Fprintf(w, "%s: %s\n", name(t), kind)
+
+	if deps := dependencies(t); len(deps) > 0 {
+		var a []string
+		for _, d := range deps {
+			a = append(a, name(d))
+		}
+		fmt.Fprintf(w, "  after: %s\n", strings.Join(a, ", "))
+	}
+
+	iter, _ := v.Fields()
+	for iter.Next() {
+		label := iter.Label()
+		if strings.HasPrefix(label, "$") || label == "kind" {
+			continue
+		}
+		x := iter.Value()
+		b, err := format.Node(x.Syntax(cue.Final()), explainFormat...)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %s\n", cue.MakePath(cue.Str(label)),
+			indent(strings.TrimSpace(string(b)), "  "))
+	}
+}
+
+// relTaskPath returns the path of a task relative to the root of its command,
+// or the full path for tasks outside the command.
+func relTaskPath(p, root cue.Path) string {
+	sel := p.Selectors()
+	prefix := root.Selectors()
+	if len(prefix) >= len(sel) {
+		return p.String()
+	}
+	for i, s := range prefix {
+		if s.String() != sel[i].String() {
+			return p.String()
+		}
+	}
+	return cue.MakePath(sel[len(prefix):]...).String()
+}
@@ -37,6 +37,17 @@ Printing is skipped if validation fails.
 
 The --expression flag is used to evaluate an expression within the
 configuration file, instead of the entire configuration file itself.
+The flag also accepts paths with the wildcards * (any regular field),
+#* (any definition) and [*] (any list element), and filters of the
+form [?(expr)], which select the fields and list elements for which
+expr is true. The expression of a filter is evaluated within the
+selected value. Each value matched by such a path is printed preceded
+by its path. The export and def commands accept such paths as well.
+
+With --watch, eval keeps running and evaluates the configuration again
+whenever any of the files it was loaded from changes, including the files
+of imported packages. The output of each new evaluation is preceded by a
+line listing the changed files.
 
 Examples:
 
@@ -47,12 +58,21 @@ Examples:
   $ cue eval foo.cue -e a[0] -e a[2]
   "a"
   "c"
+
+  $ cue eval foo.cue -e 'a[*]'
+  // a[0]
+  "a"
+  // a[1]
+  "b"
+  // a[2]
+  "c"
 `,
 		RunE: mkRunE(c, runEval),
 	}
 
 	addOutFlags(cmd.Flags(), true)
 	addOrphanFlags(cmd.Flags())
+	addWatchFlag(cmd.Flags())
 
 	cmd.Flags().StringArrayP(string(flagExpression), "e", nil, "evaluate this expression only")
 
@@ -112,7 +132,7 @@ func runEval(cmd *Command, args []string) error {
 
 	iter := b.instances()
 	defer iter.close()
-	for i := 0; iter.scan(); i++ {
+	for iter.scan() {
 		id := ""
 		if len(b.insts) > 1 {
 			id = iter.id()
@@ -132,9 +152,8 @@ func runEval(cmd *Command, args []string) error {
 			}
 		}
 
-		if len(b.expressions) > 1 {
-			b, _ := format.Node(b.expressions[i%len(b.expressions)])
-			id = string(b)
+		if len(b.expressions) > 1 || b.hasQuery {
+			id = iter.(*expressionIter).label()
 		}
 		if err := v.Err(); err != nil {
 			errHeader()
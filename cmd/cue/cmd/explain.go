@@ -0,0 +1,185 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+)
+
+func newExplainCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain -e <path> [packages]",
+		Short: "show where the value of a field comes from",
+		Long: `Explain prints the conjuncts that contributed to the value of a field,
+along with their positions and how each of them arrived at the field.
+
+The value of the field, or its error, is printed first, followed by each
+contributing conjunct. A conjunct may arrive at a field through a chain
+of steps, which are listed below it starting with the step closest to
+the conjunct:
+
+  reference            a reference to a regular field, let or alias
+  definition           a reference to a definition
+  embedding            an embedded value
+  pattern constraint   a pattern constraint such as [string]: int
+  comprehension        a for or if comprehension
+  default              the default of a disjunction
+
+The --expression flag selects the fields to explain and may be repeated.
+It accepts paths with the same wildcards and filters as cue eval.
+
+Explain also reports the conjuncts of fields with conflicting values,
+which makes it useful for finding the sources of a conflict.
+
+Examples:
+
+  $ cat <<EOF > foo.cue
+  #Config: replicas: int
+  config: #Config & {replicas: 2}
+  EOF
+
+  $ cue explain -e config.replicas foo.cue
+  config.replicas: 2
+    ./foo.cue:1:10: replicas: int
+      via definition #Config (./foo.cue:2:9)
+    ./foo.cue:2:20: replicas: 2
+`,
+		RunE: mkRunE(c, runExplain),
+	}
+
+	cmd.Flags().StringArrayP(string(flagExpression), "e", nil,
+		"path of the fields to explain")
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runExplain(cmd *Command, args []string) error {
+	var paths []cue.Path
+	for _, s := range flagExpression.StringArray(cmd) {
+		p := cue.ParsePath(s)
+		if err := p.Err(); err != nil {
+			return err
+		}
+		paths = append(paths, p)
+	}
+	if len(paths) == 0 {
+		return errors.Newf(token.NoPos,
+			"explain requires at least one path set with --expression")
+	}
+
+	// Use the instances directly rather than through the expressions of the
+	// build plan, as evaluating an expression would hide the conjuncts of
+	// the selected fields.
+	b, err := parseArgs(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+
+	cwd, _ := os.Getwd()
+	w := cmd.OutOrStdout()
+	for _, binst := range b.insts {
+		inst := cue.Build([]*build.Instance{binst})[0]
+		exitOnErr(cmd, inst.Err, true)
+
+		for _, p := range paths {
+			a, err := inst.Value().Query(p)
+			if err != nil {
+				return err
+			}
+			if len(a) == 0 {
+				exitOnErr(cmd, errors.Newf(token.NoPos,
+					"no value found for path %s", p), false)
+			}
+			for _, v := range a {
+				explain(w, v, cwd)
+			}
+		}
+	}
+	return nil
+}
+
+// explain writes the value of v and the conjuncts that contributed to it
+// to w.
+func explain(w io.Writer, v cue.Value, cwd string) {
+	if err := v.Err(); err != nil {
+		// The error message includes the path.
+		fmt.Fprintln(w, err)
+	} else {
+		b, _ := format.Node(v.Syntax(cue.Final()), explainFormat...)
+		fmt.Fprintf(w, "%s: %s\n", v.Path(), indent(strings.TrimSpace(string(b)), "  "))
+	}
+
+	for _, o := range v.Origins() {
+		fmt.Fprintf(w, "  %s: %s\n",
+			relPos(o.Pos(), cwd), indent(formatSource(o.Source), "    "))
+		for _, s := range o.Via {
+			src := formatSource(s.Source)
+			if src != "" {
+				src = " " + indent(src, "      ")
+			}
+			fmt.Fprintf(w, "    via %s%s (%s)\n", s.Kind, src, relPos(s.Pos(), cwd))
+		}
+	}
+}
+
+var explainFormat = []format.Option{
+	format.UseSpaces(2),
+	format.TabIndent(false),
+}
+
+// formatSource formats n, or returns the empty string if n cannot be
+// formatted, as is the case for comprehension clauses.
+func formatSource(n ast.Node) string {
+	if n == nil {
+		return ""
+	}
+	b, err := format.Node(n, explainFormat...)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// indent indents all but the first line of s with prefix.
+func indent(s, prefix string) string {
+	return strings.Replace(s, "\n", "\n"+prefix, -1)
+}
+
+// relPos formats pos with a file name relative to cwd, like errors do.
+func relPos(pos token.Pos, cwd string) string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	p := pos.Position()
+	if rel, err := filepath.Rel(cwd, p.Filename); err == nil &&
+		!strings.HasPrefix(rel, "..") {
+		p.Filename = "./" + filepath.ToSlash(rel)
+	}
+	return p.String()
+}
@@ -73,6 +73,13 @@ ancestor directories, up to the module root, belonging to the same package.
 If the package is not explicitly defined by the '-p' flag, it must be uniquely
 defined by the files in the current directory.
 
+Watching files:
+With --watch, export keeps running and exports the configuration again
+whenever any of the files that contributed to it changes, including data
+files and the files of imported packages. Each new output is preceded by
+a line listing the changed files, and errors do not end the command:
+
+	cue export --watch -o config.yaml
 
 Formats
 The following formats are recognized:
@@ -92,6 +99,7 @@ yaml    output as YAML
 
 	addOutFlags(cmd.Flags(), true)
 	addOrphanFlags(cmd.Flags())
+	addWatchFlag(cmd.Flags())
 
 	cmd.Flags().Bool(string(flagEscape), false, "use HTML escaping")
 
@@ -33,6 +33,12 @@ const (
 	flagPackage   flagName = "package"
 	flagInject    flagName = "inject"
 
+	flagErrorsFormat flagName = "errors-format"
+
+	flagStats      flagName = "stats"
+	flagCPUProfile flagName = "cpuprofile"
+	flagMemProfile flagName = "memprofile"
+
 	flagExpression  flagName = "expression"
 	flagSchema      flagName = "schema"
 	flagEscape      flagName = "escape"
@@ -46,6 +52,20 @@ const (
 	flagWithContext flagName = "with-context"
 	flagOut         flagName = "out"
 	flagOutFile     flagName = "outfile"
+	flagOutDir      flagName = "outdir"
+	flagFormat      flagName = "format"
+	flagDepth       flagName = "depth"
+	flagEnable      flagName = "enable"
+	flagDisable     flagName = "disable"
+	flagWatch       flagName = "watch"
+	flagRegistry    flagName = "registry"
+	flagModules     flagName = "modules"
+	flagVendor      flagName = "vendor"
+	flagRun         flagName = "run"
+	flagUpdate      flagName = "update"
+	flagPatch       flagName = "patch"
+	flagAddr        flagName = "addr"
+	flagRequire     flagName = "require"
 )
 
 func addOutFlags(f *pflag.FlagSet, allowNonCUE bool) {
@@ -69,6 +89,16 @@ func addGlobalFlags(f *pflag.FlagSet) {
 	f.BoolP(string(flagVerbose), "v", false,
 		"print information about progress")
 	f.BoolP(string(flagAllErrors), "E", false, "print all available errors")
+	f.Bool(string(flagVendor), false,
+		"load packages outside the main module from cue.mod/vendor only")
+	f.String(string(flagErrorsFormat), "text",
+		"format of error messages: text, json or sarif")
+	f.Bool(string(flagStats), false,
+		"print evaluator statistics and timings")
+	f.String(string(flagCPUProfile), "",
+		"write a CPU profile to the given file")
+	f.String(string(flagMemProfile), "",
+		"write a heap profile to the given file")
 }
 
 func addOrphanFlags(f *pflag.FlagSet) {
@@ -95,6 +125,11 @@ func (f flagName) String(cmd *Command) string {
 	return v
 }
 
+func (f flagName) Int(cmd *Command) int {
+	v, _ := cmd.Flags().GetInt(string(f))
+	return v
+}
+
 func (f flagName) StringArray(cmd *Command) []string {
 	v, _ := cmd.Flags().GetStringArray(string(f))
 	return v
@@ -0,0 +1,122 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/depgraph"
+)
+
+const graphDoc = `graph prints the dependency graph of the fields of a package
+
+The graph has an edge from a field to each field it references, either
+directly or through the definitions it unifies with. Only fields that
+reference or are referenced by other fields are included.
+
+References are distinguished by what they refer to: definitions and
+the fields within them, values in imported packages, and regular
+fields.
+
+The --expression flag restricts the graph to the fields of the given
+value, although references to fields outside of it are still included.
+The --depth flag restricts the graph to fields up to the given depth;
+the references of deeper fields, and references to them, are attributed
+to their ancestor at that depth.
+
+The --format flag selects the output format:
+
+  dot      Graphviz DOT (default); definitions are boxes and
+           references to them are dashed, imported values are
+           notes and references to them are dotted
+  mermaid  Mermaid flowchart; definitions are subroutines and
+           references to them are dotted, imported values are
+           cylinders and references to them are thick
+  json     a JSON object with a list of nodes and a list of edges,
+           each with a kind of "field", "definition" or "import"
+
+Examples:
+
+  # Render the dependencies between the top-level fields as an image.
+  $ cue graph --depth 1 | dot -Tsvg > deps.svg
+
+  # Show the dependencies of a single service.
+  $ cue graph -e services.frontend --format mermaid ./config
+`
+
+func newGraphCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [package]",
+		Short: "print the dependency graph of the fields of a package",
+		Long:  graphDoc,
+		RunE:  mkRunE(c, runGraph),
+	}
+
+	cmd.Flags().StringArrayP(string(flagExpression), "e", nil,
+		"restrict the graph to the fields of this value")
+	cmd.Flags().Int(string(flagDepth), 0,
+		"maximum depth of fields in the graph; 0 means unlimited")
+	cmd.Flags().String(string(flagFormat), "dot",
+		`output format: "dot", "mermaid" or "json"`)
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runGraph(cmd *Command, args []string) error {
+	var write func(g *depgraph.Graph) error
+	w := cmd.OutOrStdout()
+	switch format := flagFormat.String(cmd); format {
+	case "dot":
+		write = func(g *depgraph.Graph) error { return g.WriteDOT(w) }
+	case "mermaid":
+		write = func(g *depgraph.Graph) error { return g.WriteMermaid(w) }
+	case "json":
+		write = func(g *depgraph.Graph) error { return g.WriteJSON(w) }
+	default:
+		return errors.Newf(token.NoPos,
+			`invalid --format %q: must be "dot", "mermaid" or "json"`, format)
+	}
+	if len(args) > 1 {
+		return errors.Newf(token.NoPos, "graph accepts at most one package")
+	}
+
+	b, err := parseArgs(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+
+	var v cue.Value
+	n := 0
+	iter := b.instances()
+	defer iter.close()
+	for ; iter.scan(); n++ {
+		v = iter.value()
+	}
+	exitOnErr(cmd, iter.err(), true)
+	if n != 1 {
+		return errors.Newf(token.NoPos,
+			"found %d values; must evaluate to a single value", n)
+	}
+
+	exitOnErr(cmd, v.Err(), true)
+
+	g, err := depgraph.Build(v, flagDepth.Int(cmd))
+	exitOnErr(cmd, err, true)
+	return write(g)
+}
@@ -53,7 +53,9 @@ element-- is interpreted as a file system path and denotes the
 package instance in that directory.
 
 Otherwise, the import path P denotes and external package found
-in cue.mod/{pkg|gen|usr}/P.
+in cue.mod/{pkg|gen|usr}/P or, if P is provided by a module
+required in cue.mod/module.cue, in the module cache (see
+'cue help mod').
 
 An import path may contain one or more "..." to match any
 subdirectory: pkg/... matches all packages below pkg, including
@@ -77,6 +79,13 @@ differently.
 If a data file has multiple values, such as allowed with JSON
 Lines or YAML, each value is interpreted as a separate file.
 
+An archive with extension .zip, .tar, .tar.gz, .tgz or .txtar
+is treated as a directory tree. Its contents are addressed as
+archive:arg, where arg is a package pattern or file relative to
+the root of the archive. An archive by itself denotes the package
+at its root. The root of an archive is also the module root: a
+cue.mod directory in the archive is used as its module.
+
 If the --schema/-d is specified, data files are not merged, and
 are compared against the specified schema within a package or
 non-data file. For OpenAPI, the -d flag specifies a schema name.
@@ -95,6 +104,9 @@ $ cue export ./pkg -d Foo foo.yaml
 
 # Unify data.json with schema.json.
 $ cue export data.json schema: schema.json
+
+# Evaluate all packages in an archive.
+$ cue eval bundle.zip:./...
 `,
 }
 
@@ -431,6 +431,11 @@ func getFilename(b *buildPlan, f *ast.File, root string, force bool) (filename s
 				return "", nil
 			}
 		default:
+			if a := containingArchive(cueFile); a != "" {
+				return "", fmt.Errorf(
+					"cannot write %s to archive %s: use -o to specify an output file",
+					filepath.Base(cueFile), a)
+			}
 			return "", fmt.Errorf("error creating file: %v", err)
 		}
 	}
@@ -0,0 +1,131 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/internal/lint"
+)
+
+const lintDoc = `lint reports suspicious constructs in CUE packages
+
+Lint checks packages with a set of rules, each of which reports one kind
+of problem. The following rules are available; those marked with * are
+enabled by default:
+
+%s
+Rules can be enabled with --enable and disabled with --disable. Both
+flags accept a comma-separated list of rule names and may be repeated.
+The name "all" refers to all rules; rules that are named explicitly take
+precedence over it.
+
+A finding can be suppressed with a comment at the end of its line or on
+the line before it:
+
+  //cue:lint-ignore unused-let,shadow kept for backwards compatibility
+
+The comment lists the rules to suppress, or "all", optionally followed by
+a reason.
+
+Findings are reported as warnings along with the name of the rule that
+reported them. Use --errors-format to report them as JSON or SARIF. Lint
+exits with a non-zero status if there are any findings.
+
+Packages that fail to compile are checked without the rules that require
+evaluation. Use cue vet to report the errors of such packages.
+
+Examples:
+
+  # Check all packages in a module, including regular fields that
+  # should be definitions.
+  $ cue lint --enable field-should-be-definition ./...
+
+  # Check a package for unused declarations only.
+  $ cue lint --disable all --enable unused-definition,unused-let,unused-import
+`
+
+func newLintCmd(c *Command) *cobra.Command {
+	var rules strings.Builder
+	for _, r := range lint.Rules {
+		mark := " "
+		if r.Default {
+			mark = "*"
+		}
+		fmt.Fprintf(&rules, "  %s %-27s %s\n", mark, r.Name, r.Doc)
+	}
+
+	cmd := &cobra.Command{
+		Use:   "lint [packages]",
+		Short: "report suspicious constructs in packages",
+		Long:  fmt.Sprintf(lintDoc, rules.String()),
+		RunE:  mkRunE(c, runLint),
+	}
+
+	cmd.Flags().StringArray(string(flagEnable), nil,
+		"comma-separated list of rules to enable in addition to the default rules")
+	cmd.Flags().StringArray(string(flagDisable), nil,
+		"comma-separated list of rules to disable")
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runLint(cmd *Command, args []string) error {
+	rules, err := lint.Select(
+		ruleNames(flagEnable.StringArray(cmd)),
+		ruleNames(flagDisable.StringArray(cmd)))
+	if err != nil {
+		return err
+	}
+
+	b, err := parseArgs(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+
+	for _, binst := range b.insts {
+		exitOnErr(cmd, binst.Err, true)
+
+		// Evaluation is only needed for some of the rules; lint the files
+		// even if the package does not compile.
+		var v cue.Value
+		if inst := cue.Build([]*build.Instance{binst})[0]; inst.Err == nil {
+			v = inst.Value()
+		}
+		if errs := lint.Package(binst.Files, v, rules); errs != nil {
+			exitOnErr(cmd, errs, false)
+		}
+	}
+	return nil
+}
+
+// ruleNames splits comma-separated lists of rule names.
+func ruleNames(a []string) []string {
+	var names []string
+	for _, s := range a {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
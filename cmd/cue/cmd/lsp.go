@@ -0,0 +1,58 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/lsp"
+)
+
+const lspDoc = `lsp runs a language server for editor integration
+
+The language server communicates with an editor over standard input
+and output using the Language Server Protocol. It analyzes the package
+of each open file, taking unsaved changes to other open files into
+account, and supports:
+
+  - diagnostics for parse and evaluation errors,
+  - hover, showing the doc comments and evaluated value of a field,
+  - go to definition and find references,
+  - formatting, like cue fmt,
+  - completion of the fields allowed by closed structs and of the
+    definitions of a package.
+
+Configure your editor to start 'cue lsp' for files with the .cue
+extension.
+`
+
+func newLspCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "run a language server for editor integration",
+		Long:  lspDoc,
+		RunE:  mkRunE(c, runLsp),
+	}
+	return cmd
+}
+
+func runLsp(cmd *Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Newf(token.NoPos, "lsp takes no arguments")
+	}
+	return lsp.Serve(cmd.InOrStdin(), cmd.OutOrStdout())
+}
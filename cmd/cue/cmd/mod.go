@@ -29,7 +29,53 @@ func newModCmd(c *Command) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "mod <cmd> [arguments]",
 		Short: "module maintenance",
-		Long: `
+		Long: `Mod groups commands that operate on modules.
+
+A module is defined by a cue.mod directory at its root. The file
+cue.mod/module.cue declares the module path and the versions of
+the modules it depends on:
+
+	module: "example.com/foo"
+
+	require: {
+		"acme.com/schemas": "v1.2.0"
+	}
+
+Required modules are fetched from a module registry into the
+module cache, from where they are loaded when imported. The
+registry is specified with the --registry flag or the
+CUE_REGISTRY environment variable and is currently either a
+local directory or a file:// URL, holding each module version
+in a directory named <module path>@<version>. The module cache
+is located in $CUE_CACHE_DIR/mod, which defaults to the cue
+directory within the user cache directory.
+
+The file cue.mod/cue.sum records a cryptographic hash of the
+contents of each required module version. It is maintained by
+'cue mod get' and 'cue mod tidy' and should be checked in along
+with module.cue. When a package of a required module is loaded,
+the contents of the module are verified against cue.sum, and
+loading fails if the module is missing from cue.sum or its
+contents do not match.
+
+The mod commands also record in cue.sum a hash of the files of each
+directory within cue.mod/pkg. If the main module has a cue.sum file
+or requires modules, packages loaded from cue.mod/pkg are verified
+in the same way. Run 'cue mod tidy' after changing the contents of
+cue.mod/pkg to record the changes.
+
+The replace section of module.cue maps import path prefixes to
+local directories. Packages with a matching import path are loaded
+from the given directory instead of from a required module or from
+cue.mod/pkg, which allows developing a module and its users side
+by side:
+
+	replace: {
+		"acme.com/schemas": "../schemas"
+	}
+
+Relative directories are interpreted relative to the module root.
+Replaced packages are not verified against cue.sum.
 `,
 		RunE: mkRunE(c, func(cmd *Command, args []string) error {
 			stderr := cmd.Stderr()
@@ -45,6 +91,11 @@ func newModCmd(c *Command) *cobra.Command {
 	}
 
 	cmd.AddCommand(newModInitCmd(c))
+	cmd.AddCommand(newModGetCmd(c))
+	cmd.AddCommand(newModTidyCmd(c))
+	cmd.AddCommand(newModGraphCmd(c))
+	cmd.AddCommand(newModWhyCmd(c))
+	cmd.AddCommand(newModVendorCmd(c))
 	return cmd
 }
 
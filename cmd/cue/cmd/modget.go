@@ -0,0 +1,321 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/mod"
+)
+
+func newModGetCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <module>[@version] ...",
+		Short: "add or update module dependencies",
+		Long: `Get adds the given modules as requirements of the current
+module, fetching them from the module registry into the module
+cache. If no version is specified, the latest release version
+available in the registry is used.
+
+Requirements of the fetched modules are added as well. If more
+than one version of a module is required, the highest one is
+selected.
+
+See 'cue help mod' for how to configure the registry.
+`,
+		RunE: mkRunE(c, runModGet),
+	}
+
+	addRegistryFlag(cmd)
+
+	return cmd
+}
+
+func addRegistryFlag(cmd *cobra.Command) {
+	cmd.Flags().String(string(flagRegistry), "",
+		"location of the module registry (default $"+mod.RegistryEnv+")")
+}
+
+func runModGet(cmd *Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Newf(token.NoPos, "no modules specified")
+	}
+
+	m, err := newModContext(cmd)
+	if err != nil {
+		return err
+	}
+
+	old := append([]mod.Version(nil), m.file.Require...)
+
+	require := m.file.Require
+	for _, arg := range args {
+		v, err := mod.ParseVersion(arg)
+		if err != nil {
+			return err
+		}
+		if v.Path == m.file.Module {
+			return errors.Newf(token.NoPos, "cannot require main module %s", v.Path)
+		}
+		if v.Version == "" {
+			if v.Version, err = m.latest(v.Path); err != nil {
+				return err
+			}
+		}
+		require = setRequirement(require, v)
+	}
+
+	if err := m.update(require); err != nil {
+		return err
+	}
+	m.report(old)
+	return m.write()
+}
+
+// setRequirement sets the required version of module v.Path to v.Version.
+func setRequirement(a []mod.Version, v mod.Version) []mod.Version {
+	for i, m := range a {
+		if m.Path == v.Path {
+			a[i] = v
+			return a
+		}
+	}
+	return append(a, v)
+}
+
+// A modContext holds the state of the main module used by the mod
+// subcommands.
+type modContext struct {
+	cmd   *Command
+	root  string // root directory of the main module
+	file  *mod.File
+	sums  mod.Sums
+	cache string // root of the CUE cache
+
+	reg mod.Registry
+}
+
+func newModContext(cmd *Command) (*modContext, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	root := findModuleRoot(cwd)
+	if root == "" {
+		return nil, errors.Newf(token.NoPos,
+			"no cue.mod directory found; run 'cue mod init' first")
+	}
+	filename := filepath.Join(root, "cue.mod", "module.cue")
+	b, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := mod.Parse(filename, b)
+	if err != nil {
+		return nil, err
+	}
+	filename = filepath.Join(root, "cue.mod", mod.SumFile)
+	b, err = ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sums, err := mod.ParseSums(filename, b)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := mod.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &modContext{
+		cmd:   cmd,
+		root:  root,
+		file:  f,
+		sums:  sums,
+		cache: cache,
+	}, nil
+}
+
+// findModuleRoot returns the closest ancestor of dir that contains a cue.mod
+// directory, or "" if there is none.
+func findModuleRoot(dir string) string {
+	for {
+		info, err := os.Stat(filepath.Join(dir, "cue.mod"))
+		if err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// registry returns the configured module registry.
+func (m *modContext) registry() (mod.Registry, error) {
+	if m.reg != nil {
+		return m.reg, nil
+	}
+	location := flagRegistry.String(m.cmd)
+	if location == "" {
+		location = os.Getenv(mod.RegistryEnv)
+	}
+	reg, err := mod.NewRegistry(location)
+	if err != nil {
+		return nil, err
+	}
+	m.reg = reg
+	return reg, nil
+}
+
+// latest returns the latest version of the given module in the registry.
+func (m *modContext) latest(path string) (string, error) {
+	reg, err := m.registry()
+	if err != nil {
+		return "", err
+	}
+	versions, err := reg.Versions(path)
+	if err != nil {
+		return "", err
+	}
+	v := mod.LatestVersion(versions)
+	if v == "" {
+		return "", errors.Newf(token.NoPos, "module %s not found in registry", path)
+	}
+	return v, nil
+}
+
+// download ensures module version v is in the module cache and returns
+// its directory.
+func (m *modContext) download(v mod.Version) (string, error) {
+	dir := mod.Dir(m.cache, v)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	reg, err := m.registry()
+	if err != nil {
+		return "", err
+	}
+	return mod.Download(reg, m.cache, v)
+}
+
+// requirements reports the requirements of dependency v, downloading it if
+// necessary.
+func (m *modContext) requirements(v mod.Version) ([]mod.Version, error) {
+	dir, err := m.download(v)
+	if err != nil {
+		return nil, err
+	}
+	f, err := mod.ReadFile(dir)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return f.Require, nil
+}
+
+// update sets the requirements of the main module to the build list
+// computed from the given requirements.
+func (m *modContext) update(require []mod.Version) error {
+	list, err := mod.BuildList(require, m.requirements)
+	if err != nil {
+		return err
+	}
+	k := 0
+	for _, v := range list {
+		if v.Path != m.file.Module {
+			list[k] = v
+			k++
+		}
+	}
+	m.file.Require = list[:k]
+	return nil
+}
+
+// report prints the changes in requirements relative to old.
+func (m *modContext) report(old []mod.Version) {
+	w := m.cmd.OutOrStderr()
+	prev := map[string]string{}
+	for _, v := range old {
+		prev[v.Path] = v.Version
+	}
+	for _, v := range m.file.Require {
+		switch p, ok := prev[v.Path]; {
+		case !ok:
+			fmt.Fprintf(w, "added %s %s\n", v.Path, v.Version)
+		case mod.CompareVersion(v.Version, p) > 0:
+			fmt.Fprintf(w, "upgraded %s %s => %s\n", v.Path, p, v.Version)
+		case mod.CompareVersion(v.Version, p) < 0:
+			fmt.Fprintf(w, "downgraded %s %s => %s\n", v.Path, p, v.Version)
+		}
+		delete(prev, v.Path)
+	}
+	for _, v := range old {
+		if _, ok := prev[v.Path]; ok {
+			fmt.Fprintf(w, "removed %s %s\n", v.Path, v.Version)
+		}
+	}
+}
+
+// write writes the module file and checksum file of the main module. The
+// checksum file holds an entry for each required module and for each
+// directory within cue.mod/pkg that holds files. It is an error if the
+// contents of a module in the cache do not match an existing entry. The
+// entries for cue.mod/pkg record the current contents of the directories.
+func (m *modContext) write() error {
+	sums, err := mod.HashPkgDirs(m.root)
+	if err != nil {
+		return err
+	}
+	for _, v := range m.file.Require {
+		dir, err := m.download(v)
+		if err != nil {
+			return err
+		}
+		h, err := mod.HashDir(dir)
+		if err != nil {
+			return err
+		}
+		if want, ok := m.sums[v]; ok && want != h {
+			return &mod.ChecksumError{Module: v, Got: h, Want: want}
+		}
+		sums[v] = h
+	}
+
+	b, err := m.file.Format()
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(m.root, "cue.mod", "module.cue")
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		return err
+	}
+
+	filename = filepath.Join(m.root, "cue.mod", mod.SumFile)
+	if len(sums) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(filename, sums.Format(), 0644)
+}
@@ -0,0 +1,232 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/mod"
+)
+
+func newModGraphCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [packages]",
+		Short: "print the import graph of the current module",
+		Long: `Graph prints the import graph of the packages of the current
+module, or of the given packages, including all packages they
+transitively import. Builtin packages are not included.
+
+In text format, each line holds an edge of the graph, consisting
+of the importing package and the imported package separated by a
+space. With --out=dot, the graph is printed in the Graphviz DOT
+language.
+
+With --modules, the graph shows the dependencies between modules
+instead of packages. Required modules are shown as path@version.
+`,
+		RunE: mkRunE(c, runModGraph),
+	}
+
+	cmd.Flags().String(string(flagOut), "text", "output format: text or dot")
+	cmd.Flags().Bool(string(flagModules), false, "show the module graph instead of the package graph")
+
+	return cmd
+}
+
+func runModGraph(cmd *Command, args []string) error {
+	g, err := loadImportGraph(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	edges := g.edges
+	if flagModules.Bool(cmd) {
+		edges = g.moduleEdges()
+	}
+
+	w := cmd.OutOrStdout()
+	switch out := flagOut.String(cmd); out {
+	case "text":
+		for _, e := range edges {
+			fmt.Fprintf(w, "%s %s\n", e[0], e[1])
+		}
+	case "dot":
+		writeDOT(w, "imports", edges)
+	default:
+		return errors.Newf(token.NoPos, "unknown output format %q", out)
+	}
+	return nil
+}
+
+// writeDOT writes the given edges as a Graphviz digraph.
+func writeDOT(w io.Writer, name string, edges [][2]string) {
+	fmt.Fprintf(w, "digraph %s {\n", name)
+	for _, e := range edges {
+		fmt.Fprintf(w, "\t%s -> %s;\n", strconv.Quote(e[0]), strconv.Quote(e[1]))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// An importGraph holds the packages of the main module and the packages they
+// transitively import.
+type importGraph struct {
+	m *modContext
+
+	roots []string // packages of the main module, sorted
+	edges [][2]string
+
+	// imports maps a package to the sorted packages it imports.
+	imports map[string][]string
+	// module maps a package to the path of its module.
+	module map[string]string
+	// insts maps a package to its build instance.
+	insts map[string]*build.Instance
+}
+
+// loadImportGraph loads the given packages, or all packages of the main
+// module if there are none, and returns their import graph. Test and tool
+// files are included.
+func loadImportGraph(cmd *Command, args []string) (*importGraph, error) {
+	m, err := newModContext(cmd)
+	if err != nil {
+		return nil, err
+	}
+	dir := ""
+	if len(args) == 0 {
+		dir = m.root
+		args = []string{"./..."}
+	}
+	insts := load.Instances(args, &load.Config{
+		Dir:    dir,
+		Tests:  true,
+		Tools:  true,
+		Vendor: flagVendor.Bool(cmd),
+	})
+
+	g := &importGraph{
+		m:       m,
+		imports: map[string][]string{},
+		module:  map[string]string{},
+		insts:   map[string]*build.Instance{},
+	}
+
+	var errs errors.Error
+	var visit func(p *build.Instance)
+	visit = func(p *build.Instance) {
+		path := packagePath(p)
+		if _, ok := g.imports[path]; ok {
+			return
+		}
+		g.imports[path] = nil
+		g.module[path] = p.Module
+		g.insts[path] = p
+		if p.Err != nil {
+			errs = errors.Append(errs, p.Err)
+		}
+		for _, dep := range p.Imports {
+			g.imports[path] = append(g.imports[path], packagePath(dep))
+			visit(dep)
+		}
+		sort.Strings(g.imports[path])
+	}
+	for _, p := range insts {
+		g.roots = append(g.roots, packagePath(p))
+		visit(p)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	sort.Strings(g.roots)
+
+	for _, from := range g.packages() {
+		for _, to := range g.imports[from] {
+			g.edges = append(g.edges, [2]string{from, to})
+		}
+	}
+	return g, nil
+}
+
+// packagePath returns the import path of p or, if p has no import path, its
+// display path.
+func packagePath(p *build.Instance) string {
+	if p.ImportPath != "" {
+		return p.ImportPath
+	}
+	return p.DisplayPath
+}
+
+// packages returns all packages in the graph in sorted order.
+func (g *importGraph) packages() []string {
+	a := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		a = append(a, p)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// moduleEdges returns the sorted edges of the module graph derived from the
+// package graph.
+func (g *importGraph) moduleEdges() [][2]string {
+	seen := map[[2]string]bool{}
+	var edges [][2]string
+	for _, e := range g.edges {
+		from, to := g.moduleName(e[0]), g.moduleName(e[1])
+		if from == to || seen[[2]string{from, to}] {
+			continue
+		}
+		seen[[2]string{from, to}] = true
+		edges = append(edges, [2]string{from, to})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// moduleName returns the name of the module of package p, including the
+// version for required modules.
+func (g *importGraph) moduleName(p string) string {
+	return g.moduleVersion(p).String()
+}
+
+// moduleVersion returns the module of package p. The version is only set for
+// required modules that are not replaced. Packages outside any module, such
+// as those in cue.mod/pkg, are identified by their import path.
+func (g *importGraph) moduleVersion(p string) mod.Version {
+	path := g.module[p]
+	if path == "" || !mod.HasPathPrefix(importPathDir(p), path) {
+		return mod.Version{Path: importPathDir(p)}
+	}
+	if g.m.isReplaced(p) {
+		return mod.Version{Path: path}
+	}
+	if v, ok := g.m.file.Lookup(path); ok {
+		return v
+	}
+	return mod.Version{Path: path}
+}
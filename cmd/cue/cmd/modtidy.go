@@ -0,0 +1,219 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/mod"
+)
+
+func newModTidyCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tidy",
+		Short: "add missing and remove unused module requirements",
+		Long: `Tidy makes the requirements in cue.mod/module.cue match the
+imports of the CUE files in the current module.
+
+It adds requirements for modules that provide imported packages,
+using the latest version available in the module registry for
+modules that are not yet required, and removes requirements for
+modules that are no longer needed. Packages that are found in
+cue.mod/pkg, cue.mod/gen or cue.mod/usr, or that are provided by
+a replacement, do not need a module requirement.
+
+All files are considered, including test and tool files and files
+excluded by build tags.
+`,
+		RunE: mkRunE(c, runModTidy),
+	}
+
+	addRegistryFlag(cmd)
+
+	return cmd
+}
+
+func runModTidy(cmd *Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Newf(token.NoPos, "tidy takes no arguments")
+	}
+
+	m, err := newModContext(cmd)
+	if err != nil {
+		return err
+	}
+
+	imports, err := moduleImports(m.root)
+	if err != nil {
+		return err
+	}
+
+	old := append([]mod.Version(nil), m.file.Require...)
+
+	var require []mod.Version
+	needed := map[string]bool{}
+	for _, p := range imports {
+		if !isExternalImport(p) || m.isMainModule(p) {
+			continue
+		}
+		v, ok := mod.ModuleForImport(old, p)
+		if !ok {
+			if m.isLocalPkg(p) || m.isReplaced(p) {
+				continue
+			}
+			if v, err = m.findModule(p); err != nil {
+				return err
+			}
+		}
+		if !needed[v.Path] {
+			needed[v.Path] = true
+			require = append(require, v)
+		}
+	}
+
+	if err := m.update(require); err != nil {
+		return err
+	}
+	m.report(old)
+	return m.write()
+}
+
+// isExternalImport reports whether p is the import path of a package that is
+// not a builtin package.
+func isExternalImport(p string) bool {
+	return strings.Contains(strings.Split(p, "/")[0], ".")
+}
+
+// isMainModule reports whether the package with import path p is part of
+// the main module.
+func (m *modContext) isMainModule(p string) bool {
+	return m.file.Module != "" && mod.HasPathPrefix(importPathDir(p), m.file.Module)
+}
+
+// isLocalPkg reports whether the package with import path p is provided by
+// one of the cue.mod/{gen,pkg,usr} directories of the main module.
+func (m *modContext) isLocalPkg(p string) bool {
+	p = importPathDir(p)
+	for _, sub := range []string{"gen", "pkg", "usr"} {
+		dir := filepath.Join(m.root, "cue.mod", sub, filepath.FromSlash(p))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// isReplaced reports whether the package with import path p is provided by a
+// replacement in the module file of the main module.
+func (m *modContext) isReplaced(p string) bool {
+	_, ok := m.file.Replacement(p)
+	return ok
+}
+
+// findModule finds the module that provides the package with import path p
+// in the registry. If more than one module matches, the module with the
+// longest path is selected.
+func (m *modContext) findModule(p string) (mod.Version, error) {
+	reg, err := m.registry()
+	if err != nil {
+		return mod.Version{}, err
+	}
+	for prefix := importPathDir(p); prefix != "."; prefix = path.Dir(prefix) {
+		if mod.CheckPath(prefix) != nil {
+			break
+		}
+		versions, err := reg.Versions(prefix)
+		if err != nil {
+			return mod.Version{}, err
+		}
+		if v := mod.LatestVersion(versions); v != "" {
+			return mod.Version{Path: prefix, Version: v}, nil
+		}
+	}
+	return mod.Version{}, errors.Newf(token.NoPos,
+		"cannot find module providing package %s", p)
+}
+
+// importPathDir returns the import path p without package qualifier.
+func importPathDir(p string) string {
+	if i := strings.LastIndexByte(p, ':'); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
+
+// moduleImports returns the sorted import paths of all CUE files in the module
+// rooted at root. Directories within the cue.mod directory, nested modules and
+// directories starting with "." or "_" are skipped.
+func moduleImports(root string) ([]string, error) {
+	seen := map[string]bool{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if p == root {
+				return nil
+			}
+			if name == "cue.mod" || strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "cue.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".cue") || strings.HasPrefix(name, ".") ||
+			strings.HasPrefix(name, "_") {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		f, err := parser.ParseFile(p, b, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range f.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				seen[path] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	imports := make([]string, 0, len(seen))
+	for p := range seen {
+		imports = append(imports, p)
+	}
+	sort.Strings(imports)
+	return imports, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/mod"
+)
+
+func newModVendorCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "copy imported packages into cue.mod/vendor",
+		Long: `Vendor copies all packages that are transitively imported by the
+packages of the current module into the directory cue.mod/vendor.
+Builtin packages and packages of the current module are not
+copied. The vendor directory is recreated on each run.
+
+The file cue.mod/vendor/modules.txt lists the vendored packages,
+grouped by the module that provides them, along with the version
+of required modules.
+
+With the --vendor flag, which is accepted by all commands that load
+packages, packages outside the current module are loaded only from
+the vendor directory. This allows builds without access to the
+module cache, the module registry or replacement directories.
+`,
+		RunE: mkRunE(c, runModVendor),
+	}
+	return cmd
+}
+
+func runModVendor(cmd *Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Newf(token.NoPos, "vendor takes no arguments")
+	}
+	if flagVendor.Bool(cmd) {
+		return errors.Newf(token.NoPos, "cannot use --vendor with mod vendor")
+	}
+
+	g, err := loadImportGraph(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	vendorDir := filepath.Join(g.m.root, "cue.mod", mod.VendorDir)
+	if err := os.RemoveAll(vendorDir); err != nil {
+		return err
+	}
+
+	isRoot := map[string]bool{}
+	for _, p := range g.roots {
+		isRoot[p] = true
+	}
+
+	mods := map[string]*mod.VendoredModule{}
+	for _, p := range g.packages() {
+		if isRoot[p] || g.m.isMainModule(p) {
+			continue
+		}
+		if err := vendorPackage(vendorDir, p, g.insts[p]); err != nil {
+			return err
+		}
+		v := g.moduleVersion(p)
+		m, ok := mods[v.Path]
+		if !ok {
+			m = &mod.VendoredModule{Version: v}
+			mods[v.Path] = m
+		}
+		m.Packages = append(m.Packages, p)
+	}
+	if len(mods) == 0 {
+		return nil
+	}
+
+	var list []mod.VendoredModule
+	for _, m := range mods {
+		list = append(list, *m)
+	}
+	filename := filepath.Join(vendorDir, mod.VendorManifest)
+	return ioutil.WriteFile(filename, mod.FormatVendorManifest(list), 0644)
+}
+
+// vendorPackage copies the CUE files of package p with import path pkg into
+// the vendor directory. Files from ancestor directories that are part of the
+// package are copied to the corresponding ancestor directory.
+func vendorPackage(vendorDir, pkg string, p *build.Instance) error {
+	var files []*build.File
+	files = append(files, p.BuildFiles...)
+	files = append(files, p.IgnoredFiles...)
+	for _, f := range files {
+		if f.Encoding != build.CUE || !filepath.IsAbs(f.Filename) {
+			continue
+		}
+		rel, ok := vendorPath(p, f.Filename)
+		if !ok {
+			return errors.Newf(token.NoPos,
+				"cannot vendor %s: file %s is outside of module", pkg, f.Filename)
+		}
+		b, err := ioutil.ReadFile(f.Filename)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(vendorDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vendorPath returns the slash-separated location of a file of package p
+// within the vendor directory. This is the import path of the directory
+// containing the file, followed by the file name.
+func vendorPath(p *build.Instance, filename string) (string, bool) {
+	for _, sub := range []string{"gen", "pkg", "usr"} {
+		if rel, ok := relPath(filepath.Join(p.Root, "cue.mod", sub), filename); ok {
+			return rel, true
+		}
+	}
+	if rel, ok := relPath(p.Root, filename); ok && p.Module != "" {
+		return path.Join(p.Module, rel), true
+	}
+	return "", false
+}
+
+// relPath returns the slash-separated path of filename relative to dir if
+// filename is within dir.
+func relPath(dir, filename string) (string, bool) {
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
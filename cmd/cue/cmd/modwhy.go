@@ -0,0 +1,101 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+func newModWhyCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "why <importpath> ...",
+		Short: "explain why packages are needed",
+		Long: `Why shows the shortest chain of imports from a package of the
+current module to each of the given packages.
+
+For each package, the output starts with a line holding the package
+path prefixed with "#", followed by the import chain, one package per
+line. If the package is not imported by the current module, this is
+noted instead. Test and tool files are included.
+`,
+		RunE: mkRunE(c, runModWhy),
+	}
+	return cmd
+}
+
+func runModWhy(cmd *Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Newf(token.NoPos, "no packages specified")
+	}
+
+	g, err := loadImportGraph(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	for i, target := range args {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "# %s\n", target)
+		path := g.shortestPath(target)
+		if path == nil {
+			fmt.Fprintf(w, "(main module does not need package %s)\n", target)
+			continue
+		}
+		for _, p := range path {
+			fmt.Fprintln(w, p)
+		}
+	}
+	return nil
+}
+
+// shortestPath returns the shortest import chain from one of the roots of g
+// to the given package, or nil if there is no such chain. Ties are broken by
+// preferring the chain that sorts first.
+func (g *importGraph) shortestPath(target string) []string {
+	parent := map[string]string{}
+	queue := []string{}
+	for _, r := range g.roots {
+		if _, ok := parent[r]; !ok {
+			parent[r] = ""
+			queue = append(queue, r)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == target || importPathDir(p) == target {
+			var path []string
+			for ; p != ""; p = parent[p] {
+				path = append([]string{p}, path...)
+			}
+			return path
+		}
+		for _, dep := range g.imports[p] {
+			if _, ok := parent[dep]; !ok {
+				parent[dep] = p
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
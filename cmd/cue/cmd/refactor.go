@@ -0,0 +1,186 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/tools/refactor"
+)
+
+func newRefactorCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "refactor <cmd> [arguments]",
+		Short: "semantic transformations of packages",
+		Long: `Refactor groups commands that transform packages while preserving
+their meaning, updating all references in the packages that use them.
+`,
+		RunE: mkRunE(c, func(cmd *Command, args []string) error {
+			stderr := cmd.Stderr()
+			if len(args) == 0 {
+				fmt.Fprintln(stderr, "refactor must be run as one of its subcommands")
+			} else {
+				fmt.Fprintf(stderr, "refactor must be run as one of its subcommands: unknown subcommand %q\n", args[0])
+			}
+			fmt.Fprintln(stderr, "Run 'cue help refactor' for known subcommands.")
+			return ErrPrintedError
+		}),
+	}
+
+	cmd.AddCommand(newRefactorRenameCmd(c))
+	return cmd
+}
+
+func newRefactorRenameCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rename <package>[.<path>] <newname> [packages]",
+		Short: "rename a field, definition or package",
+		Long: `Rename renames a field, definition or package and updates all
+references to it.
+
+The first argument is the package, optionally followed by a dot and the
+path of the field or definition to rename within the package. Only the
+last element of the path is renamed. A path without a package refers to
+the package in the current directory. Without a path, the package itself
+is renamed, in which case the import paths of the importing packages are
+qualified with the new name, as in "example.com/cfg:config", unless the
+new name matches the last element of the import path.
+
+Renaming a field also renames the fields that are unified with it, such
+as the fields of values that embed or unify with a definition containing
+it, as well as all identifiers and selectors referring to these fields.
+
+References are updated in the given packages, which default to the
+packages in the current directory and its subdirectories. All packages
+must evaluate without errors.
+
+With --dryrun, rename prints the changes to each file as a diff instead
+of writing the files.
+
+Examples:
+
+  # Rename the definition #Server in the current package.
+  $ cue refactor rename '#Server' '#Service'
+
+  # Rename a field of a definition of a package in a module.
+  $ cue refactor rename 'example.com/cfg.#Config.old' replicas
+
+  # Rename a package and update the packages importing it.
+  $ cue refactor rename ./cfg config
+`,
+		RunE: mkRunE(c, runRefactorRename),
+	}
+
+	cmd.Flags().BoolP(string(flagDryrun), "n", false,
+		"print the changes as a diff instead of writing files")
+
+	return cmd
+}
+
+func runRefactorRename(cmd *Command, args []string) error {
+	if len(args) < 2 {
+		return errors.Newf(token.NoPos, "rename requires a package or field and a new name")
+	}
+	pkg, path := splitPackagePath(args[0])
+	name := args[1]
+	scope := args[2:]
+	if len(scope) == 0 {
+		scope = []string{"./..."}
+	}
+
+	p := cue.MakePath()
+	if path != "" {
+		p = cue.ParsePath(path)
+		if err := p.Err(); err != nil {
+			return err
+		}
+	}
+
+	cfg := &load.Config{Tests: true, Tools: true}
+	target := load.Instances([]string{pkg}, cfg)[0]
+	exitOnErr(cmd, target.Err, true)
+	insts := load.Instances(scope, cfg)
+
+	// Keep the original contents for diffs.
+	orig := map[string][]byte{}
+	if flagDryrun.Bool(cmd) {
+		for _, f := range target.Files {
+			orig[f.Filename], _ = ioutil.ReadFile(f.Filename)
+		}
+		for _, inst := range insts {
+			for _, f := range inst.Files {
+				orig[f.Filename], _ = ioutil.ReadFile(f.Filename)
+			}
+		}
+	}
+
+	files, err := refactor.Rename(target, p, name, insts)
+	exitOnErr(cmd, err, true)
+
+	cwd, _ := os.Getwd()
+	w := cmd.OutOrStdout()
+	for _, f := range files {
+		b, err := format.Node(f)
+		if err != nil {
+			return err
+		}
+		if !flagDryrun.Bool(cmd) {
+			if err := ioutil.WriteFile(f.Filename, b, 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		filename := f.Filename
+		if rel, err := filepath.Rel(cwd, filename); err == nil {
+			filename = filepath.ToSlash(rel)
+		}
+		fmt.Fprintf(w, "--- %s\n+++ %s\n", filename, filename)
+		fmt.Fprintln(w, diff.Diff(
+			strings.TrimSuffix(string(orig[f.Filename]), "\n"),
+			strings.TrimSuffix(string(b), "\n")))
+	}
+	return nil
+}
+
+// splitPackagePath splits an argument of the form <package>.<path> into the
+// package and the path. The path starts at the first dot after the last
+// slash. An argument without a slash other than "." is a path in the package
+// in the current directory.
+func splitPackagePath(arg string) (pkg, path string) {
+	i := strings.LastIndexByte(arg, '/')
+	if i < 0 {
+		if arg == "." {
+			return ".", ""
+		}
+		return ".", arg
+	}
+	j := strings.IndexByte(arg[i+1:], '.')
+	if j < 0 {
+		return arg, ""
+	}
+	return arg[:i+1+j], arg[i+2+j:]
+}
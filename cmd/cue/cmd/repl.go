@@ -0,0 +1,418 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/scanner"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+	"cuelang.org/go/internal/lineedit"
+)
+
+const replDoc = `repl evaluates expressions interactively
+
+The repl command loads and evaluates a package once and then reads
+expressions and commands from standard input. Expressions are evaluated
+within the current path of the package, which is initially its root,
+and printed like with cue eval. References resolve to the fields of the
+current value and of the values enclosing it. An expression that spans
+multiple lines is continued until its brackets are balanced.
+
+Commands:
+
+  :def [expr]     print the value of expr, or the current value, in
+                  definition form, like cue def
+  :export [expr]  export the value of expr, or the current value, like
+                  cue export in the format given by --out
+  :type [expr]    print the kind of the value of expr
+  :ls [expr]      list the fields of the value of expr
+  :cd [path]      change the current path; '..' moves up one level and
+                  a path starting with '/' or no path is absolute
+  :pwd            print the current path, starting with '/'
+  :reload         reload the package, retaining the current path
+  :help           print this list of commands
+  :quit           exit the repl
+
+When reading from a terminal, the repl supports line editing with the
+usual Emacs-style key bindings, a history of the session and completion
+of commands and field names with the tab key.
+
+Examples:
+
+  $ cue repl ./frontend
+  > :cd spec.containers[0]
+  spec.containers[0]> image
+  "nginx:1.19"
+`
+
+func newReplCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repl [inputs]",
+		Short: "evaluate expressions interactively",
+		Long:  replDoc,
+		RunE:  mkRunE(c, runRepl),
+	}
+
+	cmd.Flags().String(string(flagOut), "json",
+		`output format of :export (run 'cue filetypes' for more info)`)
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runRepl(cmd *Command, args []string) error {
+	export, err := filetypes.ParseFile(flagOut.String(cmd)+":-", filetypes.Export)
+	exitOnErr(cmd, err, true)
+
+	r := &repl{
+		cmd:    cmd,
+		args:   args,
+		w:      cmd.OutOrStdout(),
+		export: export,
+	}
+	inst, err := r.load()
+	if inst == nil {
+		exitOnErr(cmd, err, true)
+	}
+	r.inst = inst
+	r.printError(err)
+
+	r.editor = lineedit.New(cmd.InOrStdin(), r.w)
+	r.editor.Complete = r.complete
+	return r.run()
+}
+
+// A repl holds the state of an interactive session.
+type repl struct {
+	cmd    *Command
+	args   []string
+	w      io.Writer
+	editor *lineedit.Editor
+	export *build.File
+
+	inst *cue.Instance
+	path []cue.Selector // current path
+}
+
+// load loads the package. It returns an instance along with any validation
+// error, or nil if the package could not be loaded.
+func (r *repl) load() (*cue.Instance, error) {
+	b, err := parseArgs(r.cmd, r.args, &config{outMode: filetypes.Eval})
+	if err != nil {
+		return nil, err
+	}
+	if len(b.insts) != 1 || len(b.orphaned) > 0 {
+		return nil, errors.Newf(token.NoPos, "repl requires a single package")
+	}
+	inst := cue.Build(b.insts)[0]
+	if inst.Err != nil {
+		return nil, inst.Err
+	}
+	return inst, inst.Value().Validate()
+}
+
+func (r *repl) run() error {
+	for {
+		src, err := r.readInput()
+		switch err {
+		case nil:
+		case lineedit.ErrInterrupt:
+			continue
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+		r.editor.AddHistory(strings.Replace(src, "\n", " ", -1))
+
+		src = strings.TrimSpace(src)
+		if src == "" || strings.HasPrefix(src, "//") {
+			continue
+		}
+		if !strings.HasPrefix(src, ":") {
+			r.printValue(filetypes.Eval, src)
+			continue
+		}
+		name, arg := src, ""
+		if i := strings.IndexAny(src, " \t"); i >= 0 {
+			name, arg = src[:i], strings.TrimSpace(src[i+1:])
+		}
+		switch name {
+		case ":q", ":quit", ":exit":
+			return nil
+		case ":h", ":help":
+			r.help()
+		case ":def":
+			r.printValue(filetypes.Def, arg)
+		case ":export":
+			r.printValue(filetypes.Export, arg)
+		case ":type":
+			r.printType(arg)
+		case ":ls":
+			r.list(arg)
+		case ":cd":
+			r.cd(arg)
+		case ":pwd":
+			fmt.Fprintf(r.w, "/%s\n", r.pathString())
+		case ":reload":
+			r.reload()
+		default:
+			fmt.Fprintf(r.cmd.OutOrStderr(),
+				"unknown command %s; type :help for a list of commands\n", name)
+		}
+	}
+}
+
+// readInput reads lines until they form an input with balanced brackets.
+func (r *repl) readInput() (string, error) {
+	prompt := r.pathString() + "> "
+	if len(r.path) == 0 {
+		prompt = "> "
+	}
+	var lines []string
+	for {
+		line, err := r.editor.ReadLine(prompt)
+		if err != nil {
+			if err == io.EOF && len(lines) > 0 {
+				break
+			}
+			return "", err
+		}
+		lines = append(lines, line)
+		if !unbalanced(strings.Join(lines, "\n")) {
+			break
+		}
+		prompt = "... "
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// unbalanced reports whether src has unclosed brackets.
+func unbalanced(src string) bool {
+	var s scanner.Scanner
+	f := token.NewFile("", 0, len(src))
+	s.Init(f, []byte(src), nil, 0)
+	depth := 0
+	for {
+		_, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF:
+			return depth > 0
+		case token.LPAREN, token.LBRACE, token.LBRACK:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACK:
+			depth--
+		}
+	}
+}
+
+// current returns the value at the current path.
+func (r *repl) current() cue.Value {
+	return r.inst.Value().LookupPath(cue.MakePath(r.path...))
+}
+
+// eval evaluates src within the current path. An empty src evaluates to the
+// current value.
+func (r *repl) eval(src string) (cue.Value, error) {
+	if src == "" {
+		return r.current(), nil
+	}
+	expr, err := parser.ParseExpr("<input>", src)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	var v cue.Value
+	if len(r.path) == 0 {
+		v = r.inst.Eval(expr)
+	} else {
+		v = internal.EvalExpr(r.current(), expr).(cue.Value)
+	}
+	return v, v.Err()
+}
+
+func (r *repl) printValue(mode filetypes.Mode, src string) {
+	v, err := r.eval(src)
+	if err != nil {
+		r.printError(err)
+		return
+	}
+	file := &build.File{Filename: "-", Encoding: build.CUE}
+	if mode == filetypes.Export {
+		file = r.export
+	}
+	enc, err := encoding.NewEncoder(file, &encoding.Config{
+		Mode: mode,
+		Out:  r.w,
+	})
+	if err == nil {
+		err = enc.Encode(v)
+		if cerr := enc.Close(); err == nil {
+			err = cerr
+		}
+	}
+	r.printError(err)
+}
+
+func (r *repl) printType(src string) {
+	v, err := r.eval(src)
+	if err != nil {
+		r.printError(err)
+		return
+	}
+	fmt.Fprintln(r.w, v.IncompleteKind())
+}
+
+func (r *repl) list(src string) {
+	v, err := r.eval(src)
+	if err != nil {
+		r.printError(err)
+		return
+	}
+	iter, err := v.Fields(cue.Optional(true), cue.Definitions(true))
+	if err != nil {
+		r.printError(err)
+		return
+	}
+	for iter.Next() {
+		label := iter.Label()
+		if iter.IsOptional() {
+			label += "?"
+		}
+		fmt.Fprintf(r.w, "%s: %v\n", label, iter.Value().IncompleteKind())
+	}
+}
+
+func (r *repl) cd(arg string) {
+	path := r.path
+	switch {
+	case arg == "" || arg == "/":
+		path = nil
+	case arg == "..":
+		if len(path) > 0 {
+			path = path[:len(path)-1]
+		}
+	default:
+		if strings.HasPrefix(arg, "/") {
+			path, arg = nil, arg[1:]
+		}
+		p := cue.ParsePath(arg)
+		if err := p.Err(); err != nil {
+			r.printError(err)
+			return
+		}
+		path = append(path[:len(path):len(path)], p.Selectors()...)
+	}
+	v := r.inst.Value().LookupPath(cue.MakePath(path...))
+	if !v.Exists() {
+		fmt.Fprintf(r.cmd.OutOrStderr(), "%s: path not found\n", cue.MakePath(path...))
+		return
+	}
+	r.path = path
+}
+
+func (r *repl) pathString() string {
+	return cue.MakePath(r.path...).String()
+}
+
+func (r *repl) reload() {
+	inst, err := r.load()
+	if inst == nil {
+		r.printError(err)
+		return
+	}
+	r.inst = inst
+	r.printError(err)
+	if !r.current().Exists() {
+		fmt.Fprintf(r.cmd.OutOrStderr(),
+			"%s: path no longer exists; moved to the root\n", r.pathString())
+		r.path = nil
+	}
+}
+
+func (r *repl) help() {
+	const start = "Commands:\n\n"
+	doc := replDoc[strings.Index(replDoc, start)+len(start):]
+	doc = doc[:strings.Index(doc, "\n\n")+1]
+	fmt.Fprint(r.w, doc)
+}
+
+func (r *repl) printError(err error) {
+	if err != nil {
+		printError(r.cmd.OutOrStderr(), err)
+	}
+}
+
+var replCommands = []string{
+	":cd", ":def", ":export", ":help", ":ls", ":pwd", ":quit", ":reload", ":type",
+}
+
+// complete proposes commands and the labels of fields for the selector
+// expression ending the line.
+func (r *repl) complete(line string) (start int, candidates []string) {
+	if strings.HasPrefix(line, ":") && !strings.ContainsAny(line, " \t") {
+		for _, c := range replCommands {
+			if strings.HasPrefix(c, line) {
+				candidates = append(candidates, c)
+			}
+		}
+		return 0, candidates
+	}
+
+	start = len(line)
+	for start > 0 && (isIdentByte(line[start-1]) || strings.IndexByte(".[]", line[start-1]) >= 0) {
+		start--
+	}
+	word := line[start:]
+	v := r.current()
+	prefix, partial := "", word
+	if i := strings.LastIndexByte(word, '.'); i >= 0 {
+		prefix, partial = word[:i+1], word[i+1:]
+		var err error
+		if v, err = r.eval(word[:i]); err != nil {
+			return start, nil
+		}
+	}
+	iter, err := v.Fields(cue.Optional(true), cue.Definitions(true))
+	if err != nil {
+		return start, nil
+	}
+	for iter.Next() {
+		if strings.HasPrefix(iter.Label(), partial) {
+			candidates = append(candidates, prefix+iter.Label())
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '#' || c == '$' || c >= 0x80
+}
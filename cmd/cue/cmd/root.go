@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/token"
 )
@@ -43,6 +45,17 @@ type runFunction func(cmd *Command, args []string) error
 func mkRunE(c *Command, f runFunction) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
 		c.Command = cmd
+		switch format := flagErrorsFormat.String(c); format {
+		case "text", "json", "sarif":
+		default:
+			exitOnErr(c, errors.Newf(token.NoPos,
+				"invalid --%s %q; must be text, json or sarif", flagErrorsFormat, format), true)
+		}
+		defer startProfiling(c)()
+
+		if flagWatch.Bool(c) {
+			return runWatch(c, args, f)
+		}
 		err := f(c, args)
 		if err != nil {
 			exitOnErr(c, err, true)
@@ -88,15 +101,26 @@ For more information on writing CUE configuration files see cuelang.org.`,
 
 	subCommands := []*cobra.Command{
 		cmdCmd,
+		newCompatCmd(c),
 		newCompletionCmd(c),
 		newEvalCmd(c),
 		newDefCmd(c),
+		newDiffCmd(c),
+		newDocCmd(c),
+		newExplainCmd(c),
 		newExportCmd(c),
 		newFixCmd(c),
 		newFmtCmd(c),
 		newGetCmd(c),
+		newGraphCmd(c),
 		newImportCmd(c),
+		newLintCmd(c),
+		newLspCmd(c),
 		newModCmd(c),
+		newRefactorCmd(c),
+		newReplCmd(c),
+		newServeCmd(c),
+		newTestCmd(c),
 		newTrimCmd(c),
 		newVersionCmd(c),
 		newVetCmd(c),
@@ -155,6 +179,21 @@ type Command struct {
 	cmd *cobra.Command
 
 	hasErr bool
+
+	// loaded holds the instances loaded by the current run of the command,
+	// which are used to determine the files to watch with --watch.
+	loaded []*build.Instance
+
+	// overwrite is set by --watch for runs after a successful run, so that
+	// they replace the output files written by that run.
+	overwrite bool
+
+	// stats collects the statistics reported at the end of the command if
+	// --stats is set.
+	stats *evalStats
+
+	// errs holds the errors to report in SARIF format at the end of Run.
+	errs []error
 }
 
 type errWriter Command
@@ -201,9 +240,10 @@ func (c *Command) Run(ctx context.Context) (err error) {
 	// - user defined
 	// - help
 	// For the latter two, we need to use the default loading.
+	defer c.reportSARIF()
 	defer recoverError(&err)
 
-	if err := c.root.Execute(); err != nil {
+	if err := c.root.ExecuteContext(ctx); err != nil {
 		return err
 	}
 	if c.hasErr {
@@ -212,6 +252,18 @@ func (c *Command) Run(ctx context.Context) (err error) {
 	return nil
 }
 
+// reportSARIF writes a SARIF log with the errors reported during Run, if
+// --errors-format=sarif. The log is written even if there were no errors,
+// so that it can always be passed on to other tools.
+func (c *Command) reportSARIF() {
+	if flagErrorsFormat.String(c) != "sarif" {
+		return
+	}
+	w := &bytes.Buffer{}
+	_ = errors.PrintSARIF(w, c.errs, errorsConfig())
+	_, _ = c.Stderr().Write(w.Bytes())
+}
+
 func recoverError(err *error) {
 	switch e := recover().(type) {
 	case nil:
@@ -0,0 +1,249 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+)
+
+const serveDoc = `serve serves evaluated configurations over HTTP
+
+The configurations are loaded and evaluated once, when the server
+starts. A GET request for a URL path returns the value at the
+corresponding path within the configuration, exported like with
+cue export. For instance, /spec/containers/0 selects the first
+element of the containers list in the field spec. A request for /
+returns the entire configuration.
+
+If more than one package is served, each package is served under the
+path of its directory relative to the current directory.
+
+The output format is determined, in order of precedence, by the out
+query parameter, by an Accept header for YAML, and by the --out flag.
+The default is JSON.
+
+Examples:
+
+  $ cue serve --addr localhost:8080 ./...
+  $ curl localhost:8080/frontend/spec?out=yaml
+`
+
+func newServeCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve [inputs]",
+		Short: "serve evaluated configurations over HTTP",
+		Long:  serveDoc,
+		RunE:  mkRunE(c, runServe),
+	}
+
+	cmd.Flags().String(string(flagAddr), "localhost:8080",
+		"address on which to listen")
+	cmd.Flags().String(string(flagOut), "",
+		`default output format (run 'cue filetypes' for more info)`)
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runServe(cmd *Command, args []string) error {
+	b, err := parseArgs(cmd, args, &config{outMode: filetypes.Export})
+	exitOnErr(cmd, err, true)
+
+	h := &configHandler{
+		file:   b.outFile,
+		config: b.encConfig,
+	}
+
+	var ids []string
+	var values []cue.Value
+	iter := b.instances()
+	defer iter.close()
+	for iter.scan() {
+		ids = append(ids, iter.id())
+		values = append(values, iter.value())
+	}
+	exitOnErr(cmd, iter.err(), true)
+
+	cwd, _ := os.Getwd()
+	for i, v := range values {
+		var prefix string
+		if len(values) > 1 {
+			rel, err := filepath.Rel(cwd, ids[i])
+			if err != nil {
+				rel = ids[i]
+			}
+			if prefix = filepath.ToSlash(rel); prefix == "." {
+				prefix = ""
+			}
+		}
+		if err := h.mount(prefix, v); err != nil {
+			exitOnErr(cmd, err, true)
+		}
+	}
+
+	ln, err := net.Listen("tcp", flagAddr.String(cmd))
+	exitOnErr(cmd, err, true)
+	fmt.Fprintf(cmd.OutOrStdout(), "serving on http://%s\n", ln.Addr())
+
+	return http.Serve(ln, h)
+}
+
+// A configHandler serves values at the paths at which they are mounted.
+type configHandler struct {
+	file   *build.File
+	config *encoding.Config
+
+	mu     sync.Mutex // evaluation is not safe for concurrent use
+	mounts []mount
+}
+
+type mount struct {
+	prefix string
+	value  cue.Value
+}
+
+func (h *configHandler) mount(prefix string, v cue.Value) error {
+	for _, m := range h.mounts {
+		if m.prefix == prefix {
+			return errors.Newf(token.NoPos,
+				"multiple configurations to serve at /%s", prefix)
+		}
+	}
+	h.mounts = append(h.mounts, mount{prefix, v})
+	// Match longer prefixes first.
+	sort.SliceStable(h.mounts, func(i, j int) bool {
+		return len(h.mounts[i].prefix) > len(h.mounts[j].prefix)
+	})
+	return nil
+}
+
+func (h *configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := h.file
+	if out := r.URL.Query().Get("out"); out != "" {
+		var err error
+		file, err = filetypes.ParseFile(out+":-", filetypes.Export)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if strings.Contains(r.Header.Get("Accept"), "yaml") {
+		file = &build.File{Filename: "-", Encoding: build.YAML}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, err := h.lookup(r.URL.EscapedPath())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	cfg := *h.config
+	cfg.Out = &buf
+	enc, err := encoding.NewEncoder(file, &cfg)
+	if err == nil {
+		err = enc.Encode(v)
+		if cerr := enc.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		http.Error(w, errors.Details(err, nil), http.StatusInternalServerError)
+		return
+	}
+
+	switch file.Encoding {
+	case build.JSON:
+		w.Header().Set("Content-Type", "application/json")
+	case build.YAML:
+		w.Header().Set("Content-Type", "application/yaml")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// lookup returns the value for the given escaped URL path.
+func (h *configHandler) lookup(path string) (cue.Value, error) {
+	path = strings.Trim(path, "/")
+	for _, m := range h.mounts {
+		rest := path
+		switch {
+		case m.prefix == "":
+		case path == m.prefix:
+			rest = ""
+		case strings.HasPrefix(path, m.prefix+"/"):
+			rest = path[len(m.prefix)+1:]
+		default:
+			continue
+		}
+
+		v := m.value
+		if rest == "" {
+			return v, nil
+		}
+		for _, elem := range strings.Split(rest, "/") {
+			// Escaped slashes may be used for labels containing slashes.
+			elem, err := url.PathUnescape(elem)
+			if err != nil {
+				return v, err
+			}
+			notFound := errors.Newf(token.NoPos, "/%s: %q not found", path, elem)
+			sel := cue.Str(elem)
+			if i, err := strconv.Atoi(elem); err == nil && v.IncompleteKind() == cue.ListKind {
+				if i < 0 || int64(i) > adt.MaxIndex {
+					return v, notFound
+				}
+				sel = cue.Index(i)
+			}
+			v = v.LookupPath(cue.MakePath(sel))
+			if !v.Exists() {
+				return v, notFound
+			}
+		}
+		return v, nil
+	}
+	return cue.Value{}, errors.Newf(token.NoPos, "/%s not found", path)
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+)
+
+func TestServeConfig(t *testing.T) {
+	h := &configHandler{
+		file:   &build.File{Filename: "-", Encoding: build.JSON},
+		config: &encoding.Config{Mode: filetypes.Export},
+	}
+	for prefix, src := range map[string]string{
+		"": `
+			spec: containers: [{name: "web", port: 80}]
+			open: [1, ...int]
+			"a/b": 1
+			`,
+		"sub/pkg": `
+			x: int
+			y: x + 1
+			`,
+	} {
+		var r cue.Runtime
+		inst, err := r.Compile(prefix, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.mount(prefix, inst.Value()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.mount("", cue.Value{}); err == nil {
+		t.Error("mounting twice at the same prefix succeeded")
+	}
+
+	testCases := []struct {
+		method string
+		url    string
+		accept string
+		status int
+		ctype  string
+		out    string
+	}{{
+		url:    "/spec/containers/0",
+		status: 200,
+		ctype:  "application/json",
+		out:    `{"name":"web","port":80}`,
+	}, {
+		url:    "/spec/containers/0?out=yaml",
+		status: 200,
+		ctype:  "application/yaml",
+		out:    "name: web\nport: 80",
+	}, {
+		url:    "/spec/containers/0/port",
+		accept: "application/yaml",
+		status: 200,
+		ctype:  "application/yaml",
+		out:    "80",
+	}, {
+		url:    "/a%2Fb",
+		status: 200,
+		out:    "1",
+	}, {
+		url:    "/spec/volumes",
+		status: 404,
+		out:    `"volumes" not found`,
+	}, {
+		url:    "/spec/containers/-1",
+		status: 404,
+		out:    `"-1" not found`,
+	}, {
+		url:    "/spec/containers/1",
+		status: 404,
+		out:    `"1" not found`,
+	}, {
+		url:    "/open/3000000000",
+		status: 404,
+		out:    `"3000000000" not found`,
+	}, {
+		url:    "/open/0",
+		status: 200,
+		out:    "1",
+	}, {
+		url:    "/open/1",
+		status: 404,
+		out:    `"1" not found`,
+	}, {
+		url:    "/sub/pkg/y",
+		status: 500,
+		out:    "non-concrete value",
+	}, {
+		url:    "/spec?out=foo",
+		status: 400,
+	}, {
+		method: "POST",
+		url:    "/spec",
+		status: 405,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tc.url, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("got status %d; want %d", w.Code, tc.status)
+			}
+			if got := w.Header().Get("Content-Type"); tc.ctype != "" && got != tc.ctype {
+				t.Errorf("got Content-Type %q; want %q", got, tc.ctype)
+			}
+			got := strings.Join(strings.Fields(w.Body.String()), "")
+			if want := strings.Join(strings.Fields(tc.out), ""); !strings.Contains(got, want) {
+				t.Errorf("got body %q; want %q", w.Body.String(), tc.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	goruntime "runtime"
+	"runtime/pprof"
+	"text/tabwriter"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/eval"
+)
+
+// maxStatsFields is the maximum number of top-level fields reported per
+// package by --stats.
+const maxStatsFields = 10
+
+// evalStats collects the statistics reported by --stats.
+type evalStats struct {
+	start time.Time
+
+	// Time spent in each phase. The export phase is the remaining time.
+	load     time.Duration
+	compile  time.Duration
+	evaluate time.Duration
+
+	total eval.Cost
+	pkgs  []pkgStats
+}
+
+type pkgStats struct {
+	name string
+	eval.Cost
+	fields []eval.FieldCost
+}
+
+// addTime adds the time since start to d.
+func (s *evalStats) addTime(d *time.Duration, start time.Time) {
+	*d += time.Since(start)
+}
+
+// profile evaluates the given instances, recording the cost of evaluating
+// each of them and their top-level fields.
+func (s *evalStats) profile(binst []*build.Instance, insts []*cue.Instance) {
+	for i, inst := range insts {
+		r, v := internal.CoreInstance(inst)
+		total, fields := eval.Profile(r.(adt.Runtime), v.(*adt.Vertex))
+
+		name := binst[i].ImportPath
+		if !importable(binst[i]) {
+			// Packages with the same name are told apart by their directory.
+			name = binst[i].DisplayPath
+		}
+		s.total.Add(total)
+		s.pkgs = append(s.pkgs, pkgStats{name: name, Cost: total, fields: fields})
+	}
+}
+
+func (s *evalStats) write(w io.Writer) {
+	total := time.Since(s.start)
+	export := total - s.load - s.compile - s.evaluate
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Phases:")
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"load", s.load},
+		{"compile", s.compile},
+		{"evaluate", s.evaluate},
+		{"export", export},
+		{"total", total},
+	} {
+		fmt.Fprintf(tw, "  %s\t%s\n", p.name, round(p.d))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nEvaluation:")
+	fmt.Fprintf(tw, "  unifications\t%d\n", s.total.UnifyCount)
+	fmt.Fprintf(tw, "  disjuncts\t%d\n", s.total.DisjunctCount)
+	fmt.Fprintf(tw, "  allocs\t%d\n", s.total.Allocs)
+	fmt.Fprintf(tw, "  reused\t%d\n", s.total.Reused)
+	fmt.Fprintf(tw, "  freed\t%d\n", s.total.Freed)
+	fmt.Fprintf(tw, "  retained\t%d\n", s.total.Retained)
+	fmt.Fprintf(tw, "  leaks\t%d\n", s.total.Leaks())
+	tw.Flush()
+
+	if len(s.pkgs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nCost per package and top-level field:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  package/field\tunifications\tdisjuncts\ttime")
+	for _, p := range s.pkgs {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\n",
+			p.name, p.UnifyCount, p.DisjunctCount, round(p.Duration))
+		fields := p.fields
+		if len(fields) > maxStatsFields {
+			fields = fields[:maxStatsFields]
+		}
+		for _, f := range fields {
+			fmt.Fprintf(tw, "    %s\t%d\t%d\t%s\n",
+				f.Label, f.UnifyCount, f.DisjunctCount, round(f.Duration))
+		}
+		if rest := p.fields[len(fields):]; len(rest) > 0 {
+			var c eval.Cost
+			for _, f := range rest {
+				c.Add(f.Cost)
+			}
+			fmt.Fprintf(tw, "    (%d more fields)\t%d\t%d\t%s\n",
+				len(rest), c.UnifyCount, c.DisjunctCount, round(c.Duration))
+		}
+	}
+	tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// startProfiling starts the profiling requested by the --stats, --cpuprofile
+// and --memprofile flags and returns a function that finishes it.
+func startProfiling(c *Command) (stop func()) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if file := flagCPUProfile.String(c); file != "" {
+		f, err := os.Create(file)
+		exitOnErr(c, err, true)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			exitOnErr(c, err, true)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			exitOnErr(c, f.Close(), false)
+		})
+	}
+
+	if file := flagMemProfile.String(c); file != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(file)
+			if err != nil {
+				exitOnErr(c, err, false)
+				return
+			}
+			goruntime.GC() // get up-to-date statistics
+			exitOnErr(c, pprof.WriteHeapProfile(f), false)
+			exitOnErr(c, f.Close(), false)
+		})
+	}
+
+	if flagStats.Bool(c) {
+		c.stats = &evalStats{start: time.Now()}
+		stops = append(stops, func() {
+			c.stats.write(c.OutOrStderr())
+			c.stats = nil
+		})
+	}
+	return stop
+}
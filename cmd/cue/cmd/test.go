@@ -0,0 +1,362 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+)
+
+const testDoc = `test runs the tests defined in the _test.cue files of packages
+
+Test files are files with a name ending in _test.cue. They are part of
+the package in their directory, but are only loaded by cue test, so
+they may refer to any value defined in the package. Test cases are
+declared as fields of the top-level field test, each of which is a
+struct with the following fields:
+
+  value     the value under test (required).
+  error     a regular expression. If set, the value must fail to
+            validate with an error message matching the expression.
+  concrete  if true, the value must be concrete to validate.
+  golden    the name of a file, relative to the directory of the
+            package, to which the exported value must be equal. The
+            format of the file is determined by its extension.
+
+A test case without an error field passes if its value validates
+and, if golden is set, exports to the contents of the golden file.
+The --update flag writes the exported values to the golden files
+instead of comparing them.
+
+For each package, cue test reports the result of each test case and
+the number of passed and failed cases. It exits with a non-zero
+status if any test fails.
+
+Example:
+
+  $ cat schema_test.cue
+  package server
+
+  test: validPort: value: #Server & {port: 8080}
+
+  test: negativePort: {
+      value: #Server & {port: -1}
+      error: "invalid value -1"
+  }
+
+  test: defaults: {
+      value:  #Server & {port: 80}
+      golden: "testdata/defaults.json"
+  }
+
+  $ cue test ./...
+`
+
+func newTestCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [inputs]",
+		Short: "run tests defined in _test.cue files",
+		Long:  testDoc,
+		RunE:  mkRunE(c, runTest),
+	}
+
+	cmd.Flags().String(string(flagRun), "",
+		"run only the tests matching the regular expression")
+	cmd.Flags().BoolP(string(flagUpdate), "u", false,
+		"update golden files")
+
+	cmd.Flags().StringArrayP(string(flagInject), "t", nil,
+		"set the value of a tagged field")
+
+	return cmd
+}
+
+func runTest(cmd *Command, args []string) error {
+	r := &testRunner{
+		w:      cmd.OutOrStdout(),
+		update: flagUpdate.Bool(cmd),
+	}
+	if s := flagRun.String(cmd); s != "" {
+		var err error
+		r.match, err = regexp.Compile(s)
+		exitOnErr(cmd, err, true)
+	}
+
+	loadCfg := *defaultConfig.loadCfg
+	loadCfg.Tests = true
+	cfg := &config{loadCfg: &loadCfg}
+	_, err := newBuildPlan(cmd, args, cfg)
+	exitOnErr(cmd, err, true)
+
+	binst := loadFromArgs(cmd, args, cfg.loadCfg)
+	if binst == nil {
+		exitOnErr(cmd, errors.Newf(token.NoPos, "invalid args"), true)
+	}
+	cmd.loaded = append(cmd.loaded, binst...)
+
+	// Only packages that loaded successfully and have tests are built, all
+	// at once. Errors are reported per package.
+	var toBuild []*build.Instance
+	for _, b := range binst {
+		if b.Err == nil && len(b.TestCUEFiles) > 0 {
+			toBuild = append(toBuild, b)
+		}
+	}
+	built := map[*build.Instance]*cue.Instance{}
+	if len(toBuild) > 0 {
+		for i, inst := range compileInstances(cmd, toBuild) {
+			built[toBuild[i]] = inst
+		}
+	}
+
+	for _, b := range binst {
+		r.runInstance(b, built[b])
+	}
+
+	if r.failed > 0 {
+		fmt.Fprintf(r.w, "FAIL: %d passed, %d failed\n", r.passed, r.failed)
+		exit()
+	}
+	fmt.Fprintf(r.w, "PASS: %d passed\n", r.passed)
+	return nil
+}
+
+// testFields are the fields allowed in a test case.
+var testFields = map[string]bool{
+	"value":    true,
+	"error":    true,
+	"concrete": true,
+	"golden":   true,
+}
+
+type testRunner struct {
+	w      io.Writer
+	match  *regexp.Regexp
+	update bool
+
+	passed int
+	failed int
+}
+
+// runInstance runs the tests of the package b, which was built as inst.
+// inst is nil if b has no tests or could not be loaded.
+func (r *testRunner) runInstance(b *build.Instance, inst *cue.Instance) {
+	name := b.ImportPath
+	if name == "" {
+		name = b.Dir
+	}
+	switch {
+	case b.Err != nil:
+		r.failed++
+		fmt.Fprintf(r.w, "FAIL\t%s\t[setup failed]\n", name)
+		r.printErr(b.Err)
+		return
+
+	case inst == nil:
+		fmt.Fprintf(r.w, "?   \t%s\t[no test files]\n", name)
+		return
+
+	case inst.Err != nil:
+		r.failed++
+		fmt.Fprintf(r.w, "FAIL\t%s\t[build failed]\n", name)
+		r.printErr(inst.Err)
+		return
+	}
+
+	passed, failed := 0, 0
+	iter, err := inst.Value().Lookup("test").Fields()
+	if err == nil {
+		for iter.Next() {
+			label := iter.Label()
+			if r.match != nil && !r.match.MatchString(label) {
+				continue
+			}
+			if err := r.runCase(b.Dir, iter.Value()); err != nil {
+				failed++
+				fmt.Fprintf(r.w, "--- FAIL: %s\n", label)
+				r.printErr(err)
+			} else {
+				passed++
+				fmt.Fprintf(r.w, "--- PASS: %s\n", label)
+			}
+		}
+	}
+	r.passed += passed
+	r.failed += failed
+
+	switch {
+	case passed+failed == 0:
+		fmt.Fprintf(r.w, "?   \t%s\t[no tests to run]\n", name)
+	case failed > 0:
+		fmt.Fprintf(r.w, "FAIL\t%s\t%d passed, %d failed\n", name, passed, failed)
+	default:
+		fmt.Fprintf(r.w, "ok  \t%s\t%d passed\n", name, passed)
+	}
+}
+
+// runCase runs a single test case and reports why it failed, if it did.
+func (r *testRunner) runCase(dir string, tc cue.Value) error {
+	iter, err := tc.Fields()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		if !testFields[iter.Label()] {
+			return errors.Newf(iter.Value().Pos(),
+				"unknown field %q in test", iter.Label())
+		}
+	}
+
+	value := tc.Lookup("value")
+	if !value.Exists() {
+		return errors.Newf(tc.Pos(), "test has no value field")
+	}
+
+	concrete := false
+	if v := tc.Lookup("concrete"); v.Exists() {
+		if concrete, err = v.Bool(); err != nil {
+			return err
+		}
+	}
+	verr := value.Validate(cue.Concrete(concrete))
+
+	if v := tc.Lookup("error"); v.Exists() {
+		pattern, err := v.String()
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Newf(v.Pos(), "invalid error pattern: %v", err)
+		}
+		if verr == nil {
+			return errors.Newf(value.Pos(),
+				"value is valid; want error matching %q", pattern)
+		}
+		for _, e := range errors.Errors(verr) {
+			if re.MatchString(e.Error()) {
+				return nil
+			}
+		}
+		return errors.Append(errors.Newf(token.NoPos,
+			"no error matching %q among:", pattern), errors.Promote(verr, ""))
+	}
+	if verr != nil {
+		return verr
+	}
+
+	if v := tc.Lookup("golden"); v.Exists() {
+		filename, err := v.String()
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filepath.FromSlash(filename))
+		}
+		return r.checkGolden(filename, value)
+	}
+	return nil
+}
+
+// checkGolden compares the exported value with the contents of the given
+// golden file, or updates the file if the --update flag is set.
+func (r *testRunner) checkGolden(filename string, v cue.Value) error {
+	f, err := filetypes.ParseFile(filename, filetypes.Export)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc, err := encoding.NewEncoder(f, &encoding.Config{
+		Mode: filetypes.Export,
+		Out:  &buf,
+	})
+	if err != nil {
+		return err
+	}
+	err = enc.Encode(v)
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	got := buf.Bytes()
+
+	if r.update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filename, got, 0644)
+	}
+
+	want, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return errors.Newf(token.NoPos,
+			"golden file %s does not exist; use --update to create it",
+			r.relPath(filename))
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		d := diff.Diff(
+			strings.TrimSuffix(string(want), "\n"),
+			strings.TrimSuffix(string(got), "\n"))
+		return errors.Newf(token.NoPos,
+			"output does not match golden file %s (-want +got):\n%s",
+			r.relPath(filename), d)
+	}
+	return nil
+}
+
+func (r *testRunner) relPath(filename string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, filename); err == nil {
+			filename = rel
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
+// printErr prints the details of an error, indented below the test result.
+func (r *testRunner) printErr(err error) {
+	cwd, _ := os.Getwd()
+	var buf bytes.Buffer
+	errors.Print(&buf, err, &errors.Config{
+		Cwd:     cwd,
+		ToSlash: inTest,
+	})
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" {
+			fmt.Fprintf(r.w, "    %s", line)
+		}
+	}
+}
@@ -59,6 +59,9 @@ Examples:
   cue vet translations/*.yaml foo.cue -d '#Translation'
 
 If more than one expression is given, all must match all values.
+
+With --watch, vet keeps running and validates the files again whenever any
+of them, or any of the CUE files they are validated against, changes.
 `
 
 func newVetCmd(c *Command) *cobra.Command {
@@ -70,6 +73,7 @@ func newVetCmd(c *Command) *cobra.Command {
 	}
 
 	addOrphanFlags(cmd.Flags())
+	addWatchFlag(cmd.Flags())
 
 	cmd.Flags().BoolP(string(flagConcrete), "c", false,
 		"require the evaluation to be concrete")
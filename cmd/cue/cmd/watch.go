@@ -0,0 +1,80 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/watch"
+)
+
+func addWatchFlag(f *pflag.FlagSet) {
+	f.Bool(string(flagWatch), false,
+		"run the command again when any of its input files changes")
+}
+
+// runWatch runs f, and runs it again each time any of the files loaded
+// during the previous run changes, until the context of cmd is done.
+func runWatch(cmd *Command, args []string, f runFunction) error {
+	ctx := cmd.Context()
+	for {
+		cmd.loaded = nil
+		hasErr := cmd.hasErr
+		cmd.hasErr = false
+		runOnce(cmd, args, f)
+		// Once a run succeeded, later runs replace the files it wrote.
+		cmd.overwrite = cmd.overwrite || !cmd.hasErr
+		cmd.hasErr = cmd.hasErr || hasErr
+
+		files := watch.Files(cmd.loaded)
+		if len(files) == 0 {
+			return errors.Newf(token.NoPos, "no files to watch")
+		}
+		changed, err := watch.Wait(ctx, files, nil)
+		if err != nil {
+			// The context was canceled.
+			return nil
+		}
+
+		cwd, _ := os.Getwd()
+		for i, file := range changed {
+			if rel, err := filepath.Rel(cwd, file); err == nil {
+				changed[i] = filepath.ToSlash(rel)
+			}
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "--- %s changed ---\n",
+			strings.Join(changed, ", "))
+	}
+}
+
+// runOnce runs f and reports its errors without exiting.
+func runOnce(cmd *Command, args []string, f runFunction) {
+	var err error
+	defer func() {
+		if err != nil && err != ErrPrintedError {
+			exitOnErr(cmd, err, false)
+		}
+	}()
+	defer recoverError(&err)
+
+	err = f(cmd, args)
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cue-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	file := filepath.Join(dir, "a.cue")
+	if err := ioutil.WriteFile(file, []byte("a: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New([]string{"export", "--watch", "a.cue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &syncBuffer{}
+	c.SetOutput(out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	waitFor := func(s string) {
+		t.Helper()
+		for start := time.Now(); !strings.Contains(out.String(), s); {
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("timeout waiting for %q; got:\n%s", s, out.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor(`"a": 1`)
+	// Change the size, as the modification time may not change on file
+	// systems with a coarse resolution.
+	if err := ioutil.WriteFile(file, []byte("a: 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(`"a": 10`)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+    "a": 1
+}
+--- a.cue changed ---
+{
+    "a": 10
+}
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWatchOutFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cue-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	file := filepath.Join(dir, "a.cue")
+	if err := ioutil.WriteFile(file, []byte("a: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(dir, "out.json")
+
+	c, err := New([]string{"export", "--watch", "-o", "out.json", "a.cue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &syncBuffer{}
+	c.SetOutput(out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	waitFor := func(s string) {
+		t.Helper()
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			b, _ := ioutil.ReadFile(outFile)
+			if strings.Contains(string(b), s) {
+				return
+			}
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("timeout waiting for %q; got:\n%s\noutput:\n%s", s, b, out.String())
+			}
+		}
+	}
+
+	waitFor(`"a": 1`)
+	if err := ioutil.WriteFile(file, []byte("a: 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(`"a": 10`)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "--- a.cue changed ---\n"; got != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", got, want)
+	}
+}
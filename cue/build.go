@@ -63,6 +63,11 @@ func init() {
 		}
 		return nil, nil
 	}
+
+	internal.CoreInstance = func(instance interface{}) (runtime, vertex interface{}) {
+		inst := instance.(*Instance)
+		return inst.index.Runtime, inst.root
+	}
 }
 
 func dummyLoad(token.Pos, string) *build.Instance { return nil }
@@ -0,0 +1,85 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cue
+
+import (
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/internal/core/subsume"
+)
+
+// Compatibility describes how a new version of a schema relates to an old
+// version.
+type Compatibility struct {
+	// Backward lists the paths at which the new version does not accept
+	// values accepted by the old version.
+	Backward []Break
+
+	// Forward lists the paths at which the old version does not accept
+	// values accepted by the new version.
+	Forward []Break
+}
+
+// IsBackward reports whether the new version accepts all values accepted
+// by the old version.
+func (c *Compatibility) IsBackward() bool { return len(c.Backward) == 0 }
+
+// IsForward reports whether the old version accepts all values accepted by
+// the new version.
+func (c *Compatibility) IsForward() bool { return len(c.Forward) == 0 }
+
+// A Break describes a path at which compatibility breaks.
+type Break struct {
+	// Err describes the break. Its path is relative to the compared values
+	// and its positions are those of the new version, if any, followed by
+	// those of the old version.
+	Err errors.Error
+
+	// Inexact reports whether compatibility could not be decided for the
+	// path, in which case the break may be spurious.
+	Inexact bool
+}
+
+// CheckCompatibility reports the compatibility of the new version next of a
+// schema with its old version prev. The values must be obtained from the
+// same Runtime.
+//
+// Unlike Subsume, CheckCompatibility reports all paths at which
+// compatibility breaks, including within disjunctions, and it compares
+// pattern constraints and the element types of open lists. Closedness is
+// taken into account, but default values are not.
+func CheckCompatibility(prev, next Value) *Compatibility {
+	ctx := next.ctx().opCtx
+	p := subsume.CUE
+	p.IgnoreDefaults = true
+	c := &Compatibility{}
+	for _, err := range errors.Errors(p.Mismatches(ctx, next.v, prev.v)) {
+		c.Backward = append(c.Backward, makeBreak(err, false))
+	}
+	for _, err := range errors.Errors(p.Mismatches(ctx, prev.v, next.v)) {
+		c.Forward = append(c.Forward, makeBreak(err, true))
+	}
+	return c
+}
+
+func makeBreak(err errors.Error, swap bool) Break {
+	m, ok := err.(*subsume.Mismatch)
+	if !ok {
+		return Break{Err: err}
+	}
+	if swap {
+		m.XPos, m.YPos = m.YPos, m.XPos
+	}
+	return Break{Err: m, Inexact: m.Inexact}
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A Diagnostic is a structured representation of an error for use by tools.
+type Diagnostic struct {
+	// Message is the error message, excluding its path and positions.
+	Message string `json:"message"`
+
+	// Path is the path into the data tree where the error occurred, if any.
+	Path []string `json:"path,omitempty"`
+
+	// Severity is the severity of the diagnostic: "error", unless the error
+	// reports otherwise (see Diagnostics).
+	Severity string `json:"severity"`
+
+	// Code identifies the kind of problem reported, such as the lint rule
+	// that reported it, if the error reports one.
+	Code string `json:"code,omitempty"`
+
+	// Positions holds the primary position of the error, if any, followed
+	// by its input positions, as reported by Positions.
+	Positions []Location `json:"positions,omitempty"`
+}
+
+// A Location is a position within a file.
+type Location struct {
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line"`   // 1-based
+	Column   int    `json:"column"` // 1-based, in bytes
+	Offset   int    `json:"offset"` // 0-based, in bytes
+}
+
+// Diagnostics returns a Diagnostic for each error in err, sanitized as with
+// Print. Filenames are made relative to cfg.Cwd, if set.
+//
+// An error may report the severity and code of its diagnostic by implementing
+// a Severity() string and a Code() string method, respectively.
+func Diagnostics(err error, cfg *Config) []Diagnostic {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if e, ok := err.(Error); ok {
+		err = Sanitize(e)
+	}
+	var a []Diagnostic
+	for _, e := range Errors(err) {
+		w := &strings.Builder{}
+		writeMsg(w, e)
+		d := Diagnostic{
+			Message:  w.String(),
+			Path:     e.Path(),
+			Severity: severity(e),
+			Code:     code(e),
+		}
+		for _, p := range Positions(e) {
+			pos := p.Position()
+			d.Positions = append(d.Positions, Location{
+				Filename: relFilename(cfg, pos.Filename),
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Offset:   pos.Offset,
+			})
+		}
+		a = append(a, d)
+	}
+	return a
+}
+
+func severity(err Error) string {
+	if s, ok := err.(interface{ Severity() string }); ok {
+		return s.Severity()
+	}
+	return "error"
+}
+
+func code(err Error) string {
+	if c, ok := err.(interface{ Code() string }); ok {
+		return c.Code()
+	}
+	return ""
+}
+
+func relFilename(cfg *Config, filename string) string {
+	if cfg.Cwd != "" && filepath.IsAbs(filename) {
+		if rel, err := filepath.Rel(cfg.Cwd, filename); err == nil {
+			filename = rel
+		}
+	}
+	if cfg.ToSlash {
+		filename = filepath.ToSlash(filename)
+	}
+	return filename
+}
+
+// PrintJSON writes the diagnostics for err to w as JSON, one diagnostic per
+// line. The output for multiple errors may therefore be concatenated.
+func PrintJSON(w io.Writer, err error, cfg *Config) error {
+	enc := json.NewEncoder(w)
+	for _, d := range Diagnostics(err, cfg) {
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintSARIF writes the diagnostics for all of errs to w as a single log in
+// the Static Analysis Results Interchange Format (SARIF) version 2.1.0.
+//
+// The first position of a diagnostic is reported as its location and the
+// others as related locations. Its path, if any, is reported as a logical
+// location.
+func PrintSARIF(w io.Writer, errs []error, cfg *Config) error {
+	results := []sarifResult{}
+	for _, err := range errs {
+		for _, d := range Diagnostics(err, cfg) {
+			r := sarifResult{
+				RuleID:  d.Code,
+				Level:   d.Severity,
+				Message: sarifMessage{Text: d.Message},
+			}
+			for i, loc := range d.Positions {
+				l := sarifLocation{PhysicalLocation: &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI: filepath.ToSlash(loc.Filename),
+					},
+					Region: sarifRegion{
+						StartLine:   loc.Line,
+						StartColumn: loc.Column,
+					},
+				}}
+				if i == 0 {
+					r.Locations = append(r.Locations, l)
+				} else {
+					r.RelatedLocations = append(r.RelatedLocations, l)
+				}
+			}
+			if len(d.Path) > 0 {
+				path := strings.Join(d.Path, ".")
+				if len(r.Locations) == 0 {
+					r.Locations = append(r.Locations, sarifLocation{})
+				}
+				r.Locations[0].LogicalLocations = []sarifLogicalLocation{{
+					FullyQualifiedName: path,
+				}}
+			}
+			results = append(results, r)
+		}
+	}
+
+	b, err := json.MarshalIndent(&sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "cue",
+				InformationURI: "https://cuelang.org",
+			}},
+			Results: results,
+		}},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// SARIF types, limited to the properties used by PrintSARIF.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
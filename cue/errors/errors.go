@@ -411,6 +411,11 @@ func (p *list) RemoveMultiples() {
 }
 
 func approximateEqual(a, b Error) bool {
+	// Diagnostics with different codes, such as findings of different lint
+	// rules, are never merged. Errors without a code are compared as usual.
+	if code(a) != code(b) {
+		return false
+	}
 	aPos := a.Position()
 	bPos := b.Position()
 	if aPos == token.NoPos || bPos == token.NoPos {
@@ -521,7 +526,11 @@ func writeErr(w io.Writer, err Error) {
 		_, _ = io.WriteString(w, path)
 		_, _ = io.WriteString(w, ": ")
 	}
+	writeMsg(w, err)
+}
 
+// writeMsg writes the message of err and of the errors it wraps.
+func writeMsg(w io.Writer, err Error) {
 	for {
 		u := xerrors.Unwrap(err)
 
@@ -592,6 +601,10 @@ func printError(w io.Writer, err error, cfg *Config) {
 
 	if e, ok := err.(Error); ok {
 		writeErr(w, e)
+		// Only diagnostics that carry a code, like lint findings, show it.
+		if c := code(e); c != "" {
+			fprintf(w, " (%s)", c)
+		}
 	} else {
 		fprintf(w, "%v", err)
 	}
@@ -190,3 +190,133 @@ func TestPrintError(t *testing.T) {
 		}
 	}
 }
+
+type pathErr struct {
+	*posError
+	path []string
+}
+
+func (e *pathErr) Path() []string { return e.path }
+
+func TestDiagnostics(t *testing.T) {
+	f := token.NewFile("/cue/dir/a.cue", 0, 20)
+	f.SetLinesForContent([]byte("a: int\na: \"s\"\nb: 1\n"))
+	pos := func(offset int) token.Pos { return f.Pos(offset, token.NoRelPos) }
+
+	conflict := &posError{
+		pos:    pos(3),
+		inputs: []token.Pos{pos(10)},
+		Message: NewMessage("conflicting values %s and %s",
+			[]interface{}{"int", `"s"`}),
+	}
+	err := Append(
+		&pathErr{conflict, []string{"a"}},
+		Newf(token.NoPos, "no position"))
+
+	got := &bytes.Buffer{}
+	if err := PrintJSON(got, err, &Config{Cwd: "/cue"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"message":"no position","severity":"error"}
+{"message":"conflicting values int and \"s\"","path":["a"],"severity":"error","positions":[{"filename":"dir/a.cue","line":1,"column":4,"offset":3},{"filename":"dir/a.cue","line":2,"column":4,"offset":10}]}
+`
+	if got.String() != want {
+		t.Errorf("PrintJSON:\ngot  %s\nwant %s", got, want)
+	}
+
+	got.Reset()
+	if err := PrintSARIF(got, []error{err}, &Config{Cwd: "/cue"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`"version": "2.1.0"`,
+		`"text": "no position"`,
+		`"uri": "dir/a.cue"`,
+		`"startLine": 2`,
+		`"relatedLocations"`,
+		`"fullyQualifiedName": "a"`,
+	} {
+		if !bytes.Contains(got.Bytes(), []byte(s)) {
+			t.Errorf("PrintSARIF: output does not contain %s:\n%s", s, got)
+		}
+	}
+}
+
+func TestErrorsWithoutCode(t *testing.T) {
+	f := token.NewFile("a.cue", 0, 10)
+	f.SetLinesForContent([]byte("a: 1\nb: 2\n"))
+	pos := f.Pos(0, token.NoRelPos)
+
+	// Errors without a code at the same line are still merged and printed
+	// without a code.
+	err := Sanitize(Append(
+		&posError{pos: pos, Message: NewMessage("first", nil)},
+		&posError{pos: pos, Message: NewMessage("second", nil)}))
+	if n := len(Errors(err)); n != 1 {
+		t.Errorf("Sanitize: got %d errors, want 1", n)
+	}
+
+	got := &bytes.Buffer{}
+	Print(got, err, nil)
+	want := "first:\n    a.cue:1:1\n"
+	if got.String() != want {
+		t.Errorf("Print:\ngot  %s\nwant %s", got, want)
+	}
+
+	got.Reset()
+	if err := PrintJSON(got, err, nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"message":"first","severity":"error","positions":[{"filename":"a.cue","line":1,"column":1,"offset":0}]}
+`
+	if got.String() != want {
+		t.Errorf("PrintJSON:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+type codeErr struct {
+	*posError
+	code string
+}
+
+func (e *codeErr) Code() string     { return e.code }
+func (e *codeErr) Severity() string { return "warning" }
+
+func TestDiagnosticCode(t *testing.T) {
+	f := token.NewFile("a.cue", 0, 10)
+	f.SetLinesForContent([]byte("a: 1\nb: 2\n"))
+	pos := f.Pos(0, token.NoRelPos)
+
+	// Errors at the same line are not merged if their codes differ.
+	err := Append(
+		&codeErr{&posError{pos: pos, Message: NewMessage("first", nil)}, "rule-a"},
+		&codeErr{&posError{pos: pos, Message: NewMessage("second", nil)}, "rule-b"})
+
+	got := &bytes.Buffer{}
+	Print(got, err, nil)
+	want := "first (rule-a):\n    a.cue:1:1\nsecond (rule-b):\n    a.cue:1:1\n"
+	if got.String() != want {
+		t.Errorf("Print:\ngot  %s\nwant %s", got, want)
+	}
+
+	got.Reset()
+	if err := PrintJSON(got, err, nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"message":"first","severity":"warning","code":"rule-a","positions":[{"filename":"a.cue","line":1,"column":1,"offset":0}]}
+{"message":"second","severity":"warning","code":"rule-b","positions":[{"filename":"a.cue","line":1,"column":1,"offset":0}]}
+`
+	if got.String() != want {
+		t.Errorf("PrintJSON:\ngot  %s\nwant %s", got, want)
+	}
+
+	got.Reset()
+	if err := PrintSARIF(got, []error{err}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"ruleId": "rule-a"`, `"level": "warning"`} {
+		if !bytes.Contains(got.Bytes(), []byte(s)) {
+			t.Errorf("PrintSARIF: output does not contain %s:\n%s", s, got)
+		}
+	}
+}
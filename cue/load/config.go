@@ -16,6 +16,7 @@ package load
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	pathpkg "path"
 	"path/filepath"
@@ -25,12 +26,9 @@ import (
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/errors"
-	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal"
-	"cuelang.org/go/internal/core/compile"
-	"cuelang.org/go/internal/core/eval"
-	"cuelang.org/go/internal/core/runtime"
+	modfile "cuelang.org/go/internal/mod"
 )
 
 const (
@@ -122,6 +120,24 @@ func GenPath(root string) string {
 	return internal.GenPath(root)
 }
 
+// genPath is like GenPath for the module root of c, but consults the file
+// system of the configuration.
+func (c *Config) genPath() string {
+	root := c.ModuleRoot
+	info, err := c.fileSystem.stat(filepath.Join(root, modDir))
+	if err != nil || !info.IsDir() {
+		// Try legacy pkgDir mode
+		pkgDir := filepath.Join(root, "pkg")
+		if err == nil {
+			return pkgDir
+		}
+		if info, err := c.fileSystem.stat(pkgDir); err == nil && info.IsDir() {
+			return pkgDir
+		}
+	}
+	return filepath.Join(root, modDir, "gen")
+}
+
 // A Config configures load behavior.
 type Config struct {
 	// Context specifies the context for the load operation.
@@ -242,6 +258,13 @@ type Config struct {
 	// to CUE.
 	DataFiles bool
 
+	// If Vendor is set, packages outside the main module, other than builtin
+	// packages, are loaded exclusively from the vendor directory
+	// cue.mod/vendor, as populated by "cue mod vendor". Required modules,
+	// replacements, and the cue.mod/gen, cue.mod/pkg and cue.mod/usr
+	// directories are not consulted.
+	Vendor bool
+
 	// StdRoot specifies an alternative directory for standard libaries.
 	// This is mostly used for bootstrapping.
 	StdRoot string
@@ -266,12 +289,32 @@ type Config struct {
 	// If the value must be of type string, []byte, io.Reader, or *ast.File.
 	Overlay map[string]Source
 
+	// FS specifies the file system from which the loader reads. It is used
+	// for all directory listings, file information and file contents,
+	// including those needed to locate the module root and to read the
+	// cue.mod directory, so that instances can be loaded entirely from a
+	// virtual file tree. Overlay is applied on top of FS.
+	//
+	// If FS is nil, the file system of the host operating system is used.
+	FS FS
+
+	// Workers specifies the maximum number of files that are read and parsed
+	// concurrently. Packages are assembled in the same, deterministic order
+	// regardless of this setting. If Workers is 0, runtime.GOMAXPROCS(0) is
+	// used. A value of 1 disables concurrent loading.
+	Workers int
+
 	// Stdin defines an alternative for os.Stdin for the file "-". When used,
 	// the corresponding build.File will be associated with the full buffer.
 	Stdin io.Reader
 
 	fileSystem
 
+	modFile  *modfile.File
+	sums     modfile.Sums
+	cacheDir string                     // root of the module cache
+	vendored map[string]modfile.Version // vendored package to its module
+
 	loadFunc build.LoadFunc
 }
 
@@ -291,6 +334,17 @@ func (c *Config) newInstance(pos token.Pos, p importPath) *build.Instance {
 	i.ImportPath = string(p)
 	i.Root = c.ModuleRoot
 	i.Module = c.Module
+	if c.isVendored(p) {
+		i.Root = c.vendorDir()
+		m, _ := c.vendoredModule(p)
+		i.Module = m.Path
+	} else if r, ok := c.replacement(p); ok {
+		i.Root = c.replaceDir(r)
+		i.Module = r.Path
+	} else if m, ok := c.dependency(p); ok {
+		i.Root = c.moduleDir(m)
+		i.Module = m.Path
+	}
 	i.Err = errors.Append(i.Err, err)
 
 	return i
@@ -465,7 +519,21 @@ func (c *Config) absDirFromImportPath(pos token.Pos, p importPath) (absDir, name
 		absDir = filepath.Join(c.ModuleRoot, sub[len(c.Module)+1:])
 
 	default:
-		absDir = filepath.Join(GenPath(c.ModuleRoot), sub)
+		if c.isVendored(p) {
+			absDir = filepath.Join(c.vendorDir(), sub)
+			break
+		}
+		if r, ok := c.replacement(p); ok {
+			rel := strings.TrimPrefix(string(p), r.Path)
+			absDir = filepath.Join(c.replaceDir(r), filepath.FromSlash(rel))
+			break
+		}
+		if m, ok := c.dependency(p); ok {
+			rel := strings.TrimPrefix(string(p), m.Path)
+			absDir = filepath.Join(c.moduleDir(m), filepath.FromSlash(rel))
+			break
+		}
+		absDir = filepath.Join(c.genPath(), sub)
 	}
 
 	return absDir, name, err
@@ -515,6 +583,7 @@ func (c Config) complete() (cfg *Config, err error) {
 	c.loader = &loader{
 		cfg:       &c,
 		buildTags: make(map[string]bool),
+		verified:  make(map[modfile.Version]errors.Error),
 	}
 
 	// TODO: also make this work if run from outside the module?
@@ -532,38 +601,45 @@ func (c Config) complete() (cfg *Config, err error) {
 		if cerr != nil {
 			break
 		}
+		b, rerr := ioutil.ReadAll(f)
+		f.Close()
+		if rerr != nil {
+			return nil, errors.Wrapf(rerr, token.NoPos, "invalid cue.mod file")
+		}
 
 		// TODO: move to full build again
-		file, err := parser.ParseFile("load", f)
+		file, err := modfile.Parse(mod, b)
 		if err != nil {
 			return nil, errors.Wrapf(err, token.NoPos, "invalid cue.mod file")
 		}
+		c.modFile = file
 
-		r := runtime.New()
-		v, err := compile.Files(nil, r, "_", file)
-		if err != nil {
-			return nil, errors.Wrapf(err, token.NoPos, "invalid cue.mod file")
-		}
-		ctx := eval.NewContext(r, v)
-		v.Finalize(ctx)
-		prefix := v.Lookup(ctx.StringLabel("module"))
-		if prefix != nil {
-			name := ctx.StringValue(prefix.Value())
-			if err := ctx.Err(); err != nil {
-				return &c, err.Err
-			}
-			pos := token.NoPos
-			src := prefix.Value().Source()
-			if src != nil {
-				pos = src.Pos()
-			}
+		if name := file.Module; name != "" {
 			if c.Module != "" && c.Module != name {
-				return &c, errors.Newf(pos, "inconsistent modules: got %q, want %q", name, c.Module)
+				return &c, errors.Newf(file.ModulePos, "inconsistent modules: got %q, want %q", name, c.Module)
 			}
 			c.Module = name
 		}
+
+		if !c.Vendor {
+			if len(file.Require) > 0 {
+				if c.cacheDir, err = modfile.CacheDir(); err != nil {
+					return nil, err
+				}
+			}
+			if c.sums, err = c.readSums(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if c.Vendor {
+		if c.vendored, err = c.readVendorManifest(); err != nil {
+			return nil, err
+		}
 	}
 
+	c.loader.prefetch = newPrefetcher(&c)
 	c.loadFunc = c.loader.loadFunc()
 
 	if c.Context == nil {
@@ -44,10 +44,45 @@ func (f *overlayFile) ModTime() time.Time { return f.modtime }
 func (f *overlayFile) IsDir() bool        { return f.isDir }
 func (f *overlayFile) Sys() interface{}   { return nil }
 
+// An FS provides access to a hierarchical file system. It is modeled after
+// the FS interfaces of the io/fs package, but names are absolute,
+// clean paths in the syntax of the host operating system, like the keys of
+// Config.Overlay.
+//
+// Errors reported for files that do not exist must satisfy os.IsNotExist.
+type FS interface {
+	// Open opens the named file for reading.
+	Open(name string) (io.ReadCloser, error)
+
+	// Stat returns the file information of the named file.
+	Stat(name string) (os.FileInfo, error)
+
+	// ReadDir returns the entries of the named directory sorted by name.
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// osFS implements FS using the file system of the host operating system.
+type osFS struct{}
+
+func (osFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (osFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (osFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
+
 // A fileSystem specifies the supporting context for a build.
 type fileSystem struct {
 	overlayDirs map[string]map[string]*overlayFile
 	cwd         string
+
+	// files is the underlying file system on top of which the overlay is
+	// applied.
+	files FS
+}
+
+// isOS reports whether the underlying file system is that of the host
+// operating system.
+func (fs *fileSystem) isOS() bool {
+	_, ok := fs.files.(osFS)
+	return ok
 }
 
 func (fs *fileSystem) getDir(dir string, create bool) map[string]*overlayFile {
@@ -63,6 +98,11 @@ func (fs *fileSystem) getDir(dir string, create bool) map[string]*overlayFile {
 func (fs *fileSystem) init(c *Config) error {
 	fs.cwd = c.Dir
 
+	fs.files = c.FS
+	if fs.files == nil {
+		fs.files = osFS{}
+	}
+
 	overlay := c.Overlay
 	fs.overlayDirs = map[string]map[string]*overlayFile{}
 
@@ -127,13 +167,13 @@ func (fs *fileSystem) isDir(path string) bool {
 	if fs.getDir(path, false) != nil {
 		return true
 	}
-	fi, err := os.Stat(path)
+	fi, err := fs.files.Stat(path)
 	return err == nil && fi.IsDir()
 }
 
 func (fs *fileSystem) hasSubdir(root, dir string) (rel string, ok bool) {
 	// Try using paths we received.
-	if rel, ok = hasSubdir(root, dir); ok {
+	if rel, ok = hasSubdir(root, dir); ok || !fs.isOS() {
 		return
 	}
 
@@ -168,11 +208,14 @@ func hasSubdir(root, dir string) (rel string, ok bool) {
 func (fs *fileSystem) readDir(path string) ([]os.FileInfo, errors.Error) {
 	path = fs.makeAbs(path)
 	m := fs.getDir(path, false)
-	items, err := ioutil.ReadDir(path)
+	items, err := fs.files.ReadDir(path)
 	if err != nil {
-		if !os.IsNotExist(err) || m == nil {
+		// An overlay directory may shadow a file or a directory that does
+		// not exist in the underlying file system.
+		if m == nil {
 			return nil, errors.Wrapf(err, token.NoPos, "readDir")
 		}
+		items = nil
 	}
 	if m != nil {
 		done := map[string]bool{}
@@ -207,19 +250,27 @@ func (fs *fileSystem) stat(path string) (os.FileInfo, errors.Error) {
 	if fi := fs.getOverlay(path); fi != nil {
 		return fi, nil
 	}
-	fi, err := os.Stat(path)
+	fi, err := fs.files.Stat(path)
 	if err != nil {
 		return nil, errors.Wrapf(err, token.NoPos, "stat")
 	}
 	return fi, nil
 }
 
+// lstat is like stat, but does not follow symbolic links if the underlying
+// file system is that of the host operating system.
 func (fs *fileSystem) lstat(path string) (os.FileInfo, errors.Error) {
 	path = fs.makeAbs(path)
 	if fi := fs.getOverlay(path); fi != nil {
 		return fi, nil
 	}
-	fi, err := os.Lstat(path)
+	var fi os.FileInfo
+	var err error
+	if fs.isOS() {
+		fi, err = os.Lstat(path)
+	} else {
+		fi, err = fs.files.Stat(path)
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, token.NoPos, "stat")
 	}
@@ -232,7 +283,7 @@ func (fs *fileSystem) openFile(path string) (io.ReadCloser, errors.Error) {
 		return ioutil.NopCloser(bytes.NewReader(fi.contents)), nil
 	}
 
-	f, err := os.Open(path)
+	f, err := fs.files.Open(path)
 	if err != nil {
 		return nil, errors.Wrapf(err, token.NoPos, "load")
 	}
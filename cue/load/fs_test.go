@@ -0,0 +1,177 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/format"
+)
+
+// mapFS is an in-memory file system rooted at root, holding files keyed by
+// their slash-separated path relative to root.
+type mapFS struct {
+	root  string
+	files map[string]string
+}
+
+func (m *mapFS) rel(name string) (string, bool) {
+	rel, err := filepath.Rel(m.root, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (m *mapFS) Open(name string) (io.ReadCloser, error) {
+	if rel, ok := m.rel(name); ok {
+		if s, ok := m.files[rel]; ok {
+			return ioutil.NopCloser(strings.NewReader(s)), nil
+		}
+	}
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+func (m *mapFS) Stat(name string) (os.FileInfo, error) {
+	rel, ok := m.rel(name)
+	if ok {
+		if s, ok := m.files[rel]; ok {
+			return &overlayFile{basename: filepath.Base(name), contents: []byte(s)}, nil
+		}
+		for f := range m.files {
+			if rel == "." || strings.HasPrefix(f, rel+"/") {
+				return &overlayFile{basename: filepath.Base(name), isDir: true}, nil
+			}
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (m *mapFS) ReadDir(name string) ([]os.FileInfo, error) {
+	rel, ok := m.rel(name)
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	prefix := rel + "/"
+	if rel == "." {
+		prefix = ""
+	}
+	seen := map[string]bool{}
+	var items []os.FileInfo
+	for f, s := range m.files {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+		elem := strings.SplitN(f[len(prefix):], "/", 2)
+		if seen[elem[0]] {
+			continue
+		}
+		seen[elem[0]] = true
+		items = append(items, &overlayFile{
+			basename: elem[0],
+			contents: []byte(s),
+			isDir:    len(elem) == 2,
+			modtime:  time.Time{},
+		})
+	}
+	if items == nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name() < items[j].Name() })
+	return items, nil
+}
+
+func TestFS(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "virtual-cue-root")
+	fs := &mapFS{root: root, files: map[string]string{
+		"cue.mod/module.cue": `module: "example.com"`,
+		"cue.mod/pkg/acme.com/lib/lib.cue": `
+			package lib
+
+			x: 1
+		`,
+		"root.cue": `
+			package a
+
+			r: 1
+		`,
+		"a/a.cue": `
+			package a
+
+			import "acme.com/lib"
+
+			a: lib.x
+		`,
+		"a/data.json": `{"d": 2}`,
+		"b/b.cue": `
+			package b
+
+			import "example.com/a"
+
+			b: a.a
+		`,
+	}}
+
+	insts := Instances([]string{"./..."}, &Config{
+		Dir: filepath.Join(root, "a"),
+		FS:  fs,
+	})
+	if len(insts) != 1 {
+		t.Fatalf("got %d instances; want 1", len(insts))
+	}
+	if err := insts[0].Err; err != nil {
+		t.Fatal(err)
+	}
+	if got := insts[0].Root; got != root {
+		t.Errorf("got root %q; want %q", got, root)
+	}
+
+	insts = Instances([]string{"./b", "./a/data.json"}, &Config{
+		Dir: root,
+		FS:  fs,
+	})
+	for _, p := range insts {
+		if p.Err != nil {
+			t.Fatal(p.Err)
+		}
+	}
+	inst := cue.Build(insts[:1])[0]
+	if inst.Err != nil {
+		t.Fatal(inst.Err)
+	}
+	b, err := format.Node(inst.Value().Syntax(cue.Final()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(strings.Fields(string(b)), " "), "{ b: 1 }"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	// Data files are read from the file system as well.
+	if len(insts) != 2 || len(insts[1].OrphanedFiles) != 1 {
+		t.Fatalf("expected data file instance, got %d instances", len(insts))
+	}
+	if src, _ := insts[1].OrphanedFiles[0].Source.([]byte); string(src) != `{"d": 2}` {
+		t.Errorf("got source %q", src)
+	}
+}
@@ -92,7 +92,7 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 		return []*build.Instance{p}
 	}
 
-	if !strings.HasPrefix(p.Dir, cfg.ModuleRoot) {
+	if !strings.HasPrefix(p.Dir, p.Root) {
 		err := errors.Newf(token.NoPos, "module root not defined", p.DisplayPath)
 		return retErr(err)
 	}
@@ -113,12 +113,12 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 		fp.ignoreOther = true
 	}
 
-	if !strings.HasPrefix(p.Dir, cfg.ModuleRoot) {
+	if !strings.HasPrefix(p.Dir, p.Root) {
 		panic("")
 	}
 
 	var dirs [][2]string
-	genDir := GenPath(cfg.ModuleRoot)
+	genDir := cfg.genPath()
 	if strings.HasPrefix(p.Dir, genDir) {
 		dirs = append(dirs, [2]string{genDir, p.Dir})
 		// TODO(legacy): don't support "pkg"
@@ -137,7 +137,11 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 			}
 		}
 	} else {
-		dirs = append(dirs, [2]string{cfg.ModuleRoot, p.Dir})
+		dirs = append(dirs, [2]string{p.Root, p.Dir})
+	}
+
+	for _, d := range dirs {
+		l.prefetch.dir(d[1])
 	}
 
 	found := false
@@ -149,7 +153,39 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 		}
 	}
 
+	if ip := importPath(p.ImportPath); cfg.isVendored(ip) {
+		if _, ok := cfg.vendoredModule(ip); !ok {
+			found = false
+		}
+	}
+
 	if !found {
+		if cfg.isVendored(importPath(p.ImportPath)) {
+			return retErr(
+				&PackageError{
+					Message: errors.NewMessage(
+						"cannot find package %q: package is not vendored (run 'cue mod vendor')",
+						[]interface{}{p.DisplayPath}),
+				})
+		}
+		if r, ok := cfg.replacement(importPath(p.ImportPath)); ok &&
+			!ctxt.isDir(p.Root) {
+			return retErr(
+				&PackageError{
+					Message: errors.NewMessage(
+						"cannot find package %q: replacement directory %s for %s does not exist",
+						[]interface{}{p.DisplayPath, r.Dir, r.Path}),
+				})
+		}
+		if m, ok := cfg.dependency(importPath(p.ImportPath)); ok &&
+			!ctxt.isDir(p.Root) {
+			return retErr(
+				&PackageError{
+					Message: errors.NewMessage(
+						"cannot find package %q: module %s not downloaded (run 'cue mod tidy')",
+						[]interface{}{p.DisplayPath, m}),
+				})
+		}
 		return retErr(
 			&PackageError{
 				Message: errors.NewMessage("cannot find package %q",
@@ -157,9 +193,16 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 			})
 	}
 
+	if m, ok := cfg.dependency(importPath(p.ImportPath)); ok {
+		if err := l.verifyModule(m, p.Root); err != nil {
+			return retErr(err)
+		}
+	}
+
 	// This algorithm assumes that multiple directories within cue.mod/*/
 	// have the same module scope and that there are no invalid modules.
-	inModule := false // if pkg == "_"
+	// Dependencies loaded from outside the main module are always modules.
+	inModule := p.Root != cfg.ModuleRoot // if pkg == "_"
 	for _, d := range dirs {
 		if l.cfg.findRoot(d[1]) != "" {
 			inModule = true
@@ -167,8 +210,14 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 		}
 	}
 
+	verifyPkg := cfg.verifiesPkgDirs()
 	for _, d := range dirs {
 		for dir := filepath.Clean(d[1]); ctxt.isDir(dir); {
+			if verifyPkg && d[0] == cfg.pkgDir() {
+				if err := l.verifyPkgDir(dir); err != nil {
+					return retErr(err)
+				}
+			}
 			files, err := ctxt.readDir(dir)
 			if err != nil && !os.IsNotExist(err) {
 				return retErr(errors.Wrapf(err, pos, "import failed reading dir %v", dirs[0][1]))
@@ -220,7 +269,7 @@ func (l *loader) importPkg(pos token.Pos, p *build.Instance) []*build.Instance {
 		}
 
 		all = append(all, p)
-		rewriteFiles(p, cfg.ModuleRoot, false)
+		rewriteFiles(p, p.Root, false)
 		if errs := fp.finalize(p); errs != nil {
 			p.ReportError(errs)
 			return all
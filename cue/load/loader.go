@@ -31,6 +31,7 @@ import (
 	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal/encoding"
 	"cuelang.org/go/internal/filetypes"
+	modfile "cuelang.org/go/internal/mod"
 
 	// Trigger the unconditional loading of all core builtin packages if load
 	// is used. This was deemed the simplest way to avoid having to import
@@ -55,6 +56,7 @@ func Instances(args []string, c *Config) []*build.Instance {
 	c = newC
 
 	l := c.loader
+	defer l.prefetch.close()
 
 	// TODO: require packages to be placed before files. At some point this
 	// could be relaxed.
@@ -138,6 +140,14 @@ type loader struct {
 	tags         []tag // tags found in files
 	buildTags    map[string]bool
 	replacements map[ast.Node]ast.Node
+
+	// verified records the result of verifying the checksum of each
+	// dependency module.
+	verified map[modfile.Version]errors.Error
+
+	// prefetch reads and parses files ahead of the loader. It is nil if
+	// concurrent loading is disabled.
+	prefetch *prefetcher
 }
 
 func (l *loader) abs(filename string) string {
@@ -216,8 +226,25 @@ func (l *loader) cueFilesPackage(files []*build.File) *build.Instance {
 }
 
 func (l *loader) addFiles(dir string, p *build.Instance) {
+	if l.prefetch != nil {
+		var filenames []string
+		for _, f := range p.BuildFiles {
+			if isPrefetchable(f) {
+				filenames = append(filenames, f.Filename)
+			}
+		}
+		l.prefetch.schedule(filenames...)
+	}
 	for _, f := range p.BuildFiles {
-		d := encoding.NewDecoder(f, &encoding.Config{
+		src := f
+		if isPrefetchable(f) {
+			if file, ok := l.prefetch.file(f.Filename); ok {
+				c := *f
+				c.Source = file
+				src = &c
+			}
+		}
+		d := encoding.NewDecoder(src, &encoding.Config{
 			Stdin:     l.cfg.stdin(),
 			ParseFile: l.cfg.ParseFile,
 		})
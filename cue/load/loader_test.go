@@ -16,6 +16,9 @@ package load
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -28,6 +31,7 @@ import (
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/format"
+	modfile "cuelang.org/go/internal/mod"
 	"cuelang.org/go/internal/str"
 )
 
@@ -394,3 +398,489 @@ func TestOverlays(t *testing.T) {
 		}
 	}
 }
+
+// TestOverlayShadowsFile tests that an overlay may mount a directory tree at
+// the location of a file, as is done for archives.
+func TestOverlayShadowsFile(t *testing.T) {
+	cwd, _ := os.Getwd()
+	root := filepath.Join(cwd, "loader_test.go")
+	c := &Config{
+		Dir:        root,
+		ModuleRoot: root,
+		Overlay: map[string]Source{
+			filepath.Join(root, "cue.mod", "module.cue"): FromString(`module: "acme.com"`),
+			filepath.Join(root, "a.cue"): FromString(`
+				package a
+				import "acme.com/sub"
+				x: sub.y
+			`),
+			filepath.Join(root, "sub", "sub.cue"): FromString(`
+				package sub
+				y: 3
+			`),
+		},
+	}
+	want := []string{`{x:3}`, `{y:3}`}
+	insts := cue.Build(Instances([]string{"./..."}, c))
+	if len(insts) != len(want) {
+		t.Fatalf("got %d instances; want %d", len(insts), len(want))
+	}
+	for i, inst := range insts {
+		if inst.Err != nil {
+			t.Fatal(inst.Err)
+		}
+		b, err := format.Node(inst.Value().Syntax(cue.Final()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(strings.Fields(string(b)), "")
+		if got != want[i] {
+			t.Errorf("%d: got %s; want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestInconsistentModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cue-load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{
+		"cue.mod/module.cue": `module: "acme.com"`,
+		"a.cue":              `package a`,
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inst := Instances([]string{"."}, &Config{Dir: dir, Module: "other.com"})[0]
+	const want = `inconsistent modules: got "acme.com", want "other.com"`
+	if inst.Err == nil || !strings.Contains(inst.Err.Error(), want) {
+		t.Fatalf("got error %v; want %q", inst.Err, want)
+	}
+	pos := inst.Err.Position()
+	if got := filepath.Base(pos.Filename()); got != "module.cue" || pos.Line() != 1 {
+		t.Errorf("got error position %v; want module.cue:1", pos)
+	}
+}
+
+func TestModuleDependencies(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := func(path string) string {
+		return filepath.Join(cwd, filepath.FromSlash(path))
+	}
+	cache := abs("testdata/_cache")
+	old := os.Getenv("CUE_CACHE_DIR")
+	os.Setenv("CUE_CACHE_DIR", cache)
+	defer os.Setenv("CUE_CACHE_DIR", old)
+
+	schemas := modfile.Version{Path: "acme.com/schemas", Version: "v1.0.0"}
+	k8s := modfile.Version{Path: "acme.com/schemas/k8s", Version: "v0.1.0"}
+	deps := map[modfile.Version]map[string]string{
+		schemas: {"a.cue": `
+			package schemas
+
+			#A: x: int
+		`},
+		k8s: {"k8s.cue": `
+			package k8s
+
+			d: "v0.1.0"
+		`},
+	}
+
+	c := &Config{
+		Dir: abs("mod"),
+		Overlay: map[string]Source{
+			abs("mod/cue.mod/module.cue"): FromString(`
+				module: "example.com/main"
+				require: {
+					"acme.com/schemas": "v1.0.0"
+					"acme.com/schemas/k8s": "v0.1.0"
+				}
+			`),
+			abs("mod/main.cue"): FromString(`
+				package main
+
+				import (
+					"acme.com/schemas"
+					"acme.com/schemas/k8s"
+				)
+
+				a: schemas.#A & {x: 1}
+				d: k8s.d
+			`),
+		},
+	}
+	sums := modfile.Sums{}
+	for m, files := range deps {
+		var names []string
+		for name, data := range files {
+			names = append(names, name)
+			c.Overlay[filepath.Join(modfile.Dir(cache, m), name)] = FromString(data)
+		}
+		h, err := modfile.Hash(names, func(name string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(files[name])), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums[m] = h
+	}
+	c.Overlay[abs("mod/cue.mod/cue.sum")] = FromBytes(sums.Format())
+
+	insts := Instances(nil, c)
+	if len(insts) != 1 {
+		t.Fatalf("got %d instances; want 1", len(insts))
+	}
+	p := insts[0]
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	wantRoots := map[string]string{
+		"acme.com/schemas":     filepath.Join(cache, "mod", "acme.com", "schemas@v1.0.0"),
+		"acme.com/schemas/k8s": filepath.Join(cache, "mod", "acme.com", "schemas", "k8s@v0.1.0"),
+	}
+	for _, dep := range p.Imports {
+		if dep.Err != nil {
+			t.Errorf("%s: %v", dep.ImportPath, dep.Err)
+		}
+		if want := wantRoots[dep.ImportPath]; dep.Root != want {
+			t.Errorf("%s: got root %q; want %q", dep.ImportPath, dep.Root, want)
+		}
+		if dep.Module != dep.ImportPath {
+			t.Errorf("%s: got module %q", dep.ImportPath, dep.Module)
+		}
+	}
+
+	inst := cue.Build(insts)[0]
+	if inst.Err != nil {
+		t.Fatal(inst.Err)
+	}
+	b, err := format.Node(inst.Value().Syntax(cue.Final()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(strings.Fields(string(b)), " ")
+	if want := `{ a: { x: 1 } d: "v0.1.0" }`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	// Modified dependencies are detected.
+	c.Overlay[filepath.Join(modfile.Dir(cache, k8s), "k8s.cue")] = FromString(`
+		package k8s
+
+		d: "modified"
+	`)
+	p = Instances(nil, c)[0]
+	if p.Err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if got := p.Err.Error(); !strings.Contains(got, "checksum mismatch for module acme.com/schemas/k8s@v0.1.0") {
+		t.Errorf("unexpected error %q", got)
+	}
+
+	// Dependencies that are not in the module cache are reported as such.
+	c.Overlay[abs("mod/cue.mod/module.cue")] = FromString(`
+		module: "example.com/main"
+		require: "acme.com/schemas": "v1.1.0"
+	`)
+	c.Overlay[abs("mod/main.cue")] = FromString(`
+		package main
+
+		import "acme.com/schemas"
+
+		a: schemas.#A
+	`)
+	p = Instances(nil, c)[0]
+	if p.Err == nil {
+		t.Fatal("expected error for missing dependency")
+	}
+	const want = `import failed: cannot find package "acme.com/schemas": module acme.com/schemas@v1.1.0 not downloaded (run 'cue mod tidy')`
+	if got := p.Err.Error(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestPkgDirChecksums(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := func(path string) string {
+		return filepath.Join(cwd, filepath.FromSlash(path))
+	}
+
+	const util = `
+		package util
+
+		x: 1
+	`
+	c := &Config{
+		Dir: abs("mod"),
+		Overlay: map[string]Source{
+			abs("mod/cue.mod/module.cue"): FromString(`module: "example.com/main"`),
+			abs("mod/main.cue"): FromString(`
+				package main
+
+				import "acme.com/util"
+
+				x: util.x
+			`),
+			abs("mod/cue.mod/pkg/acme.com/util/util.cue"): FromString(util),
+		},
+	}
+	load := func() error {
+		p := Instances(nil, c)[0]
+		if p.Err != nil {
+			return p.Err
+		}
+		for _, dep := range p.Imports {
+			if dep.Err != nil {
+				return dep.Err
+			}
+		}
+		return nil
+	}
+
+	// Without a checksum file, packages in cue.mod/pkg are not verified.
+	if err := load(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := modfile.Hash([]string{"util.cue"}, func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(util)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := modfile.Sums{{Path: "cue.mod/pkg/acme.com/util"}: h}
+	c.Overlay[abs("mod/cue.mod/cue.sum")] = FromBytes(sums.Format())
+	if err := load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modified files are detected.
+	c.Overlay[abs("mod/cue.mod/pkg/acme.com/util/util.cue")] = FromString(`
+		package util
+
+		x: 2
+	`)
+	err = load()
+	if err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if got := err.Error(); !strings.Contains(got, "checksum mismatch for cue.mod/pkg/acme.com/util") {
+		t.Errorf("unexpected error %q", got)
+	}
+
+	// So are directories without an entry.
+	c.Overlay[abs("mod/cue.mod/pkg/acme.com/util/util.cue")] = FromString(util)
+	c.Overlay[abs("mod/cue.mod/cue.sum")] = FromString("")
+	err = load()
+	if err == nil {
+		t.Fatal("expected missing entry error")
+	}
+	if got := err.Error(); !strings.Contains(got, "missing cue.sum entry for cue.mod/pkg/acme.com/util (run 'cue mod tidy')") {
+		t.Errorf("unexpected error %q", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := func(path string) string {
+		return filepath.Join(cwd, filepath.FromSlash(path))
+	}
+
+	c := &Config{
+		Dir: abs("mod"),
+		Overlay: map[string]Source{
+			// The replacement takes precedence over the requirement, so
+			// neither the module cache nor cue.sum are consulted.
+			abs("mod/cue.mod/module.cue"): FromString(`
+				module: "example.com/main"
+				require: "acme.com/schemas": "v1.0.0"
+				replace: "acme.com/schemas": "../_schemas"
+			`),
+			abs("mod/main.cue"): FromString(`
+				package main
+
+				import "acme.com/schemas/k8s"
+
+				d: k8s.d
+			`),
+			abs("_schemas/k8s/k8s.cue"): FromString(`
+				package k8s
+
+				d: "local"
+			`),
+		},
+	}
+	insts := Instances(nil, c)
+	p := insts[0]
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	if len(p.Imports) != 1 {
+		t.Fatalf("got %d imports; want 1", len(p.Imports))
+	}
+	dep := p.Imports[0]
+	if want := abs("_schemas"); dep.Root != want {
+		t.Errorf("got root %q; want %q", dep.Root, want)
+	}
+	if want := abs("_schemas/k8s"); dep.Dir != want {
+		t.Errorf("got dir %q; want %q", dep.Dir, want)
+	}
+	if dep.Module != "acme.com/schemas" {
+		t.Errorf("got module %q; want acme.com/schemas", dep.Module)
+	}
+
+	inst := cue.Build(insts)[0]
+	if inst.Err != nil {
+		t.Fatal(inst.Err)
+	}
+	got, _ := inst.Lookup("d").String()
+	if got != "local" {
+		t.Errorf("got %q; want \"local\"", got)
+	}
+}
+
+func TestVendor(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := func(path string) string {
+		return filepath.Join(cwd, filepath.FromSlash(path))
+	}
+
+	c := &Config{
+		Dir:    abs("mod"),
+		Vendor: true,
+		Overlay: map[string]Source{
+			abs("mod/cue.mod/module.cue"): FromString(`
+				module: "example.com/main"
+				require: "acme.com/schemas": "v1.0.0"
+			`),
+			abs("mod/cue.mod/vendor/modules.txt"): FromString(`
+# acme.com/schemas v1.0.0
+acme.com/schemas/k8s
+			`),
+			abs("mod/cue.mod/vendor/acme.com/schemas/k8s/k8s.cue"): FromString(`
+				package k8s
+
+				d: "vendored"
+			`),
+			abs("mod/main.cue"): FromString(`
+				package main
+
+				import "acme.com/schemas/k8s"
+
+				d: k8s.d
+			`),
+		},
+	}
+	insts := Instances(nil, c)
+	p := insts[0]
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	dep := p.Imports[0]
+	if want := abs("mod/cue.mod/vendor"); dep.Root != want {
+		t.Errorf("got root %q; want %q", dep.Root, want)
+	}
+	if dep.Module != "acme.com/schemas" {
+		t.Errorf("got module %q; want acme.com/schemas", dep.Module)
+	}
+	inst := cue.Build(insts)[0]
+	if inst.Err != nil {
+		t.Fatal(inst.Err)
+	}
+	if got, _ := inst.Lookup("d").String(); got != "vendored" {
+		t.Errorf("got %q; want \"vendored\"", got)
+	}
+
+	// Packages that are not vendored cannot be loaded.
+	c.Overlay[abs("mod/main.cue")] = FromString(`
+		package main
+
+		import "acme.com/schemas"
+
+		d: schemas.d
+	`)
+	p = Instances(nil, c)[0]
+	const want = `import failed: cannot find package "acme.com/schemas": package is not vendored (run 'cue mod vendor')`
+	if p.Err == nil || p.Err.Error() != want {
+		t.Errorf("got error %v; want %q", p.Err, want)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "virtual-cue-root")
+	files := map[string]string{
+		"cue.mod/module.cue": `module: "example.com"`,
+		"cue.mod/pkg/acme.com/lib/lib.cue": `
+			package lib
+
+			x: 1
+		`,
+		"bad/bad.cue": `
+			package bad
+
+			a: {
+		`,
+	}
+	for i := 0; i < 6; i++ {
+		dir := fmt.Sprintf("p%02d", i)
+		files[dir+"/a.cue"] = fmt.Sprintf(`
+			package %[1]s
+
+			import "acme.com/lib"
+
+			a: lib.x + %[2]d
+		`, dir, i)
+		if i%2 == 1 {
+			files[dir+"/b.cue"] = fmt.Sprintf(`
+				package %[1]s
+
+				import "example.com/p%02[2]d"
+
+				b: p%02[2]d.a
+			`, dir, i-1)
+		}
+	}
+	fs := &mapFS{root: root, files: files}
+
+	load := func(workers int) string {
+		insts := Instances([]string{"./..."}, &Config{
+			Dir:     root,
+			FS:      fs,
+			Workers: workers,
+		})
+		buf := &bytes.Buffer{}
+		if err := pkgInfo.Execute(buf, insts); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range insts {
+			for _, f := range p.Files {
+				b, err := format.Node(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				buf.Write(b)
+			}
+		}
+		return buf.String()
+	}
+
+	want := load(1)
+	if !strings.Contains(want, "err:") {
+		t.Fatalf("expected parse error in output:\n%s", want)
+	}
+	for i := 0; i < 6; i++ {
+		if got := load(8); got != want {
+			t.Fatalf("results differ between sequential and concurrent load:\n%s",
+				diff.Diff(want, got))
+		}
+	}
+}
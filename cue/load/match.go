@@ -40,6 +40,19 @@ func matchFile(cfg *Config, file *build.File, returnImports, allFiles bool, allT
 		} else {
 			file.Source = fi.contents
 		}
+	} else if file.Filename != "-" && !cfg.fileSystem.isOS() {
+		// The contents of files in a virtual file system cannot be read by
+		// the decoder later on, so they are included with the file.
+		f, err := cfg.fileSystem.openFile(file.Filename)
+		if err != nil {
+			return false, nil, err
+		}
+		b, rerr := ioutil.ReadAll(f)
+		f.Close()
+		if rerr != nil {
+			return false, nil, errors.Newf(token.NoPos, "read %s: %v", file.Filename, rerr)
+		}
+		file.Source = b
 	}
 
 	if file.Encoding != build.CUE {
@@ -0,0 +1,290 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	modfile "cuelang.org/go/internal/mod"
+)
+
+// dependency reports the required module that provides the package with the
+// given import path. Packages within the main module or covered by a
+// replacement are never provided by a dependency.
+func (c *Config) dependency(p importPath) (m modfile.Version, ok bool) {
+	if c.modFile == nil || len(c.modFile.Require) == 0 || c.Vendor {
+		return m, false
+	}
+	if c.isMainModule(p) {
+		return m, false
+	}
+	if _, ok := c.modFile.Replacement(string(p)); ok {
+		return m, false
+	}
+	return modfile.ModuleForImport(c.modFile.Require, string(p))
+}
+
+// replacement reports the replacement declared in the module file for the
+// package with the given import path.
+func (c *Config) replacement(p importPath) (r modfile.Replacement, ok bool) {
+	if c.modFile == nil || len(c.modFile.Replace) == 0 || c.Vendor {
+		return r, false
+	}
+	if c.isMainModule(p) {
+		return r, false
+	}
+	return c.modFile.Replacement(string(p))
+}
+
+// replaceDir returns the absolute directory of replacement r.
+func (c *Config) replaceDir(r modfile.Replacement) string {
+	dir := filepath.FromSlash(r.Dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.ModuleRoot, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// isVendored reports whether the package with the given import path is loaded
+// from the vendor directory. In vendor mode, this holds for all packages
+// outside the main module, even if they were not vendored, in which case
+// loading them fails.
+func (c *Config) isVendored(p importPath) bool {
+	return c.Vendor && !c.isMainModule(p)
+}
+
+// vendoredModule returns the module of the vendored package with the given
+// import path, as recorded in the vendor manifest.
+func (c *Config) vendoredModule(p importPath) (m modfile.Version, ok bool) {
+	if m, ok := c.vendored[string(p)]; ok {
+		return m, true
+	}
+	path := string(p)
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		path = path[:i]
+	}
+	m, ok = c.vendored[path]
+	return m, ok
+}
+
+// vendorDir returns the vendor directory of the main module.
+func (c *Config) vendorDir() string {
+	return filepath.Join(c.ModuleRoot, modDir, modfile.VendorDir)
+}
+
+// readVendorManifest reads the vendor manifest and returns the module of
+// each vendored package. It reports an error if the manifest does not exist
+// or if it is inconsistent with the requirements of the main module.
+func (c *Config) readVendorManifest() (map[string]modfile.Version, error) {
+	filename := filepath.Join(c.vendorDir(), modfile.VendorManifest)
+	f, err := c.fileSystem.openFile(filename)
+	if err != nil {
+		return nil, errors.Newf(token.NoPos,
+			"cannot load vendored packages: %s/%s/%s not found (run 'cue mod vendor')",
+			modDir, modfile.VendorDir, modfile.VendorManifest)
+	}
+	defer f.Close()
+	b, rerr := ioutil.ReadAll(f)
+	if rerr != nil {
+		return nil, errors.Wrapf(rerr, token.NoPos, "reading vendor manifest")
+	}
+	mods, rerr := modfile.ParseVendorManifest(filename, b)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	vendored := map[string]modfile.Version{}
+	for _, m := range mods {
+		if c.modFile != nil {
+			if req, ok := c.modFile.Lookup(m.Path); ok && req != m.Version {
+				return nil, errors.Newf(token.NoPos,
+					"vendored module %s is inconsistent with requirement %s (run 'cue mod vendor')",
+					m.Version, req)
+			}
+		}
+		for _, p := range m.Packages {
+			vendored[p] = m.Version
+		}
+	}
+	return vendored, nil
+}
+
+// isMainModule reports whether the package with the given import path is
+// part of the main module.
+func (c *Config) isMainModule(p importPath) bool {
+	path := string(p)
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		path = path[:i]
+	}
+	return c.Module != "" && modfile.HasPathPrefix(path, c.Module)
+}
+
+// moduleDir returns the directory from which the packages of the given
+// dependency are loaded.
+func (c *Config) moduleDir(m modfile.Version) string {
+	return modfile.Dir(c.cacheDir, m)
+}
+
+// readSums reads the checksum file of the main module. It returns nil if the
+// file does not exist.
+func (c *Config) readSums() (modfile.Sums, error) {
+	filename := filepath.Join(c.ModuleRoot, modDir, modfile.SumFile)
+	f, err := c.fileSystem.openFile(filename)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	b, rerr := ioutil.ReadAll(f)
+	if rerr != nil {
+		return nil, errors.Wrapf(rerr, token.NoPos, "reading %s", modfile.SumFile)
+	}
+	return modfile.ParseSums(filename, b)
+}
+
+// verifyModule checks that the contents of dependency m, rooted at dir, match
+// the hash recorded for it in the checksum file of the main module.
+func (l *loader) verifyModule(m modfile.Version, dir string) errors.Error {
+	if err, ok := l.verified[m]; ok {
+		return err
+	}
+	err := l.checkModule(m, dir)
+	l.verified[m] = err
+	return err
+}
+
+func (l *loader) checkModule(m modfile.Version, dir string) errors.Error {
+	want, ok := l.cfg.sums[m]
+	if !ok {
+		return &PackageError{
+			Message: errors.NewMessage(
+				"missing %s entry for module %s (run 'cue mod tidy')",
+				[]interface{}{modfile.SumFile, m}),
+		}
+	}
+
+	fs := &l.cfg.fileSystem
+	var files []string
+	err := fs.walk(dir, func(path string, info os.FileInfo, err errors.Error) errors.Error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return errors.Wrapf(err, token.NoPos, "invalid path")
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Promote(err, "verifying module")
+	}
+
+	got, err := modfile.Hash(files, func(name string) (io.ReadCloser, error) {
+		r, err := fs.openFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	if err != nil {
+		return errors.Promote(err, "verifying module")
+	}
+	if got != want {
+		e := &modfile.ChecksumError{Module: m, Got: got, Want: want}
+		return &PackageError{
+			Message: errors.NewMessage("%v", []interface{}{e}),
+		}
+	}
+	return nil
+}
+
+// pkgDir returns the cue.mod/pkg directory of the main module.
+func (c *Config) pkgDir() string {
+	return filepath.Join(c.ModuleRoot, modDir, modfile.PkgDir)
+}
+
+// verifiesPkgDirs reports whether packages loaded from cue.mod/pkg are
+// verified against the checksum file. This is the case if the main module
+// has a checksum file or requires modules. Modules that are not managed
+// with the mod commands can thus continue to use cue.mod/pkg as before.
+func (c *Config) verifiesPkgDirs() bool {
+	if c.Vendor || c.modFile == nil {
+		return false
+	}
+	return c.sums != nil || len(c.modFile.Require) > 0
+}
+
+// verifyPkgDir checks that the files directly within dir, a directory within
+// cue.mod/pkg, match the hash recorded for it in the checksum file of the
+// main module.
+func (l *loader) verifyPkgDir(dir string) errors.Error {
+	key, err := modfile.PkgDirKey(l.cfg.ModuleRoot, dir)
+	if err != nil {
+		return errors.Wrapf(err, token.NoPos, "invalid path")
+	}
+	if err, ok := l.verified[key]; ok {
+		return err
+	}
+	verr := l.checkPkgDir(key, dir)
+	l.verified[key] = verr
+	return verr
+}
+
+func (l *loader) checkPkgDir(key modfile.Version, dir string) errors.Error {
+	fs := &l.cfg.fileSystem
+	infos, err := fs.readDir(dir)
+	if err != nil {
+		return errors.Promote(err, "verifying package directory")
+	}
+	files := modfile.DirFiles(infos)
+	if len(files) == 0 {
+		return nil
+	}
+
+	want, ok := l.cfg.sums[key]
+	if !ok {
+		return &PackageError{
+			Message: errors.NewMessage(
+				"missing %s entry for %s (run 'cue mod tidy')",
+				[]interface{}{modfile.SumFile, key.Path}),
+		}
+	}
+
+	got, herr := modfile.Hash(files, func(name string) (io.ReadCloser, error) {
+		r, err := fs.openFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	if herr != nil {
+		return errors.Promote(herr, "verifying package directory")
+	}
+	if got != want {
+		e := &modfile.ChecksumError{Module: key, Got: got, Want: want}
+		return &PackageError{
+			Message: errors.NewMessage("%v", []interface{}{e}),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+)
+
+// A prefetcher reads and parses CUE files concurrently, ahead of the loader.
+//
+// The loader itself remains sequential: it still determines which files
+// belong to which package and in which order instances are built, and it
+// reports all errors. It merely picks up the syntax trees parsed by the
+// prefetcher, so the result of a load does not depend on the number of
+// workers. Files that fail to parse are parsed again by the loader to
+// obtain the same errors as a sequential load.
+//
+// Whenever a file is parsed, the directories of the packages it imports are
+// scheduled as well, so that independent imports are read and parsed in
+// parallel.
+type prefetcher struct {
+	cfg  *Config
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	done chan struct{}
+
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string]*parsedFile
+}
+
+type parsedFile struct {
+	ready chan struct{}
+	file  *ast.File
+	ok    bool
+}
+
+// newPrefetcher returns a prefetcher for the given configuration, or nil if
+// concurrent loading is disabled.
+func newPrefetcher(cfg *Config) *prefetcher {
+	n := cfg.Workers
+	if n == 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n <= 1 {
+		return nil
+	}
+	return &prefetcher{
+		cfg:   cfg,
+		sem:   make(chan struct{}, n),
+		done:  make(chan struct{}),
+		dirs:  map[string]bool{},
+		files: map[string]*parsedFile{},
+	}
+}
+
+// close cancels all pending work and waits for running workers to finish.
+func (p *prefetcher) close() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+}
+
+func (p *prefetcher) canceled() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run runs f in a new goroutine once a worker is available.
+func (p *prefetcher) run(f func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.sem <- struct{}{}
+		defer func() { <-p.sem }()
+		f()
+	}()
+}
+
+// dir schedules the CUE files in the given directory to be parsed.
+func (p *prefetcher) dir(dir string) {
+	if p == nil {
+		return
+	}
+	dir = filepath.Clean(dir)
+	p.mu.Lock()
+	seen := p.dirs[dir]
+	p.dirs[dir] = true
+	p.mu.Unlock()
+	if seen {
+		return
+	}
+	p.run(func() {
+		if p.canceled() {
+			return
+		}
+		items, err := p.cfg.fileSystem.readDir(dir)
+		if err != nil {
+			return
+		}
+		for _, fi := range items {
+			name := fi.Name()
+			if fi.IsDir() || !strings.HasSuffix(name, ".cue") ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				continue
+			}
+			p.schedule(filepath.Join(dir, name))
+		}
+	})
+}
+
+// schedule schedules the given files to be parsed, unless they already are.
+func (p *prefetcher) schedule(filenames ...string) {
+	if p == nil {
+		return
+	}
+	for _, filename := range filenames {
+		p.mu.Lock()
+		if _, ok := p.files[filename]; ok {
+			p.mu.Unlock()
+			continue
+		}
+		e := &parsedFile{ready: make(chan struct{})}
+		p.files[filename] = e
+		p.mu.Unlock()
+
+		filename := filename
+		p.run(func() { p.parse(filename, e) })
+	}
+}
+
+func (p *prefetcher) parse(filename string, e *parsedFile) {
+	defer close(e.ready)
+	if p.canceled() {
+		return
+	}
+	f, err := p.cfg.fileSystem.openFile(filename)
+	if err != nil {
+		return
+	}
+	b, rerr := ioutil.ReadAll(f)
+	f.Close()
+	if rerr != nil {
+		return
+	}
+
+	// Decode the file in the same way as internal/encoding does.
+	t := unicode.BOMOverride(unicode.UTF8.NewDecoder())
+	r := transform.NewReader(bytes.NewReader(b), t)
+	var file *ast.File
+	var perr error
+	if p.cfg.ParseFile == nil {
+		file, perr = parser.ParseFile(filename, r, parser.ParseComments)
+	} else {
+		file, perr = p.cfg.ParseFile(filename, r)
+	}
+	if perr != nil || file == nil {
+		return
+	}
+	e.file, e.ok = file, true
+
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || isLocalImport(path) ||
+			!strings.Contains(strings.Split(path, "/")[0], ".") {
+			continue
+		}
+		dir, _, err := p.cfg.absDirFromImportPath(token.NoPos, importPath(path))
+		if err != nil {
+			continue
+		}
+		p.dir(dir)
+		if genDir := p.cfg.genPath(); hasFilepathPrefix(dir, genDir) {
+			rel := dir[len(genDir):]
+			for _, sub := range []string{"pkg", "usr"} {
+				p.dir(filepath.Join(p.cfg.ModuleRoot, modDir, sub) + rel)
+			}
+		}
+	}
+}
+
+// file returns the syntax tree of the given file if it was parsed
+// successfully by the prefetcher, waiting for it if it is still being parsed.
+// Each syntax tree is returned at most once, so that it is not shared between
+// instances.
+func (p *prefetcher) file(filename string) (*ast.File, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mu.Lock()
+	e := p.files[filename]
+	delete(p.files, filename)
+	p.mu.Unlock()
+	if e == nil {
+		return nil, false
+	}
+	<-e.ready
+	return e.file, e.ok
+}
+
+// isPrefetchable reports whether f is a CUE file on disk that the prefetcher
+// can parse on behalf of the loader.
+func isPrefetchable(f *build.File) bool {
+	return f.Encoding == build.CUE && f.Interpretation == "" &&
+		f.Source == nil && filepath.IsAbs(f.Filename)
+}
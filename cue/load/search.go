@@ -162,6 +162,7 @@ func (l *loader) matchPackagesInFS(pattern, pkgName string) *match {
 	// TODO(legacy): remove
 	pkgDir2 := filepath.Join(root, "pkg")
 
+	var dirs []string
 	_ = c.fileSystem.walk(root, func(path string, fi os.FileInfo, err errors.Error) errors.Error {
 		if err != nil || !fi.IsDir() {
 			return nil
@@ -191,6 +192,17 @@ func (l *loader) matchPackagesInFS(pattern, pkgName string) *match {
 		// 	return nil
 		// }
 
+		dirs = append(dirs, path)
+		return nil
+	})
+
+	// Schedule all directories to be read ahead before loading them in order.
+	for _, path := range dirs {
+		l.prefetch.dir(path)
+	}
+
+outer:
+	for _, path := range dirs {
 		// We keep the directory if we can import it, or if we can't import it
 		// due to invalid CUE source files. This means that directories
 		// containing parse errors will be built (and fail) instead of being
@@ -199,7 +211,7 @@ func (l *loader) matchPackagesInFS(pattern, pkgName string) *match {
 		// to one dir only.
 		dir, e := filepath.Rel(c.Dir, path)
 		if e != nil {
-			panic(err)
+			panic(e)
 		} else {
 			dir = "./" + dir
 		}
@@ -215,7 +227,7 @@ func (l *loader) matchPackagesInFS(pattern, pkgName string) *match {
 					if c.DataFiles && len(p.OrphanedFiles) > 0 {
 						break
 					}
-					return nil
+					continue outer
 				default:
 					m.Err = errors.Append(m.Err, err)
 				}
@@ -223,8 +235,7 @@ func (l *loader) matchPackagesInFS(pattern, pkgName string) *match {
 		}
 
 		m.Pkgs = append(m.Pkgs, pkgs...)
-		return nil
-	})
+	}
 	return m
 }
 
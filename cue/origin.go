@@ -0,0 +1,227 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cue
+
+import (
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/core/adt"
+)
+
+// An OriginKind indicates how a conjunct arrived at a value.
+type OriginKind int
+
+// Values of OriginKind.
+const (
+	// ReferenceOrigin indicates a conjunct arrived through a reference to a
+	// regular field, let, or alias.
+	ReferenceOrigin OriginKind = iota + 1
+
+	// DefinitionOrigin indicates a conjunct arrived through a reference to
+	// a definition.
+	DefinitionOrigin
+
+	// EmbeddingOrigin indicates a conjunct arrived through an embedding.
+	EmbeddingOrigin
+
+	// ConstraintOrigin indicates a conjunct arrived through a pattern
+	// constraint or the constraint of an ellipsis.
+	ConstraintOrigin
+
+	// ComprehensionOrigin indicates a conjunct was produced by a
+	// comprehension.
+	ComprehensionOrigin
+
+	// DefaultOrigin indicates a conjunct is the default of a disjunction.
+	DefaultOrigin
+)
+
+func (k OriginKind) String() string {
+	switch k {
+	case ReferenceOrigin:
+		return "reference"
+	case DefinitionOrigin:
+		return "definition"
+	case EmbeddingOrigin:
+		return "embedding"
+	case ConstraintOrigin:
+		return "pattern constraint"
+	case ComprehensionOrigin:
+		return "comprehension"
+	case DefaultOrigin:
+		return "default"
+	}
+	return "unknown"
+}
+
+// An OriginStep is a step through which a conjunct arrived at a value.
+type OriginStep struct {
+	Kind OriginKind
+
+	// Source is the reference, embedded expression, constraint,
+	// comprehension, or disjunction of the step.
+	Source ast.Node
+}
+
+// Pos reports the position of the step.
+func (s OriginStep) Pos() token.Pos {
+	return s.Source.Pos()
+}
+
+// An Origin describes a conjunct that contributed to a value.
+type Origin struct {
+	// Source is the syntax of the conjunct. This is typically the field
+	// defining the conjunct or, for conjuncts that arrived through a
+	// reference or default, the expression of the conjunct. It is nil for
+	// computed conjuncts.
+	Source ast.Node
+
+	// Via lists the steps through which the conjunct arrived at the value,
+	// starting with the step closest to the conjunct.
+	Via []OriginStep
+}
+
+// Pos reports the position of the conjunct or token.NoPos if it has no
+// source.
+func (o Origin) Pos() token.Pos {
+	if o.Source == nil {
+		return token.NoPos
+	}
+	return o.Source.Pos()
+}
+
+// Origins reports the conjuncts that contributed to v and how they arrived
+// at v. References, including the operands of a unification, are followed to
+// the conjuncts of the values they refer to. If v has a default value, the
+// defaults of disjunctions are reported instead of the disjunctions.
+//
+// Origins can be used to explain a value or a conflict, in which case v is an
+// error. The conjuncts are reported in the order in which they were added.
+func (v Value) Origins() []Origin {
+	if v.v == nil {
+		return nil
+	}
+	_, hasDefault := v.Default()
+	o := &origins{
+		ctx:      v.ctx().opCtx,
+		defaults: hasDefault,
+		visiting: map[*adt.Vertex]bool{v.v: true},
+	}
+	for _, c := range v.v.Conjuncts {
+		o.addConjunct(c, nil)
+	}
+	return o.a
+}
+
+type origins struct {
+	ctx      *adt.OpContext
+	defaults bool
+	visiting map[*adt.Vertex]bool
+	a        []Origin
+}
+
+// addConjunct adds the origins of c, which arrived through via.
+func (o *origins) addConjunct(c adt.Conjunct, via []OriginStep) {
+	var steps []OriginStep
+	for _, x := range c.CloseInfo.Origins() {
+		src := x.Location.Source()
+		if src == nil {
+			continue
+		}
+		steps = append(steps, OriginStep{Kind: spanKind(x), Source: src})
+	}
+	via = append(steps, via...)
+
+	if !o.expand(c.Env, c.Expr(), via) {
+		o.add(c.Source(), via)
+	}
+}
+
+// expand adds the origins of the operands of x, if x is a unification, a
+// reference, or a disjunction with a default, and reports whether it did so.
+func (o *origins) expand(env *adt.Environment, x adt.Expr, via []OriginStep) bool {
+	switch x := x.(type) {
+	case *adt.BinaryExpr:
+		if x.Op != adt.AndOp {
+			return false
+		}
+		o.addExpr(env, x.X, via)
+		o.addExpr(env, x.Y, via)
+		return true
+
+	case *adt.DisjunctionExpr:
+		if !o.defaults || !x.HasDefaults || x.Source() == nil {
+			return false
+		}
+		via = prepend(OriginStep{Kind: DefaultOrigin, Source: x.Source()}, via)
+		for _, d := range x.Values {
+			if d.Default {
+				o.addExpr(env, d.Val, via)
+			}
+		}
+		return true
+
+	case adt.Resolver:
+		src := x.Source()
+		if src == nil {
+			return false
+		}
+		arc, err := o.ctx.Resolve(env, x)
+		if err != nil || arc == nil || len(arc.Conjuncts) == 0 || o.visiting[arc] {
+			return false
+		}
+		kind := ReferenceOrigin
+		if adt.IsDef(x.(adt.Expr)) {
+			kind = DefinitionOrigin
+		}
+		via = prepend(OriginStep{Kind: kind, Source: src}, via)
+
+		o.visiting[arc] = true
+		for _, c := range arc.Conjuncts {
+			o.addConjunct(c, via)
+		}
+		delete(o.visiting, arc)
+		return true
+	}
+	return false
+}
+
+func (o *origins) addExpr(env *adt.Environment, x adt.Expr, via []OriginStep) {
+	if !o.expand(env, x, via) {
+		o.add(x.Source(), via)
+	}
+}
+
+func (o *origins) add(src ast.Node, via []OriginStep) {
+	o.a = append(o.a, Origin{Source: src, Via: via})
+}
+
+func prepend(s OriginStep, via []OriginStep) []OriginStep {
+	return append([]OriginStep{s}, via...)
+}
+
+func spanKind(x adt.Origin) OriginKind {
+	switch x.Span {
+	case adt.DefinitionSpan:
+		return DefinitionOrigin
+	case adt.EmbeddingSpan:
+		return EmbeddingOrigin
+	case adt.ConstraintSpan:
+		return ConstraintOrigin
+	case adt.ComprehensionSpan:
+		return ComprehensionOrigin
+	}
+	return ReferenceOrigin
+}
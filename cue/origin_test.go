@@ -0,0 +1,183 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+)
+
+func TestOrigins(t *testing.T) {
+	testCases := []struct {
+		in   string
+		path string
+		want string
+	}{{
+		in: `
+		a: 1
+		a: int
+		`,
+		path: "a",
+		want: `
+2:3 a: 1
+3:3 a: int`,
+	}, {
+		in: `
+		#D: x: int
+		a: #D & {x: 1}
+		`,
+		path: "a.x",
+		want: `
+2:7 x: int
+	definition 3:6 #D
+3:12 x: 1`,
+	}, {
+		in: `
+		b: a
+		a: 1
+		`,
+		path: "b",
+		want: `
+3:3 a: 1
+	reference 2:6 a`,
+	}, {
+		in: `
+		#D: x: int
+		b: a
+		a: #D
+		`,
+		path: "b.x",
+		want: `
+2:7 x: int
+	definition 4:6 #D
+	reference 3:6 a`,
+	}, {
+		in: `
+		a: {
+			#D
+			y: 1
+		}
+		#D: x: int
+		`,
+		path: "a.x",
+		want: `
+6:7 x: int
+	definition 3:4 #D
+	embedding 3:4 #D`,
+	}, {
+		in: `
+		a: [string]: int
+		a: b: 1
+		`,
+		path: "a.b",
+		want: `
+3:6 b: 1
+2:6 [string]: int
+	pattern constraint 2:16 int`,
+	}, {
+		in: `
+		a: {
+			for k, v in src {
+				"\(k)": v
+			}
+		}
+		src: b: 1
+		`,
+		path: "a.b",
+		want: `
+7:8 b: 1
+	reference 4:13 v
+	comprehension 3:4 *ast.ForClause`,
+	}, {
+		in: `
+		l: [for i in [1, 2] {i}]
+		`,
+		path: "l[0]",
+		want: `
+2:23 {i}
+	comprehension 2:7 *ast.ForClause`,
+	}, {
+		in: `
+		a: *1 | int
+		`,
+		path: "a",
+		want: `
+2:7 1
+	default 2:6 *1 | int`,
+	}, {
+		in: `
+		a: *1 | int
+		a: >0
+		b: a
+		`,
+		path: "b",
+		want: `
+2:7 1
+	default 2:6 *1 | int
+	reference 4:6 a
+3:3 a: >0
+	reference 4:6 a`,
+	}, {
+		in: `
+		a: 1
+		a: 2
+		`,
+		path: "a",
+		want: `
+2:3 a: 1
+3:3 a: 2`,
+	}}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			var r Runtime
+			inst, err := r.Compile("in.cue", tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := inst.Value().LookupPath(ParsePath(tc.path))
+			if !v.Exists() {
+				t.Fatalf("path %s not found", tc.path)
+			}
+
+			w := &strings.Builder{}
+			for _, o := range v.Origins() {
+				fmt.Fprintf(w, "\n%s %s", linecol(o.Pos()), formatNode(o.Source))
+				for _, s := range o.Via {
+					fmt.Fprintf(w, "\n\t%s %s %s", s.Kind, linecol(s.Pos()), formatNode(s.Source))
+				}
+			}
+			if got := w.String(); got != tc.want {
+				t.Errorf("got:%s\nwant:%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func linecol(p token.Pos) string {
+	return fmt.Sprintf("%d:%d", p.Line(), p.Column())
+}
+
+func formatNode(n ast.Node) string {
+	b, err := format.Node(n)
+	if err != nil {
+		return fmt.Sprintf("%T", n) // clauses cannot be formatted
+	}
+	return string(b)
+}
@@ -57,9 +57,16 @@ func MakePath(selectors ...Selector) Path {
 
 // ParsePath parses a CUE expression into a Path. Any error resulting from
 // this conversion can be obtained by calling Err on the result.
+//
+// In addition to selectors and index expressions, a path may contain the
+// wildcards * for AnyField, #* for AnyDefinition and [*] for AnyIndex, and
+// filters of the form [?(expr)]. See Filter and Value.Query.
 func ParsePath(s string) Path {
 	expr, err := parser.ParseExpr("", s)
 	if err != nil {
+		if isQuery(s) {
+			return parseQuery(s)
+		}
 		return MakePath(Selector{pathError{errors.Promote(err, "invalid path")}})
 	}
 
@@ -82,7 +89,7 @@ func (p Path) String() string {
 		x := sel.sel
 		// TODO: use '.' in all cases, once supported.
 		switch {
-		case x.kind() == adt.IntLabel:
+		case x.kind() == adt.IntLabel, isFilter(x):
 			b.WriteByte('[')
 			b.WriteString(x.String())
 			b.WriteByte(']')
@@ -268,6 +275,63 @@ func (s indexSelector) feature(r adt.Runtime) adt.Feature {
 	return adt.Feature(s)
 }
 
+// Wildcard selectors match multiple values. A path containing wildcards
+// can be used with Value.Query.
+var (
+	// AnyField matches any regular field of a struct. Its string
+	// representation is *.
+	AnyField = Selector{anySelector(adt.StringLabel)}
+
+	// AnyDefinition matches any definition of a struct. Its string
+	// representation is #*.
+	AnyDefinition = Selector{anySelector(adt.DefinitionLabel)}
+
+	// AnyIndex matches any element of a list. Its string representation is
+	// [*].
+	AnyIndex = Selector{anySelector(adt.IntLabel)}
+)
+
+type anySelector adt.FeatureType
+
+func (s anySelector) String() string {
+	if adt.FeatureType(s) == adt.DefinitionLabel {
+		return "#*"
+	}
+	return "*"
+}
+
+func (s anySelector) kind() adt.FeatureType { return adt.FeatureType(s) }
+
+func (s anySelector) feature(r adt.Runtime) adt.Feature {
+	return adt.InvalidLabel
+}
+
+// Filter returns a selector matching the regular fields of a struct and the
+// elements of a list for which f reports true.
+func Filter(f func(Value) bool) Selector {
+	return Selector{&filterSelector{f: f}}
+}
+
+type filterSelector struct {
+	src string // the expression of a parsed filter, if any
+	f   func(Value) bool
+}
+
+func (s *filterSelector) String() string {
+	return "?(" + s.src + ")"
+}
+
+func (s *filterSelector) kind() adt.FeatureType { return 0 }
+
+func (s *filterSelector) feature(r adt.Runtime) adt.Feature {
+	return adt.InvalidLabel
+}
+
+func isFilter(s selector) bool {
+	_, ok := s.(*filterSelector)
+	return ok
+}
+
 // TODO: allow import paths to be represented?
 //
 // // ImportPath defines a lookup at the root of an instance. It must be the first
@@ -16,6 +16,7 @@ package cue
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -124,3 +125,150 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestQuery(t *testing.T) {
+	var r Runtime
+	inst, err := r.Compile("", `
+		#Container: {
+			name:  string
+			image: string
+		}
+		#Port: int
+		deployment: {
+			web: spec: containers: [...#Container] & [{
+				name:  "web"
+				image: "nginx"
+			}, {
+				name:  "log"
+				image: "fluentd"
+			}]
+			db: spec: containers: [...#Container] & [{
+				name:  "db"
+				image: "postgres"
+			}]
+		}
+		limit: 2
+		numbers: [1, 2, 3]
+		_hidden: 4
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := inst.Value()
+
+	testCases := []struct {
+		path string
+		str  string
+		out  string // path: value pairs
+		err  string
+	}{{
+		path: "deployment.*.spec.containers[*].image",
+		out:  `deployment.web.spec.containers[0].image: "nginx" deployment.web.spec.containers[1].image: "fluentd" deployment.db.spec.containers[0].image: "postgres"`,
+	}, {
+		path: "*",
+		out:  `deployment: {...} limit: 2 numbers: [1, 2, 3]`,
+	}, {
+		path: "#*",
+		out:  `#Container: {...} #Port: int`,
+	}, {
+		path: `deployment.*.spec.containers[?(name == "log" || image == "postgres")].name`,
+		str:  `deployment.*.spec.containers[?(name == "log" || image == "postgres")].name`,
+		out:  `deployment.web.spec.containers[1].name: "log" deployment.db.spec.containers[0].name: "db"`,
+	}, {
+		// References resolve in enclosing values as well.
+		path: `deployment[?(len(spec.containers) == limit)]`,
+		out:  `deployment.web: {...}`,
+	}, {
+		// A filter that fails to evaluate does not match.
+		path: `deployment[?(nonexisting)]`,
+		out:  ``,
+	}, {
+		path: `deployment."web".spec.containers[0].*`,
+		str:  `deployment.web.spec.containers[0].*`,
+		out:  `deployment.web.spec.containers[0].name: "web" deployment.web.spec.containers[0].image: "nginx"`,
+	}, {
+		path: `numbers[*]`,
+		out:  `numbers[0]: 1 numbers[1]: 2 numbers[2]: 3`,
+	}, {
+		path: `deployment.*.[*]`,
+		err:  `invalid path deployment.*.[*]: expected label, found [`,
+	}, {
+		path: `deployment[?(a +)]`,
+		err:  `expected operand, found 'EOF'`,
+	}, {
+		path: `deployment[?(a)`,
+		err:  `invalid path deployment[?(a): unterminated filter: missing ]`,
+	}, {
+		path: `deployment[?(a`,
+		err:  `invalid path deployment[?(a: unterminated filter: missing )`,
+	}, {
+		path: `deployment.*[`,
+		err:  `invalid path deployment.*[: unterminated index`,
+	}, {
+		path: `numbers[*`,
+		err:  `invalid path numbers[*: unterminated index: missing ]`,
+	}, {
+		path: `numbers[?(true) x]`,
+		err:  `invalid path numbers[?(true) x]: expected ]`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			p := ParsePath(tc.path)
+			a, err := v.Query(p)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v; want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			str := tc.str
+			if str == "" {
+				str = tc.path
+			}
+			if got := p.String(); got != str {
+				t.Errorf("String: got %v; want %v", got, str)
+			}
+			var b []string
+			for _, w := range a {
+				s := fmt.Sprint(w)
+				if w.Kind() == StructKind {
+					s = "{...}"
+				}
+				b = append(b, fmt.Sprintf("%s: %s", w.Path(), s))
+			}
+			if got := strings.Join(b, " "); got != tc.out {
+				t.Errorf("got %s\nwant %s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestLookupQuery(t *testing.T) {
+	var r Runtime
+	inst, _ := r.Compile("", `
+		a: {x: 1}
+		b: {x: 2, y: 3}
+	`)
+	v := inst.Value()
+
+	if got := fmt.Sprint(v.LookupPath(ParsePath("*.y"))); got != "3" {
+		t.Errorf("single match: got %s; want 3", got)
+	}
+	if err := v.LookupPath(ParsePath("*.x")).Err(); err == nil ||
+		!strings.Contains(err.Error(), "matches 2 values") {
+		t.Errorf("multiple matches: got %v", err)
+	}
+	if err := v.LookupPath(ParsePath("*.z")).Err(); err == nil ||
+		!strings.Contains(err.Error(), "no values match *.z") {
+		t.Errorf("no matches: got %v", err)
+	}
+	if got := MakePath(Str("b"), Filter(func(v Value) bool {
+		i, err := v.Int64()
+		return err == nil && i > 2
+	})).String(); got != "b[?()]" {
+		t.Errorf("String: got %s", got)
+	}
+}
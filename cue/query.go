@@ -0,0 +1,285 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cue
+
+import (
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/scanner"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/core/adt"
+)
+
+// Query returns the values selected by p relative to v. Unlike with
+// LookupPath, p may contain wildcards and filters, in which case all matching
+// values are returned in order. The Path method of each returned value
+// reports its concrete path.
+//
+// Query returns an error only if p is invalid. Selectors that do not match
+// any value result in an empty slice.
+func (v Value) Query(p Path) ([]Value, error) {
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	a := []Value{v}
+	for _, sel := range p.path {
+		var next []Value
+		for _, x := range a {
+			next = x.appendMatches(next, sel.sel)
+		}
+		a = next
+	}
+	return a, nil
+}
+
+// appendMatches appends the children of v matching sel to a.
+func (v Value) appendMatches(a []Value, sel selector) []Value {
+	if v.v == nil {
+		return a
+	}
+	for _, arc := range v.v.Arcs {
+		switch x := sel.(type) {
+		case anySelector:
+			if arc.Label.Typ() != adt.FeatureType(x) {
+				continue
+			}
+		case *filterSelector:
+			if !arc.Label.IsString() && !arc.Label.IsInt() {
+				continue
+			}
+			if !x.f(makeValue(v.idx, arc)) {
+				continue
+			}
+		default:
+			if arc.Label != sel.feature(v.idx.Runtime) {
+				continue
+			}
+		}
+		a = append(a, makeValue(v.idx, arc))
+	}
+	return a
+}
+
+// lookupQuery implements LookupPath for paths with wildcards or filters,
+// which must match exactly one value.
+func (v Value) lookupQuery(p Path) Value {
+	a, err := v.Query(p)
+	switch {
+	case err != nil:
+		return newErrValue(v, &adt.Bottom{Err: errors.Promote(err, "invalid path")})
+	case len(a) == 0:
+		return newErrValue(v, v.idx.mkErr(v.v, codeNotExist,
+			"no values match %s", p))
+	case len(a) > 1:
+		return newErrValue(v, v.idx.mkErr(v.v,
+			"path %s matches %d values; use Query to obtain all", p, len(a)))
+	}
+	return a[0]
+}
+
+func (p Path) isQuery() bool {
+	for _, sel := range p.path {
+		switch sel.sel.(type) {
+		case anySelector, *filterSelector:
+			return true
+		}
+	}
+	return false
+}
+
+// isQuery reports whether s may be a path with wildcards or filters.
+func isQuery(s string) bool {
+	return strings.Contains(s, "*") || strings.Contains(s, "[?")
+}
+
+// exprFilter returns a filter selecting the values for which expr, evaluated
+// within the scope of the value, is true.
+func exprFilter(src string, expr ast.Expr) Selector {
+	return Selector{&filterSelector{src: src, f: func(v Value) bool {
+		ctx := v.idx.newContext()
+		w := newValueRoot(ctx, evalExpr(ctx, v.vertex(ctx), expr))
+		b, err := w.Bool()
+		return err == nil && b
+	}}}
+}
+
+type queryToken struct {
+	offset int
+	tok    token.Token
+	lit    string
+}
+
+// A queryParser parses paths with wildcards and filters, which cannot be
+// parsed as CUE expressions.
+type queryParser struct {
+	src  string
+	toks []queryToken
+	err  errors.Error
+}
+
+func parseQuery(s string) Path {
+	p := &queryParser{src: s}
+
+	var sc scanner.Scanner
+	f := token.NewFile("", 0, len(s))
+	sc.Init(f, []byte(s), func(pos token.Pos, msg string, args []interface{}) {
+		if p.err == nil {
+			p.err = errors.Newf(token.NoPos, msg, args...)
+		}
+	}, 0)
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.COMMA && lit == "\n" {
+			continue // automatically inserted
+		}
+		p.toks = append(p.toks, queryToken{pos.Offset(), tok, lit})
+	}
+
+	sels := p.parse()
+	if p.err != nil {
+		return MakePath(Selector{pathError{errors.Wrapf(p.err, token.NoPos,
+			"invalid path %s", s)}})
+	}
+	return MakePath(sels...)
+}
+
+func (p *queryParser) parse() (sels []Selector) {
+	for i := 0; p.err == nil && len(p.toks) > 0; i++ {
+		switch {
+		case p.got(token.LBRACK):
+			sels = append(sels, p.index())
+		case i == 0 || p.got(token.PERIOD):
+			sels = append(sels, p.label())
+		default:
+			p.errorf("unexpected %s", p.toks[0].tok)
+		}
+	}
+	return sels
+}
+
+func (p *queryParser) label() Selector {
+	t := p.next()
+	switch {
+	case t.tok == token.MUL:
+		return AnyField
+
+	case t.tok == token.IDENT && t.lit == "#" &&
+		p.peek().tok == token.MUL && p.peek().offset == t.offset+1:
+		p.next()
+		return AnyDefinition
+
+	case t.tok == token.STRING:
+		return basicLitSelector(&ast.BasicLit{Kind: token.STRING, Value: t.lit})
+
+	case t.lit != "" && ast.IsValidIdent(t.lit):
+		return identSelector(ast.NewIdent(t.lit))
+	}
+	p.errorf("expected label, found %s", t.tok)
+	return Selector{}
+}
+
+// index parses the remainder of an index or filter after the opening
+// bracket.
+func (p *queryParser) index() (sel Selector) {
+	t := p.next()
+	switch t.tok {
+	case token.MUL:
+		sel = AnyIndex
+
+	case token.INT, token.STRING:
+		sel = basicLitSelector(&ast.BasicLit{Kind: t.tok, Value: t.lit})
+
+	case token.EOF:
+		p.errorf("unterminated index")
+		return Selector{}
+
+	case token.OPTION:
+		open := p.peek()
+		if !p.got(token.LPAREN) {
+			p.errorf("expected ( after ?")
+			return Selector{}
+		}
+		depth := 1
+		for depth > 0 {
+			t = p.next()
+			switch t.tok {
+			case token.LPAREN:
+				depth++
+			case token.RPAREN:
+				depth--
+			case token.EOF:
+				p.errorf("unterminated filter: missing )")
+				return Selector{}
+			}
+		}
+		src := p.src[open.offset+1 : t.offset]
+		expr, err := parser.ParseExpr("filter", src)
+		if err != nil {
+			p.err = errors.Promote(err, "invalid filter")
+			return Selector{}
+		}
+		sel = exprFilter(strings.TrimSpace(src), expr)
+
+	default:
+		p.errorf("unexpected %s in index", t.tok)
+		return Selector{}
+	}
+	switch {
+	case p.got(token.RBRACK):
+	case p.peek().tok == token.EOF && isFilter(sel.sel):
+		p.errorf("unterminated filter: missing ]")
+	case p.peek().tok == token.EOF:
+		p.errorf("unterminated index: missing ]")
+	default:
+		p.errorf("expected ]")
+	}
+	return sel
+}
+
+func (p *queryParser) peek() queryToken {
+	if len(p.toks) == 0 {
+		return queryToken{offset: len(p.src), tok: token.EOF}
+	}
+	return p.toks[0]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.peek()
+	if len(p.toks) > 0 {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+func (p *queryParser) got(tok token.Token) bool {
+	if p.peek().tok != tok {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = errors.Newf(token.NoPos, format, args...)
+	}
+	p.toks = nil
+}
@@ -1527,8 +1527,13 @@ func (v Value) Path() Path {
 	return Path{path: a}
 }
 
-// LookupPath reports the value for path p relative to v.
+// LookupPath reports the value for path p relative to v. A path with
+// wildcards or filters must match exactly one value; use Query to obtain all
+// matching values.
 func (v Value) LookupPath(p Path) Value {
+	if p.isQuery() {
+		return v.lookupQuery(p)
+	}
 	n := v.v
 outer:
 	for _, sel := range p.path {
@@ -1342,6 +1342,67 @@ func TestSubsume(t *testing.T) {
 	}
 }
 
+func TestCheckCompatibility(t *testing.T) {
+	testCases := []struct {
+		value    string
+		backward string
+		forward  string
+	}{{
+		value: `
+		old: #A: {a: int}
+		new: #A: {a: int, b?: string}
+		`,
+		forward: "#A.b: field not allowed in closed struct",
+	}, {
+		value: `
+		old: #A: {a: int, b?: string}
+		new: #A: {a: int, b: string}
+		`,
+		backward: "#A.b: required field is optional in subsumed value",
+		forward:  "",
+	}, {
+		value: `
+		old: #A: [string]: {n: int}
+		new: #A: [string]: {n: >=0}
+		`,
+		backward: "#A.[string].n: >=0 does not subsume int",
+		forward:  "#A.[string].n: int does not subsume >=0",
+	}, {
+		value: `
+		old: a: *"x" | "y"
+		new: a: "x" | "y" | "z"
+		`,
+		forward: `a: disjunct 3: *"x" | "y" does not subsume "z"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			v := getInstance(t, tc.value).Value()
+			c := CheckCompatibility(
+				v.LookupPath(ParsePath("old")),
+				v.LookupPath(ParsePath("new")))
+
+			str := func(a []Break) string {
+				var s []string
+				for _, b := range a {
+					format, args := b.Err.Msg()
+					s = append(s, fmt.Sprintf("%s: %s",
+						strings.Join(b.Err.Path(), "."), fmt.Sprintf(format, args...)))
+				}
+				return strings.Join(s, "\n")
+			}
+			if got := str(c.Backward); got != tc.backward {
+				t.Errorf("backward: got %q; want %q", got, tc.backward)
+			}
+			if got := str(c.Forward); got != tc.forward {
+				t.Errorf("forward: got %q; want %q", got, tc.forward)
+			}
+			if c.IsBackward() != (tc.backward == "") {
+				t.Errorf("IsBackward: got %v", c.IsBackward())
+			}
+		})
+	}
+}
+
 func TestSubsumes(t *testing.T) {
 	a := []string{"a"}
 	b := []string{"b"}
@@ -0,0 +1,182 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive reads the files in zip, tar and txtar archives, so that
+// the contents of an archive can be used as a directory tree.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/txtar"
+
+	"cuelang.org/go/internal/filetypes"
+)
+
+// A File is a regular file in an archive.
+type File struct {
+	// Name is the clean, slash-separated path of the file relative to the
+	// root of the archive.
+	Name string
+	Data []byte
+}
+
+// ReadFile reads the files in the archive with the given name. The format of
+// the archive is determined by the extension of the file name, as reported by
+// filetypes.ArchiveFormat.
+func ReadFile(filename string) ([]File, error) {
+	format := filetypes.ArchiveFormat(filename)
+	if format == "" {
+		return nil, fmt.Errorf("%s: unsupported archive format", filename)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	files, err := Read(format, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return files, nil
+}
+
+// Read reads the files in an archive of the given format, which must be one
+// of "zip", "tar", "tgz" or "txtar". Directories, symbolic links and other
+// special files are skipped. If an archive contains the same file more than
+// once, the last one is used.
+func Read(format string, data []byte) ([]File, error) {
+	var a reader
+	switch format {
+	case "zip":
+		a = readZip
+	case "tar":
+		a = readTar
+	case "tgz":
+		a = readTgz
+	case "txtar":
+		a = readTxtar
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	var files []File
+	index := map[string]int{}
+	err := a(data, func(name string, data []byte) error {
+		clean, err := cleanName(name)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[clean]; ok {
+			files[i].Data = data
+			return nil
+		}
+		index[clean] = len(files)
+		files = append(files, File{Name: clean, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+type reader func(data []byte, add func(name string, data []byte) error) error
+
+// cleanName cleans the name of an archive entry and verifies that it does not
+// refer to a location outside of the archive.
+func cleanName(name string) (string, error) {
+	clean := path.Clean(strings.Replace(name, `\`, "/", -1))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") ||
+		clean == "." || strings.Contains(clean, ":") {
+		return "", fmt.Errorf("invalid file name %q in archive", name)
+	}
+	return clean, nil
+}
+
+func readZip(data []byte, add func(string, []byte) error) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+		if err := add(f.Name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTgz(data []byte, add func(string, []byte) error) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return readTar(b, add)
+}
+
+func readTar(data []byte, add func(string, []byte) error) error {
+	r := tar.NewReader(bytes.NewReader(data))
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+			continue
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", h.Name, err)
+		}
+		if err := add(h.Name, b); err != nil {
+			return err
+		}
+	}
+}
+
+func readTxtar(data []byte, add func(string, []byte) error) error {
+	for _, f := range txtar.Parse(data).Files {
+		if err := add(f.Name, f.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type entry struct {
+	name string
+	data string
+	dir  bool
+}
+
+func makeZip(t *testing.T, entries []entry) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		name := e.name
+		if e.dir {
+			name += "/"
+		}
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTar(t *testing.T, entries []entry) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Mode:     0644,
+			Size:     int64(len(e.data)),
+			Typeflag: tar.TypeReg,
+		}
+		if e.dir {
+			h.Typeflag = tar.TypeDir
+			h.Size = 0
+		}
+		if err := w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTgz(t *testing.T, entries []entry) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(makeTar(t, entries))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTxtar(t *testing.T, entries []entry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		if e.dir {
+			continue
+		}
+		buf.WriteString("-- " + e.name + " --\n" + e.data)
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	entries := []entry{
+		{name: "cue.mod", dir: true},
+		{name: "cue.mod/module.cue", data: "module: \"example.com\"\n"},
+		{name: "a.cue", data: "package a\n"},
+		{name: "./sub//b.cue", data: "package b\n"},
+		{name: "a.cue", data: "package a2\n"},
+	}
+	want := []File{
+		{Name: "cue.mod/module.cue", Data: []byte("module: \"example.com\"\n")},
+		{Name: "a.cue", Data: []byte("package a2\n")},
+		{Name: "sub/b.cue", Data: []byte("package b\n")},
+	}
+	makers := map[string]func(*testing.T, []entry) []byte{
+		"zip":   makeZip,
+		"tar":   makeTar,
+		"tgz":   makeTgz,
+		"txtar": makeTxtar,
+	}
+	for format, mk := range makers {
+		t.Run(format, func(t *testing.T) {
+			got, err := Read(format, mk(t, entries))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		data   []byte
+	}{{
+		name:   "parent",
+		format: "txtar",
+		data:   []byte("-- ../a.cue --\n"),
+	}, {
+		name:   "absolute",
+		format: "tar",
+		data:   makeTar(t, []entry{{name: "/etc/a.cue"}}),
+	}, {
+		name:   "escape",
+		format: "zip",
+		data:   makeZip(t, []entry{{name: "a/../../a.cue"}}),
+	}, {
+		name:   "corrupt",
+		format: "zip",
+		data:   []byte("not a zip file"),
+	}, {
+		name:   "format",
+		format: "rar",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Read(tc.format, tc.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
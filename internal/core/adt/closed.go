@@ -125,6 +125,30 @@ func (c CloseInfo) IsInOneOf(t SpanType) bool {
 	return c.span&t != 0
 }
 
+// An Origin records a node through which a conjunct was introduced.
+type Origin struct {
+	// Location is the reference, embedded expression, constraint, or
+	// comprehension that introduced the conjunct.
+	Location Node
+
+	// Span is the span type introduced by Location. It is 0 for references
+	// to non-definitions.
+	Span SpanType
+}
+
+// Origins reports the nodes through which the conjuncts associated with c
+// were introduced, from the innermost to the outermost.
+func (c CloseInfo) Origins() []Origin {
+	var a []Origin
+	for s := c.closeInfo; s != nil; s = s.parent {
+		if s.location == nil {
+			continue // group
+		}
+		a = append(a, Origin{Location: s.location, Span: s.root})
+	}
+	return a
+}
+
 // TODO(perf): remove: error positions should always be computed on demand
 // in dedicated error types.
 func (c *CloseInfo) AddPositions(ctx *OpContext) {
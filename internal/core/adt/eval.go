@@ -2025,11 +2025,12 @@ outer:
 		for j, elem := range l.list.Elems {
 			switch x := elem.(type) {
 			case Yielder:
+				id := l.id.SpawnSpan(x, ComprehensionSpan)
 				err := c.Yield(l.env, x, func(e *Environment, st *StructLit) {
 					label, err := MakeLabel(x.Source(), index, IntLabel)
 					n.addErr(err)
 					index++
-					c := MakeConjunct(e, st, l.id)
+					c := MakeConjunct(e, st, id)
 					n.insertField(label, c)
 				})
 				hasComprehension = true
@@ -29,9 +29,8 @@ import (
 type Config struct {
 	// Scope specifies a node in which to look up unresolved references. This
 	// is useful for evaluating expressions within an already evaluated
-	// configuration.
-	//
-	// TODO
+	// configuration. When compiling an expression, references that are not
+	// defined in Scope are looked up in its ancestors.
 	Scope *adt.Vertex
 
 	// Imports allows unresolved identifiers to resolve to imports.
@@ -101,6 +100,10 @@ type compiler struct {
 
 	fileScope map[adt.Feature]bool
 
+	// outerScope maps the labels of the ancestors of Config.Scope to the
+	// number of levels up at which they are defined.
+	outerScope map[adt.Feature]int32
+
 	num literal.NumInfo
 
 	errs errors.Error
@@ -108,6 +111,7 @@ type compiler struct {
 
 func (c *compiler) reset() {
 	c.fileScope = nil
+	c.outerScope = nil
 	c.stack = c.stack[:0]
 	c.errs = nil
 }
@@ -282,12 +286,25 @@ func (c *compiler) compileFiles(a []*ast.File) *adt.Vertex { // Or value?
 
 func (c *compiler) compileExpr(x ast.Expr) adt.Conjunct {
 	c.fileScope = map[adt.Feature]bool{}
+	c.outerScope = map[adt.Feature]int32{}
 
 	if v := c.Config.Scope; v != nil {
 		for _, arc := range v.Arcs {
 			c.fileScope[arc.Label] = true
 		}
 
+		// Allow references to fields of enclosing structs, where the
+		// innermost definition of a label takes precedence.
+		up := int32(1)
+		for p := v.Parent; p != nil; p = p.Parent {
+			for _, arc := range p.Arcs {
+				if _, ok := c.outerScope[arc.Label]; !ok && !c.fileScope[arc.Label] {
+					c.outerScope[arc.Label] = up
+				}
+			}
+			up++
+		}
+
 		c.pushScope(nil, 0, v.Source()) // File scope
 		defer c.popScope()
 	}
@@ -345,6 +362,14 @@ func (c *compiler) resolve(n *ast.Ident) adt.Expr {
 			}
 		}
 
+		if up, ok := c.outerScope[label]; ok {
+			return &adt.FieldReference{
+				Src:     n,
+				UpCount: upCount + up,
+				Label:   label,
+			}
+		}
+
 		if c.Config.Imports != nil {
 			if pkgPath := c.Config.Imports(n); pkgPath != "" {
 				return &adt.ImportReference{
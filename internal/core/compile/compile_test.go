@@ -24,6 +24,7 @@ import (
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/internal/core/compile"
 	"cuelang.org/go/internal/core/debug"
+	"cuelang.org/go/internal/core/eval"
 	"cuelang.org/go/internal/core/runtime"
 	"cuelang.org/go/internal/cuetest"
 	"cuelang.org/go/internal/cuetxtar"
@@ -75,6 +76,63 @@ func TestCompile(t *testing.T) {
 	})
 }
 
+func TestExprScope(t *testing.T) {
+	file, err := parser.ParseFile("in.cue", `
+	a: {
+		b: {c: 1, s: "b"}
+		d: 2
+		s: "a"
+	}
+	d: 3
+	e: 4
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := runtime.New()
+	root, errs := compile.Files(nil, r, "main", file)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	ctx := eval.NewContext(r, root)
+	root.Finalize(ctx)
+	scope := root.Lookup(r.StrLabel("a")).Lookup(r.StrLabel("b"))
+
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		// Fields of the scope itself.
+		{"c", "〈0;c〉"},
+		// Fields of enclosing structs.
+		{"d", "〈1;d〉"},
+		{"e", "〈2;e〉"},
+		{"{x: e}", "{\n  x: 〈3;e〉\n}"},
+		// The innermost definition of a label takes precedence.
+		{"s", "〈0;s〉"},
+		{"d + e", "(〈1;d〉 + 〈2;e〉)"},
+		{"undefined", `expr:1:1: reference "undefined" not found`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr("expr", tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, errs := compile.Expr(&compile.Config{Scope: scope}, r, "main", expr)
+			got := ""
+			if errs != nil {
+				got = errs.Error()
+			} else {
+				got = debug.NodeString(r, c.Expr(), nil)
+			}
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
 var alwaysSkip = map[string]string{
 	"fulleval/031_comparison against bottom": "fix bin op binding in test",
 }
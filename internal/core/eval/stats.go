@@ -0,0 +1,89 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval
+
+import (
+	"sort"
+	"time"
+
+	"cuelang.org/go/internal/core/adt"
+)
+
+// A Cost records the evaluator statistics and the time spent evaluating a
+// value.
+type Cost struct {
+	adt.Stats
+	Duration time.Duration
+}
+
+// Add adds the statistics and time of x to c.
+func (c *Cost) Add(x Cost) {
+	c.DisjunctCount += x.DisjunctCount
+	c.UnifyCount += x.UnifyCount
+	c.Freed += x.Freed
+	c.Retained += x.Retained
+	c.Reused += x.Reused
+	c.Allocs += x.Allocs
+	c.Duration += x.Duration
+}
+
+func since(s adt.Stats, start time.Time, ctx *adt.OpContext) Cost {
+	t := *ctx.Stats()
+	return Cost{
+		Stats: adt.Stats{
+			DisjunctCount: t.DisjunctCount - s.DisjunctCount,
+			UnifyCount:    t.UnifyCount - s.UnifyCount,
+			Freed:         t.Freed - s.Freed,
+			Retained:      t.Retained - s.Retained,
+			Reused:        t.Reused - s.Reused,
+			Allocs:        t.Allocs - s.Allocs,
+		},
+		Duration: time.Since(start),
+	}
+}
+
+// A FieldCost records the cost of evaluating a top-level field.
+type FieldCost struct {
+	Label string
+	Cost
+}
+
+// Profile evaluates v and reports the total cost of the evaluation as well as
+// the cost of evaluating each of the top-level fields of v, ordered by
+// decreasing number of unifications. The cost of evaluating values that are
+// shared between fields, such as imported packages, is attributed to the first
+// field that needs them. Profile has no effect beyond the evaluation if v was
+// already evaluated.
+func Profile(r adt.Runtime, v *adt.Vertex) (total Cost, fields []FieldCost) {
+	ctx := NewContext(r, v)
+	start := time.Now()
+	stats := *ctx.Stats()
+
+	ctx.Unify(v, adt.Partial)
+	for _, arc := range v.Arcs {
+		s, t := *ctx.Stats(), time.Now()
+		arc.Finalize(ctx)
+		fields = append(fields, FieldCost{
+			Label: arc.Label.SelectorString(r),
+			Cost:  since(s, t, ctx),
+		})
+	}
+	v.Finalize(ctx)
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].UnifyCount > fields[j].UnifyCount
+	})
+	return since(stats, start, ctx), fields
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/internal/core/compile"
+	"cuelang.org/go/internal/core/runtime"
+)
+
+func TestProfile(t *testing.T) {
+	file, err := parser.ParseFile("in.cue", `
+	small: 1
+	large: {
+		a: #D & {x: 1}
+		b: #D & {x: 2}
+		c: #D & {x: 3}
+	}
+	#D: {x: int, y: x + 1}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := runtime.New()
+	root, errs := compile.Files(nil, r, "", file)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	total, fields := Profile(r, root)
+
+	var labels []string
+	sum := 0
+	for _, f := range fields {
+		labels = append(labels, f.Label)
+		sum += f.UnifyCount
+	}
+	if len(labels) != 3 || labels[0] != "large" {
+		t.Errorf("got fields %v; want large first of 3 fields", labels)
+	}
+	if sum == 0 || total.UnifyCount < sum {
+		t.Errorf("got total of %d unifications for fields with %d",
+			total.UnifyCount, sum)
+	}
+
+	// Evaluating again has no cost.
+	if total, _ := Profile(r, root); total.UnifyCount != 0 {
+		t.Errorf("got %d unifications for evaluated value; want 0",
+			total.UnifyCount)
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subsume
+
+import (
+	"strconv"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/export"
+)
+
+// A Mismatch describes a path at which one value does not subsume another.
+type Mismatch struct {
+	path []string
+
+	// XPos and YPos hold the positions of the subsuming and subsumed values
+	// at the path of the mismatch.
+	XPos, YPos []token.Pos
+
+	// Inexact reports whether subsumption could not be decided, in which
+	// case the mismatch may be a false negative.
+	Inexact bool
+
+	errors.Message
+}
+
+// Path returns the path of the mismatch relative to the compared values.
+// Pattern constraints are denoted by their filter in brackets. Constraints for
+// additional fields or list elements, as in ...T, are denoted by [_].
+func (m *Mismatch) Path() []string { return m.path }
+
+// Position returns the first of XPos and YPos.
+func (m *Mismatch) Position() token.Pos {
+	if a := m.positions(); len(a) > 0 {
+		return a[0]
+	}
+	return token.NoPos
+}
+
+// InputPositions returns the positions following the one of Position.
+func (m *Mismatch) InputPositions() []token.Pos {
+	if a := m.positions(); len(a) > 1 {
+		return a[1:]
+	}
+	return nil
+}
+
+func (m *Mismatch) positions() []token.Pos {
+	return append(m.XPos[:len(m.XPos):len(m.XPos)], m.YPos...)
+}
+
+func (m *Mismatch) Error() string {
+	return errors.String(m)
+}
+
+// Mismatches reports all paths at which x does not subsume y, or nil if x
+// subsumes y. The result is an error list of *Mismatch values.
+//
+// Unlike Value, Mismatches descends into the values to pinpoint the
+// mismatches. Disjunctions are compared disjunct by disjunct: for each
+// disjunct of y that is not subsumed, the mismatches are reported against the
+// disjunct of x that subsumes most of it. Pattern constraints, which Value
+// does not compare, are compared for each pair of patterns of which the
+// filter of one subsumes that of the other.
+func (p *Profile) Mismatches(ctx *adt.OpContext, x, y *adt.Vertex) errors.Error {
+	m := &mismatcher{ctx: ctx, Profile: *p}
+	m.vertices(nil, x, y)
+	return m.errs
+}
+
+type mismatcher struct {
+	ctx *adt.OpContext
+	Profile
+
+	errs errors.Error
+
+	// disjuncts holds the indices of the disjuncts of y being compared, to
+	// qualify messages.
+	disjuncts []int
+
+	// xOuter and yOuter are the values of which disjuncts are being
+	// compared, for reporting positions of disjuncts that have none.
+	xOuter, yOuter *adt.Vertex
+}
+
+func (m *mismatcher) subsumes(x, y adt.Value) (ok, inexact bool) {
+	s := subsumer{ctx: m.ctx, Profile: m.Profile}
+	ok = s.values(x, y)
+	return ok, s.inexact
+}
+
+func (m *mismatcher) errf(path []string, x, y adt.Value, inexact bool, format string, args ...interface{}) {
+	for i := len(m.disjuncts) - 1; i >= 0; i-- {
+		format = "disjunct %d: " + format
+		args = append([]interface{}{m.disjuncts[i] + 1}, args...)
+	}
+	m.errs = errors.Append(m.errs, &Mismatch{
+		path:    path[:len(path):len(path)],
+		XPos:    positions(x, m.xOuter),
+		YPos:    positions(y, m.yOuter),
+		Inexact: inexact,
+		Message: errors.NewMessage(format, args),
+	})
+}
+
+// positions returns the positions of v or, if it has none, of outer.
+func positions(v adt.Value, outer *adt.Vertex) (a []token.Pos) {
+	x, ok := v.(*adt.Vertex)
+	switch {
+	case !ok:
+		if src := v.Source(); src != nil && src.Pos().IsValid() {
+			a = append(a, src.Pos())
+		}
+	default:
+		for i := range x.Conjuncts {
+			if src := x.Conjuncts[i].Source(); src != nil && src.Pos().IsValid() {
+				a = append(a, src.Pos())
+			}
+		}
+	}
+	if len(a) == 0 && outer != nil && outer != v {
+		a = positions(outer, nil)
+	}
+	return a
+}
+
+func toVertex(v adt.Value) *adt.Vertex {
+	if x, ok := v.(*adt.Vertex); ok {
+		return x
+	}
+	return &adt.Vertex{BaseValue: v}
+}
+
+func (m *mismatcher) vertices(path []string, x, y *adt.Vertex) {
+	ok, inexact := m.subsumes(x, y)
+	if ok {
+		return
+	}
+	if m.Defaults {
+		y = y.Default()
+	}
+
+	xd, _ := x.BaseValue.(*adt.Disjunction)
+	yd, _ := y.BaseValue.(*adt.Disjunction)
+	_, xStruct := x.BaseValue.(*adt.StructMarker)
+	_, yStruct := y.BaseValue.(*adt.StructMarker)
+
+	n := len(errors.Errors(m.errs))
+	defer func() {
+		// Report the mismatch at this path if it could not be pinpointed,
+		// unless subsumption failed only for lack of precision.
+		if len(errors.Errors(m.errs)) == n && !inexact {
+			m.errf(path, x, y, false, "%s does not subsume %s",
+				m.ctx.Str(x), m.ctx.Str(y))
+		}
+	}()
+
+	switch {
+	case yd != nil:
+		// Each disjunct of y must be subsumed by x.
+		for i, v := range yd.Values {
+			b := toVertex(v)
+			if ok, _ := m.subsumes(x, b); ok {
+				continue
+			}
+			m.disjuncts = append(m.disjuncts, i)
+			saved := m.yOuter
+			m.yOuter = y
+			if xd != nil {
+				m.bestDisjunct(path, x, xd, b)
+			} else {
+				m.vertices(path, x, b)
+			}
+			m.yOuter = saved
+			m.disjuncts = m.disjuncts[:len(m.disjuncts)-1]
+		}
+
+	case xd != nil:
+		m.bestDisjunct(path, x, xd, y)
+
+	case xStruct && yStruct:
+		m.structs(path, x, y)
+
+	case x.IsList() && y.IsList():
+		m.lists(path, x, y)
+
+	default:
+		m.errf(path, x, y, inexact, "%s does not subsume %s",
+			m.ctx.Str(x), m.ctx.Str(y))
+	}
+}
+
+// bestDisjunct reports the mismatches of y against the disjunct of x with the
+// fewest mismatches, or against x as a whole if none of the disjuncts
+// matches y more closely.
+func (m *mismatcher) bestDisjunct(path []string, x *adt.Vertex, xd *adt.Disjunction, y *adt.Vertex) {
+	var best []errors.Error
+	for i, v := range xd.Values {
+		sub := &mismatcher{
+			ctx:       m.ctx,
+			Profile:   m.Profile,
+			disjuncts: m.disjuncts,
+			xOuter:    x,
+			yOuter:    m.yOuter,
+		}
+		sub.vertices(path, toVertex(v), y)
+		if sub.errs == nil {
+			return
+		}
+		if a := errors.Errors(sub.errs); i == 0 || len(a) < len(best) {
+			best = a
+		}
+	}
+	for _, err := range best {
+		if len(err.Path()) > len(path) {
+			for _, err := range best {
+				m.errs = errors.Append(m.errs, err)
+			}
+			return
+		}
+	}
+	m.errf(path, x, y, false, "%s does not subsume %s", m.ctx.Str(x), m.ctx.Str(y))
+}
+
+func (m *mismatcher) structs(path []string, x, y *adt.Vertex) {
+	ctx := m.ctx
+
+	xClosed := x.IsClosed(ctx) && !m.IgnoreClosedness
+	yClosed := m.Final || (y.IsClosed(ctx) && !m.IgnoreClosedness)
+	if xClosed && !yClosed {
+		m.errf(path, x, y, false, "closed struct does not subsume open struct")
+	}
+
+	sub := func(f adt.Feature) []string {
+		return append(path[:len(path):len(path)], f.SelectorString(ctx))
+	}
+
+	xFeatures := export.VertexFeatures(x)
+	for _, f := range xFeatures {
+		if m.Final && !f.IsRegular() {
+			continue
+		}
+
+		a := x.Lookup(f)
+		aOpt := false
+		if a == nil {
+			if m.IgnoreOptional {
+				continue
+			}
+			a = &adt.Vertex{Label: f}
+			x.MatchAndInsert(ctx, a)
+			a.Finalize(ctx)
+			if a.Kind() == adt.TopKind {
+				continue
+			}
+			aOpt = true
+		}
+
+		b := y.Lookup(f)
+		if b == nil {
+			if !aOpt {
+				m.errf(sub(f), a, y, false,
+					"required field is optional in subsumed value")
+				continue
+			}
+			if !y.Accept(ctx, f) || y.IsData() || m.Final {
+				continue
+			}
+			b = &adt.Vertex{Label: f}
+			y.MatchAndInsert(ctx, b)
+			b.Finalize(ctx)
+		}
+
+		m.vertices(sub(f), a, b)
+	}
+
+outer:
+	for _, f := range export.VertexFeatures(y) {
+		if m.Final && !f.IsRegular() {
+			continue
+		}
+		for _, g := range xFeatures {
+			if g == f {
+				continue outer
+			}
+		}
+
+		b := y.Lookup(f)
+		if b == nil {
+			if m.IgnoreOptional || m.Final {
+				continue
+			}
+			b = &adt.Vertex{Label: f}
+			y.MatchAndInsert(ctx, b)
+		}
+
+		if !x.Accept(ctx, f) {
+			if !m.IgnoreClosedness {
+				m.errf(sub(f), x, b, false, "field not allowed in closed struct")
+			}
+			continue
+		}
+
+		a := &adt.Vertex{Label: f}
+		x.MatchAndInsert(ctx, a)
+		if len(a.Conjuncts) == 0 {
+			continue
+		}
+		a.Finalize(ctx)
+		b.Finalize(ctx)
+
+		m.vertices(sub(f), a, b)
+	}
+
+	if !m.Final && !m.IgnoreOptional {
+		m.patterns(path, x, y, xClosed, yClosed)
+	}
+}
+
+// A pattern is a pattern constraint [filter]: value or a constraint ...value
+// for additional fields, in which case filter is top.
+type pattern struct {
+	label  string
+	filter adt.Value
+	value  *adt.Vertex
+}
+
+func (m *mismatcher) collectPatterns(v *adt.Vertex) (a []pattern) {
+	ctx := m.ctx
+	for _, s := range v.Structs {
+		if s.Disable {
+			continue
+		}
+		s.Init()
+		for _, b := range s.Bulk {
+			filter, _ := ctx.Evaluate(s.Env, b.Filter)
+			env := *s.Env
+			env.DynamicLabel = 0
+			value := &adt.Vertex{}
+			value.AddConjunct(adt.MakeRootConjunct(&env, b))
+			value.Finalize(ctx)
+			a = append(a, pattern{
+				label:  "[" + ctx.Str(filter) + "]",
+				filter: filter,
+				value:  value,
+			})
+		}
+		for _, x := range s.Additional {
+			value := &adt.Vertex{}
+			value.AddConjunct(adt.MakeRootConjunct(s.Env, x))
+			value.Finalize(ctx)
+			a = append(a, pattern{
+				label:  "[_]",
+				filter: &adt.Top{},
+				value:  value,
+			})
+		}
+	}
+	return a
+}
+
+// patterns compares the pattern constraints of x and y, which apply to
+// fields that neither defines explicitly.
+func (m *mismatcher) patterns(path []string, x, y *adt.Vertex, xClosed, yClosed bool) {
+	xPatterns := m.collectPatterns(x)
+	yPatterns := m.collectPatterns(y)
+	if len(xPatterns) == 0 && len(yPatterns) == 0 {
+		return
+	}
+
+	covers := func(a, b adt.Value) bool {
+		s := subsumer{ctx: m.ctx}
+		return s.values(a, b)
+	}
+	sub := func(p pattern) []string {
+		return append(path[:len(path):len(path)], p.label)
+	}
+
+	for _, px := range xPatterns {
+		if px.value.Kind() == adt.TopKind && len(px.value.Arcs) == 0 {
+			continue // no constraint
+		}
+		covered := false
+		for _, py := range yPatterns {
+			yCovers := covers(py.filter, px.filter)
+			if yCovers || covers(px.filter, py.filter) {
+				m.vertices(sub(px), px.value, py.value)
+			}
+			covered = covered || yCovers
+		}
+		if !covered && !yClosed {
+			m.errf(sub(px), px.value, y, false,
+				"pattern constraint does not apply to subsumed value")
+		}
+	}
+
+	if !xClosed {
+		return
+	}
+outer:
+	for _, py := range yPatterns {
+		if isBottom(py.value.Value()) {
+			continue
+		}
+		for _, px := range xPatterns {
+			if covers(px.filter, py.filter) {
+				continue outer
+			}
+		}
+		m.errf(sub(py), x, py.value, false,
+			"fields matching pattern not allowed in closed struct")
+	}
+}
+
+func (m *mismatcher) lists(path []string, x, y *adt.Vertex) {
+	ctx := m.ctx
+	xElems := x.Elems()
+	yElems := y.Elems()
+
+	sub := func(i int) []string {
+		return append(path[:len(path):len(path)], strconv.Itoa(i))
+	}
+
+	switch {
+	case len(xElems) > len(yElems),
+		len(xElems) < len(yElems) && x.IsClosed(ctx),
+		!y.IsData() && x.IsClosed(ctx) && !y.IsClosed(ctx):
+		m.errf(path, x, y, false, "%s does not subsume %s",
+			ctx.Str(x), ctx.Str(y))
+		return
+
+	case len(xElems) < len(yElems):
+		a := elementType(ctx, x)
+		for i, b := range yElems[len(xElems):] {
+			m.vertices(sub(len(xElems)+i), a, b)
+		}
+	}
+
+	for i, a := range xElems {
+		m.vertices(sub(i), a, yElems[i])
+	}
+
+	if !x.IsClosed(ctx) && !y.IsClosed(ctx) {
+		a := elementType(ctx, x)
+		if len(a.Conjuncts) > 0 {
+			m.vertices(append(path[:len(path):len(path)], "[_]"),
+				a, elementType(ctx, y))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subsume
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/internal/core/eval"
+	"cuelang.org/go/internal/core/runtime"
+)
+
+func TestMismatches(t *testing.T) {
+	testCases := []struct {
+		x, y string
+		want string
+	}{{
+		x:    `a: int, b: string`,
+		y:    `a: 1, b: "s"`,
+		want: ``,
+	}, {
+		x: `a: int, b: string`,
+		y: `a: number, b: "s" | 1`,
+		want: `a: int does not subsume number
+b: disjunct 2: string does not subsume 1`,
+	}, {
+		x:    `#A: {a: int}`,
+		y:    `#A: {a: int, b?: int}`,
+		want: `#A.b: field not allowed in closed struct`,
+	}, {
+		x:    `#A: {a: int, b: int}`,
+		y:    `#A: {a: int, b?: int}`,
+		want: `#A.b: required field is optional in subsumed value`,
+	}, {
+		x:    `a: {kind: "x", n: int} | {kind: "y", s: string}`,
+		y:    `a: {kind: "x", n: int} | {kind: "y", s: string | int}`,
+		want: `a.s: disjunct 2: disjunct 2: string does not subsume int`,
+	}, {
+		x:    `a: [string]: int`,
+		y:    `a: [string]: int`,
+		want: ``,
+	}, {
+		x:    `a: [string]: int`,
+		y:    `a: [string]: 1`,
+		want: ``,
+	}, {
+		x:    `a: [string]: >0`,
+		y:    `a: [string]: int`,
+		want: `a.[string]: >0 does not subsume int`,
+	}, {
+		x:    `a: [=~"^x"]: int`,
+		y:    `a: [string]: int`,
+		want: ``,
+	}, {
+		x:    `a: [string]: int`,
+		y:    `a: {}`,
+		want: `a.[string]: pattern constraint does not apply to subsumed value`,
+	}, {
+		x:    `#A: [=~"^x"]: int`,
+		y:    `#A: [string]: int`,
+		want: `#A.[string]: fields matching pattern not allowed in closed struct`,
+	}, {
+		x:    `a: [...int]`,
+		y:    `a: [...string]`,
+		want: `a.[_]: int does not subsume string`,
+	}, {
+		x:    `a: [...int]`,
+		y:    `a: [1, ...1]`,
+		want: ``,
+	}, {
+		x:    `a: [N=string]: {name: N}`,
+		y:    `a: [N=string]: {name: N}`,
+		want: ``,
+	}, {
+		x:    `a: close({a: int})`,
+		y:    `a: {a: int}`,
+		want: `a: closed struct does not subsume open struct`,
+	}, {
+		x:    `a: [int, string]`,
+		y:    `a: [int, int]`,
+		want: `a.1: string does not subsume int`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.x+" ⊒ "+tc.y, func(t *testing.T) {
+			r := runtime.New()
+			ctx := eval.NewContext(r, nil)
+
+			x := parse(t, ctx, tc.x)
+			y := parse(t, ctx, tc.y)
+
+			err := CUE.Mismatches(ctx, x, y)
+			var a []string
+			for _, e := range errors.Errors(err) {
+				format, args := e.Msg()
+				a = append(a, fmt.Sprintf("%s: %s",
+					strings.Join(e.Path(), "."), fmt.Sprintf(format, args...)))
+			}
+			if got := strings.Join(a, "\n"); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -39,6 +39,10 @@ type Profile struct {
 	// IgnoreClosedness ignores closedness of structs and is used for comparing
 	// APIs.
 	IgnoreClosedness bool
+
+	// IgnoreDefaults ignores which values of disjunctions are marked as
+	// default.
+	IgnoreDefaults bool
 }
 
 var CUE = Profile{}
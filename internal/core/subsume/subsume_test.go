@@ -42,6 +42,85 @@ func TestX(t *testing.T) {
 	t.Error(err)
 }
 
+func TestProfile(t *testing.T) {
+	testCases := []struct {
+		profile  Profile
+		x, y     string
+		subsumes bool
+	}{{
+		// The element types of open lists are compared.
+		x:        `a: [...int]`,
+		y:        `a: [...int]`,
+		subsumes: true,
+	}, {
+		x:        `a: [...number]`,
+		y:        `a: [...int]`,
+		subsumes: true,
+	}, {
+		x:        `a: [...int]`,
+		y:        `a: [...number]`,
+		subsumes: false,
+	}, {
+		x:        `a: [...int]`,
+		y:        `a: [...string]`,
+		subsumes: false,
+	}, {
+		x:        `a: [...]`,
+		y:        `a: [...string]`,
+		subsumes: true,
+	}, {
+		x:        `a: [int, ...int]`,
+		y:        `a: [1, ...string]`,
+		subsumes: false,
+	}, {
+		x:        `a: [int, ...int]`,
+		y:        `a: [1, 2, ...int]`,
+		subsumes: true,
+	}, {
+		x:        `a: [...int]`,
+		y:        `a: [1, 2]`,
+		subsumes: true,
+	}, {
+		// A default of the subsumed value must be subsumed by a default.
+		x:        `a: 1 | *2`,
+		y:        `a: *1 | 2`,
+		subsumes: false,
+	}, {
+		profile:  Profile{IgnoreDefaults: true},
+		x:        `a: 1 | *2`,
+		y:        `a: *1 | 2`,
+		subsumes: true,
+	}, {
+		x:        `a: 1 | 2`,
+		y:        `a: *1 | 2`,
+		subsumes: false,
+	}, {
+		profile:  Profile{IgnoreDefaults: true},
+		x:        `a: 1 | 2`,
+		y:        `a: *1 | 2`,
+		subsumes: true,
+	}, {
+		profile:  Profile{IgnoreDefaults: true},
+		x:        `a: *1 | 2`,
+		y:        `a: *1 | 3`,
+		subsumes: false,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.x+" ⊒ "+tc.y, func(t *testing.T) {
+			r := runtime.New()
+			ctx := eval.NewContext(r, nil)
+
+			x := parse(t, ctx, tc.x).Lookup(ctx.StringLabel("a"))
+			y := parse(t, ctx, tc.y).Lookup(ctx.StringLabel("a"))
+
+			err := tc.profile.Value(ctx, x, y)
+			if got := err == nil; got != tc.subsumes {
+				t.Errorf("got %v; want %v", got, tc.subsumes)
+			}
+		})
+	}
+}
+
 func parse(t *testing.T, ctx *adt.OpContext, str string) *adt.Vertex {
 	t.Helper()
 
@@ -180,7 +180,7 @@ func (s *subsumer) values(a, b adt.Value) (result bool) {
 				// v is subsumed if any value in x subsumes v.
 				for j, a := range x.Values {
 					aDefault := j < x.NumDefaults
-					if (aDefault || !bDefault) && s.values(a, b) {
+					if (aDefault || !bDefault || s.IgnoreDefaults) && s.values(a, b) {
 						continue outerD
 					}
 				}
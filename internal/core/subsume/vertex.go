@@ -235,9 +235,7 @@ func (s *subsumer) listVertices(x, y *adt.Vertex) bool {
 	case x.IsClosed(ctx):
 		return false
 	default:
-		a := &adt.Vertex{Label: 0}
-		x.MatchAndInsert(ctx, a)
-		a.Finalize(ctx)
+		a := elementType(ctx, x)
 
 		// x must be open
 		for _, b := range yElems[len(xElems):] {
@@ -245,11 +243,13 @@ func (s *subsumer) listVertices(x, y *adt.Vertex) bool {
 				return false
 			}
 		}
+	}
 
-		if !y.IsClosed(ctx) {
-			b := &adt.Vertex{Label: adt.InvalidLabel}
-			y.MatchAndInsert(ctx, b)
-			b.Finalize(ctx)
+	// Additional elements allowed by y must be subsumed by those of x.
+	if !x.IsClosed(ctx) && !y.IsClosed(ctx) {
+		a := elementType(ctx, x)
+		if len(a.Conjuncts) > 0 && !s.vertices(a, elementType(ctx, y)) {
+			return false
 		}
 	}
 
@@ -261,3 +261,12 @@ func (s *subsumer) listVertices(x, y *adt.Vertex) bool {
 
 	return true
 }
+
+// elementType returns the constraint for elements of the open list v beyond
+// its explicit elements.
+func elementType(ctx *adt.OpContext, v *adt.Vertex) *adt.Vertex {
+	a := &adt.Vertex{Label: 0}
+	v.MatchAndInsert(ctx, a)
+	a.Finalize(ctx)
+	return a
+}
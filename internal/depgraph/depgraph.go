@@ -0,0 +1,238 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package depgraph computes the field-level dependency graph of a value.
+//
+// The nodes of the graph are the fields that reference other values or are
+// referenced by other fields. An edge from one field to another indicates
+// that the former references the latter.
+package depgraph
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/dep"
+	"cuelang.org/go/internal/core/eval"
+	"cuelang.org/go/internal/core/runtime"
+)
+
+// A Kind classifies nodes and the references to them.
+type Kind int
+
+const (
+	// Field is a regular field.
+	Field Kind = iota
+
+	// Definition is a definition or a field within a definition.
+	Definition
+
+	// Import is a value in an imported package.
+	Import
+)
+
+var kindNames = []string{
+	Field:      "field",
+	Definition: "definition",
+	Import:     "import",
+}
+
+func (k Kind) String() string { return kindNames[k] }
+
+// MarshalText implements encoding.TextMarshaler.
+func (k Kind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
+// A Graph is a dependency graph.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+
+	nodes  map[string]*Node
+	listed map[*Node]bool
+	edges  map[Edge]bool
+}
+
+// A Node is a field or a value in an imported package.
+type Node struct {
+	// ID uniquely identifies the node. It is the path of the field or, for
+	// values in imported packages, the import path followed by the path of
+	// the value within the package.
+	ID string `json:"id"`
+
+	Kind Kind `json:"kind"`
+
+	// Package is the import path of the package of a value in an imported
+	// package.
+	Package string `json:"package,omitempty"`
+
+	// Path is the path of the value within its package.
+	Path string `json:"path"`
+}
+
+// An Edge is a reference from one node to another. Its Kind is the kind of
+// the referenced node.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind Kind   `json:"kind"`
+}
+
+// Build computes the dependency graph of the fields of v.
+//
+// If depth is positive, only fields up to the given depth below v are
+// included and the references of deeper fields are attributed to their
+// ancestor at that depth. References to such fields are likewise attributed
+// to their ancestor. References to values outside of v are included.
+func Build(v cue.Value, depth int) (*Graph, error) {
+	r, x := internal.CoreValue(v)
+	root, ok := x.(*adt.Vertex)
+	if !ok {
+		return nil, fmt.Errorf("invalid value")
+	}
+	b := &builder{
+		ctx:   eval.NewContext(r.(*runtime.Runtime), root),
+		root:  root,
+		depth: depth,
+		g: &Graph{
+			Nodes:  []*Node{},
+			Edges:  []*Edge{},
+			nodes:  map[string]*Node{},
+			listed: map[*Node]bool{},
+			edges:  map[Edge]bool{},
+		},
+	}
+	if err := b.visit(root, 1, nil); err != nil {
+		return nil, err
+	}
+	return b.g, nil
+}
+
+type builder struct {
+	ctx   *adt.OpContext
+	root  *adt.Vertex
+	depth int
+	g     *Graph
+}
+
+// visit adds the references of the arcs of n, which are at the given depth
+// below the root. If owner is not nil, the references are attributed to it.
+func (b *builder) visit(n *adt.Vertex, level int, owner *Node) error {
+	for _, arc := range n.Arcs {
+		from := owner
+		if from == nil {
+			from = b.node(arc)
+		}
+		err := dep.Visit(b.ctx, arc, func(d dep.Dependency) error {
+			if to := b.target(d); to != nil && to != from {
+				b.g.addEdge(from, to)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		next := owner
+		if next == nil && b.depth > 0 && level >= b.depth {
+			next = from
+		}
+		if err := b.visit(arc, level+1, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target returns the node for the value referenced by d.
+func (b *builder) target(d dep.Dependency) *Node {
+	if ref := d.Import(); ref != nil {
+		pkg := ref.ImportPath.StringValue(b.ctx)
+		path := b.path(d.Node)
+		id := pkg
+		if path != "" {
+			id += "." + path
+		}
+		return b.add(&Node{ID: id, Kind: Import, Package: pkg, Path: path})
+	}
+	n := d.Node
+	if b.depth > 0 {
+		// Attribute references to fields below the maximum depth to their
+		// ancestor at that depth.
+		for level := b.level(n); level > b.depth; level-- {
+			n = n.Parent
+		}
+	}
+	if n == b.root || n.Parent == nil {
+		return nil
+	}
+	return b.node(n)
+}
+
+// level returns the depth of n below the root or 0 if n is not a
+// descendant of the root.
+func (b *builder) level(n *adt.Vertex) int {
+	level := 0
+	for ; n != nil; n = n.Parent {
+		if n == b.root {
+			return level
+		}
+		level++
+	}
+	return 0
+}
+
+// node returns the node for the field n.
+func (b *builder) node(n *adt.Vertex) *Node {
+	kind := Field
+	for _, f := range n.Path() {
+		if f.IsDef() {
+			kind = Definition
+		}
+	}
+	path := b.path(n)
+	return b.add(&Node{ID: path, Kind: kind, Path: path})
+}
+
+func (b *builder) path(n *adt.Vertex) string {
+	return cue.MakeValue(b.ctx, n).Path().String()
+}
+
+// addEdge adds an edge from one node to another, along with the nodes
+// themselves, if it does not exist yet.
+func (g *Graph) addEdge(from, to *Node) {
+	e := Edge{From: from.ID, To: to.ID, Kind: to.Kind}
+	if g.edges[e] {
+		return
+	}
+	g.edges[e] = true
+	for _, n := range []*Node{from, to} {
+		if !g.listed[n] {
+			g.listed[n] = true
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+	g.Edges = append(g.Edges, &e)
+}
+
+// add registers n as a node, unless a node with the same ID already exists,
+// and returns the registered node. Nodes are only included in the graph once
+// they are part of an edge.
+func (b *builder) add(n *Node) *Node {
+	if x, ok := b.g.nodes[n.ID]; ok {
+		return x
+	}
+	b.g.nodes[n.ID] = n
+	return n
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	_ "cuelang.org/go/pkg"
+)
+
+const config = `
+import "strings"
+
+#Port: int & >0
+#Server: {
+	name: string
+	port: #Port
+}
+
+host: strings.ToLower("Example.COM")
+servers: {
+	web: #Server & {name: host, port: 80}
+	api: #Server & {name: "api." + host, port: servers.web.port + 1}
+}
+`
+
+func build(t *testing.T, path string, depth int) *Graph {
+	var r cue.Runtime
+	inst, err := r.Compile("config.cue", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := inst.Value()
+	if path != "" {
+		v = v.LookupPath(cue.ParsePath(path))
+	}
+	g, err := Build(v, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func edges(g *Graph) string {
+	var a []string
+	for _, e := range g.Edges {
+		a = append(a, fmt.Sprintf("%s -> %s (%s)", e.From, e.To, e.Kind))
+	}
+	return strings.Join(a, "\n")
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name  string
+		path  string
+		depth int
+		want  string
+	}{{
+		name: "all",
+		want: `#Server.port -> #Port (definition)
+host -> strings.ToLower (import)
+servers.web -> #Server (definition)
+servers.web.name -> host (field)
+servers.web.port -> #Port (definition)
+servers.api -> #Server (definition)
+servers.api.name -> host (field)
+servers.api.port -> #Port (definition)
+servers.api.port -> servers.web.port (field)`,
+	}, {
+		name:  "depth",
+		depth: 1,
+		want: `#Server -> #Port (definition)
+host -> strings.ToLower (import)
+servers -> #Server (definition)
+servers -> host (field)
+servers -> #Port (definition)`,
+	}, {
+		name: "path",
+		path: "servers.api",
+		want: `servers.api.name -> host (field)
+servers.api.port -> #Port (definition)
+servers.api.port -> servers.web.port (field)`,
+	}, {
+		name:  "path and depth",
+		path:  "servers",
+		depth: 1,
+		want: `servers.web -> #Server (definition)
+servers.web -> host (field)
+servers.web -> #Port (definition)
+servers.api -> #Server (definition)
+servers.api -> host (field)
+servers.api -> #Port (definition)
+servers.api -> servers.web (field)`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := edges(build(t, tc.path, tc.depth))
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g := build(t, "servers.api", 0)
+
+	testCases := []struct {
+		name  string
+		write func(g *Graph, b *bytes.Buffer) error
+		want  string
+	}{{
+		name:  "dot",
+		write: func(g *Graph, b *bytes.Buffer) error { return g.WriteDOT(b) },
+		want: `digraph deps {
+	"servers.api.name";
+	"host";
+	"servers.api.port";
+	"#Port" [shape=box];
+	"servers.web.port";
+	"servers.api.name" -> "host";
+	"servers.api.port" -> "#Port" [style=dashed];
+	"servers.api.port" -> "servers.web.port";
+}
+`,
+	}, {
+		name:  "mermaid",
+		write: func(g *Graph, b *bytes.Buffer) error { return g.WriteMermaid(b) },
+		want: `graph LR
+	n0["servers.api.name"]
+	n1["host"]
+	n2["servers.api.port"]
+	n3[["#Port"]]
+	n4["servers.web.port"]
+	n0 --> n1
+	n2 -.-> n3
+	n2 --> n4
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tc.write(g, &b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := build(t, "", 1).WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`{
+            "id": "strings.ToLower",
+            "kind": "import",
+            "package": "strings",
+            "path": "ToLower"
+        }`, `{
+            "from": "servers",
+            "to": "#Server",
+            "kind": "definition"
+        }`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %s:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes g in the Graphviz DOT language. Definitions are drawn as
+// boxes and values in imported packages as notes. References to definitions
+// are dashed and references to imported packages are dotted.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("digraph deps {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "\t%s", strconv.Quote(n.ID))
+		switch n.Kind {
+		case Definition:
+			b.WriteString(" [shape=box]")
+		case Import:
+			b.WriteString(" [shape=note]")
+		}
+		b.WriteString(";\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		switch e.Kind {
+		case Definition:
+			b.WriteString(" [style=dashed]")
+		case Import:
+			b.WriteString(" [style=dotted]")
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteMermaid writes g as a Mermaid flowchart. Definitions are drawn as
+// subroutines and values in imported packages as cylinders. References to
+// definitions are dotted and references to imported packages are thick.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("graph LR\n")
+	ids := map[string]string{}
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+		label := mermaidLabel(n.ID)
+		switch n.Kind {
+		case Definition:
+			fmt.Fprintf(&b, "\t%s[[%s]]\n", id, label)
+		case Import:
+			fmt.Fprintf(&b, "\t%s[(%s)]\n", id, label)
+		default:
+			fmt.Fprintf(&b, "\t%s[%s]\n", id, label)
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		switch e.Kind {
+		case Definition:
+			arrow = "-.->"
+		case Import:
+			arrow = "==>"
+		}
+		fmt.Fprintf(&b, "\t%s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// mermaidLabel quotes s for use as a label in Mermaid, which does not
+// support escaping quotes with backslashes.
+func mermaidLabel(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
+
+// WriteJSON writes g as a JSON object with a list of nodes and a list of
+// edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(g, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
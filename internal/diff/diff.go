@@ -173,7 +173,9 @@ func (d *differ) diffValue(x, y cue.Value) (Kind, *EditScript) {
 		case cue.ListKind:
 			return d.diffList(x, y)
 		}
-		fallthrough
+		if !x.Equals(y) {
+			return Modified, nil
+		}
 
 	default:
 		// In concrete mode we do not care about non-concrete values.
@@ -61,6 +61,18 @@ func TestDiff(t *testing.T) {
 		x:    `"foo"`,
 		y:    `"bar"`,
 		kind: Modified,
+	}, {
+		name:    "modified final value",
+		x:       `{a: *1 | int, b: int}`,
+		y:       `{a: 2, b: int}`,
+		kind:    Modified,
+		profile: Final,
+		diff: `  {
+-     a: *1 | int
++     a: 2
+      b: int
+  }
+`,
 	}, {
 		name: "basics",
 		x: `{
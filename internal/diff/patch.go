@@ -0,0 +1,195 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/internal"
+)
+
+// Patches only consider the data of a value: definitions, hidden fields and
+// optional fields are ignored.
+
+// JSONPatch returns an RFC 6902 JSON Patch that transforms x into y. The
+// patch is returned as a CUE list of operations. It is empty if x and y
+// are equal.
+func (p *Profile) JSONPatch(x, y cue.Value) ast.Expr {
+	kind, es := p.Diff(x, y)
+	jp := &jsonPatch{}
+	switch {
+	case kind == Identity:
+	case es == nil:
+		jp.add("replace", "", y)
+	default:
+		jp.script("", es)
+	}
+	return ast.NewList(jp.ops...)
+}
+
+type jsonPatch struct {
+	ops []ast.Expr
+}
+
+func (p *jsonPatch) add(op, path string, v cue.Value) {
+	fields := []interface{}{"op", ast.NewString(op), "path", ast.NewString(path)}
+	if op != "remove" {
+		fields = append(fields, "value", valueExpr(v))
+	}
+	p.ops = append(p.ops, ast.NewStruct(fields...))
+}
+
+func (p *jsonPatch) script(path string, es *EditScript) {
+	switch es.x.Kind() {
+	case cue.StructKind:
+		p.structScript(path, es)
+	case cue.ListKind:
+		p.listScript(path, es)
+	}
+}
+
+func (p *jsonPatch) structScript(path string, es *EditScript) {
+	sx, sy := structs(es)
+	for _, e := range es.edits {
+		xf, xok := regularField(sx, e.XPos())
+		yf, yok := regularField(sy, e.YPos())
+		switch e.kind {
+		case UniqueX:
+			if xok {
+				p.add("remove", path+"/"+escapePointer(xf.Name), cue.Value{})
+			}
+		case UniqueY:
+			if yok {
+				p.add("add", path+"/"+escapePointer(yf.Name), yf.Value)
+			}
+		case Modified:
+			switch {
+			case xok && yok && e.sub != nil:
+				p.script(path+"/"+escapePointer(xf.Name), e.sub)
+			case xok && yok:
+				p.add("replace", path+"/"+escapePointer(xf.Name), yf.Value)
+			case xok:
+				p.add("remove", path+"/"+escapePointer(xf.Name), cue.Value{})
+			case yok:
+				p.add("add", path+"/"+escapePointer(yf.Name), yf.Value)
+			}
+		}
+	}
+}
+
+func (p *jsonPatch) listScript(path string, es *EditScript) {
+	y := getElems(es.y)
+
+	// Elements that only exist in x are trailing. Remove them back to
+	// front so that the indices of the remaining elements remain valid.
+	var removed []string
+	for _, e := range es.edits {
+		switch e.kind {
+		case UniqueX:
+			removed = append(removed, path+"/"+strconv.Itoa(e.XPos()))
+		case UniqueY:
+			p.add("add", path+"/"+strconv.Itoa(e.YPos()), y[e.YPos()])
+		case Modified:
+			elem := path + "/" + strconv.Itoa(e.XPos())
+			if e.sub != nil {
+				p.script(elem, e.sub)
+			} else {
+				p.add("replace", elem, y[e.YPos()])
+			}
+		}
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		p.add("remove", removed[i], cue.Value{})
+	}
+}
+
+// MergePatch returns an RFC 7386 JSON merge patch that transforms x into y.
+// The patch is returned as a CUE expression. It is an empty struct if x and
+// y are equal.
+//
+// Merge patches cannot express changes to list elements: lists that differ
+// are replaced as a whole. Likewise, fields with a null value in y cannot be
+// represented, as null indicates the removal of a field.
+func (p *Profile) MergePatch(x, y cue.Value) ast.Expr {
+	kind, es := p.Diff(x, y)
+	switch {
+	case kind == Identity:
+		return ast.NewStruct()
+	case es == nil || es.x.Kind() != cue.StructKind:
+		return valueExpr(y)
+	}
+	return mergePatch(es)
+}
+
+func mergePatch(es *EditScript) ast.Expr {
+	var fields []interface{}
+	sx, sy := structs(es)
+	for _, e := range es.edits {
+		xf, xok := regularField(sx, e.XPos())
+		yf, yok := regularField(sy, e.YPos())
+		switch e.kind {
+		case UniqueX:
+			if xok {
+				fields = append(fields, xf.Name, ast.NewNull())
+			}
+		case UniqueY:
+			if yok {
+				fields = append(fields, yf.Name, valueExpr(yf.Value))
+			}
+		case Modified:
+			switch {
+			case xok && yok && e.sub != nil && e.sub.x.Kind() == cue.StructKind:
+				if s := mergePatch(e.sub).(*ast.StructLit); len(s.Elts) > 0 {
+					fields = append(fields, xf.Name, s)
+				}
+			case yok:
+				fields = append(fields, yf.Name, valueExpr(yf.Value))
+			case xok:
+				fields = append(fields, xf.Name, ast.NewNull())
+			}
+		}
+	}
+	return ast.NewStruct(fields...)
+}
+
+// structs returns the structs of x and y of a struct edit script.
+func structs(es *EditScript) (x, y *cue.Struct) {
+	x, _ = es.x.Struct()
+	y, _ = es.y.Struct()
+	return x, y
+}
+
+// regularField returns the field at position i, if any, and reports whether
+// it is a regular field.
+func regularField(st *cue.Struct, i int) (f cue.FieldInfo, ok bool) {
+	if i < 0 || st == nil {
+		return f, false
+	}
+	f = st.Field(i)
+	return f, !f.IsDefinition && !f.IsHidden && !f.IsOptional
+}
+
+func valueExpr(v cue.Value) ast.Expr {
+	return internal.ToExpr(v.Syntax(cue.Final(), cue.Concrete(true)))
+}
+
+// escapePointer escapes a reference token of an RFC 6901 JSON Pointer.
+func escapePointer(s string) string {
+	s = strings.Replace(s, "~", "~0", -1)
+	return strings.Replace(s, "/", "~1", -1)
+}
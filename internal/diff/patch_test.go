@@ -0,0 +1,124 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+)
+
+func TestPatch(t *testing.T) {
+	testCases := []struct {
+		name  string
+		x, y  string
+		json  string
+		merge string
+	}{{
+		name:  "identity",
+		x:     `{a: 1, b: [1, 2]}`,
+		y:     `{b: [1, 2], a: 1}`,
+		json:  `[]`,
+		merge: `{}`,
+	}, {
+		name:  "scalar",
+		x:     `1`,
+		y:     `"a"`,
+		json:  `[{"op":"replace","path":"","value":"a"}]`,
+		merge: `"a"`,
+	}, {
+		name: "fields",
+		x:    `{a: 1, b: 2, c: {d: 1, e: 2}}`,
+		y:    `{a: 1, c: {d: 3, e: 2}, f: {g: true}}`,
+		json: `[` +
+			`{"op":"remove","path":"/b"},` +
+			`{"op":"replace","path":"/c/d","value":3},` +
+			`{"op":"add","path":"/f","value":{"g":true}}]`,
+		merge: `{"b":null,"c":{"d":3},"f":{"g":true}}`,
+	}, {
+		name: "escape",
+		x:    `{"a/b": 1, "c~d": 1}`,
+		y:    `{"a/b": 2}`,
+		json: `[` +
+			`{"op":"replace","path":"/a~1b","value":2},` +
+			`{"op":"remove","path":"/c~0d"}]`,
+		merge: `{"a/b":2,"c~d":null}`,
+	}, {
+		name: "lists",
+		x:    `{a: [1, 2, 3, 4], b: [{c: 1}], d: [1]}`,
+		y:    `{a: [1, 5], b: [{c: 2}], d: [1, 2, 3]}`,
+		json: `[` +
+			`{"op":"replace","path":"/a/1","value":5},` +
+			`{"op":"remove","path":"/a/3"},` +
+			`{"op":"remove","path":"/a/2"},` +
+			`{"op":"replace","path":"/b/0/c","value":2},` +
+			`{"op":"add","path":"/d/1","value":2},` +
+			`{"op":"add","path":"/d/2","value":3}]`,
+		merge: `{"a":[1,5],"b":[{"c":2}],"d":[1,2,3]}`,
+	}, {
+		name:  "kind change",
+		x:     `{a: {b: 1}}`,
+		y:     `{a: [1]}`,
+		json:  `[{"op":"replace","path":"/a","value":[1]}]`,
+		merge: `{"a":[1]}`,
+	}, {
+		name:  "non-data fields",
+		x:     `{a: 1, #D: 1, _h: 1, o?: 1, p?: 1}`,
+		y:     `{a: 1, #D: 2, _h: 2, o: 1, q?: 2}`,
+		json:  `[{"op":"add","path":"/o","value":1}]`,
+		merge: `{"o":1}`,
+	}, {
+		name:  "defaults",
+		x:     `{a: *1 | int}`,
+		y:     `{a: *2 | int}`,
+		json:  `[{"op":"replace","path":"/a","value":2}]`,
+		merge: `{"a":2}`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r cue.Runtime
+			x, err := r.Compile("x", tc.x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			y, err := r.Compile("y", tc.y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toJSON := func(expr ast.Expr) string {
+				inst, err := r.CompileExpr(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := json.Marshal(inst.Value())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(b)
+			}
+
+			got := toJSON(Final.JSONPatch(x.Value(), y.Value()))
+			if got != tc.json {
+				t.Errorf("JSON patch:\ngot  %s\nwant %s", got, tc.json)
+			}
+			got = toJSON(Final.MergePatch(x.Value(), y.Value()))
+			if got != tc.merge {
+				t.Errorf("merge patch:\ngot  %s\nwant %s", got, tc.merge)
+			}
+		})
+	}
+}
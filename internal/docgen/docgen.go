@@ -0,0 +1,493 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package docgen generates documentation for CUE packages.
+//
+// The documentation of a package lists its definitions and fields with
+// their types, defaults, constraints, attributes and doc comments. It is
+// rendered as Markdown or HTML by a Site, which links references to
+// definitions and to other packages, including the builtin packages.
+package docgen
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/runtime"
+)
+
+// A Package holds the documentation of a package.
+type Package struct {
+	ImportPath string
+	Name       string
+	Doc        string
+
+	// Dir is the directory of a package that cannot be imported, such as a
+	// package outside of a module. If set, it identifies the package in
+	// place of ImportPath.
+	Dir string
+
+	// Builtin reports whether this is a builtin package.
+	Builtin bool
+
+	// Fields holds the top-level fields and definitions in evaluation order.
+	Fields []*Field
+
+	imports map[string]string // import name to import path
+	defs    map[string]bool   // paths of all documented definitions
+}
+
+// path returns the path that identifies p in the documentation.
+func (p *Package) path() string {
+	if p.Dir != "" {
+		return p.Dir
+	}
+	return p.ImportPath
+}
+
+// A Field holds the documentation of a field, definition or pattern
+// constraint.
+type Field struct {
+	// Name is the label of the field as written in CUE, including a ? for
+	// optional fields. Pattern constraints are written as [filter] and
+	// constraints for additional fields as ....
+	Name string
+
+	// Path is the path of the field within its package.
+	Path string
+
+	Doc string
+
+	// Type is the constraint for the field as written in CUE, excluding
+	// struct literals, which are documented as Fields instead. It is the
+	// signature for builtin functions and the kind if there are no other
+	// constraints.
+	Type string
+
+	// Default is the default value, if any.
+	Default string
+
+	// Attrs holds the attributes of the field as written in CUE.
+	Attrs []string
+
+	// Refs holds the references to definitions and packages within Type.
+	Refs []Ref
+
+	Optional   bool
+	Definition bool
+	Function   bool
+
+	// Fields holds the documentation of the fields of a struct literal.
+	Fields []*Field
+}
+
+// A Ref is a reference to a definition or a package member within the Type
+// of a field.
+type Ref struct {
+	// Offset and Len give the location of the reference within Type.
+	Offset, Len int
+
+	// Package is the import path of the referenced package or "" for
+	// references within the same package.
+	Package string
+
+	// Path is the path of the referenced value within its package.
+	Path string
+}
+
+// Extract returns the documentation for the package with the given import
+// path, files and evaluated value.
+func Extract(importPath string, files []*ast.File, v cue.Value) *Package {
+	p := &Package{
+		ImportPath: importPath,
+		imports:    map[string]string{},
+		defs:       map[string]bool{},
+	}
+	var docs []string
+	for _, f := range files {
+		if name := f.PackageName(); name != "" {
+			p.Name = name
+		}
+		if c := internal.FileComment(f); c != nil {
+			docs = append(docs, c.Text())
+		}
+		for _, spec := range f.Imports {
+			path, err := literalString(spec.Path)
+			if err != nil {
+				continue
+			}
+			name := importName(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			p.imports[name] = path
+		}
+	}
+	p.Doc = strings.TrimSpace(strings.Join(docs, "\n"))
+	p.Fields = p.fields("", v)
+	p.resolveAll(p.Fields, "")
+	return p
+}
+
+// Builtin returns the documentation for the builtin package with the given
+// import path.
+func Builtin(importPath string) (*Package, error) {
+	var r cue.Runtime
+	inst, err := r.Compile("doc.cue",
+		fmt.Sprintf("import pkg %q\nvalue: pkg\n", importPath))
+	if err != nil {
+		return nil, err
+	}
+	v := inst.Value().LookupPath(cue.ParsePath("value"))
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	p := &Package{
+		ImportPath: importPath,
+		Name:       importName(importPath),
+		Builtin:    true,
+		imports:    map[string]string{},
+		defs:       map[string]bool{},
+	}
+	p.Fields = p.fields("", v)
+	p.resolveAll(p.Fields, "")
+	return p, nil
+}
+
+// IsBuiltin reports whether importPath is the import path of a builtin
+// package.
+func IsBuiltin(importPath string) bool {
+	return runtime.SharedRuntime.IsBuiltinPackage(importPath)
+}
+
+// importName returns the default name of an import path.
+func importName(importPath string) string {
+	if i := strings.LastIndexByte(importPath, ':'); i >= 0 {
+		return importPath[i+1:]
+	}
+	return path.Base(importPath)
+}
+
+func literalString(x *ast.BasicLit) (string, error) {
+	if x == nil {
+		return "", fmt.Errorf("missing import path")
+	}
+	return literal.Unquote(x.Value)
+}
+
+// fields documents the fields of the struct v at the given path.
+func (p *Package) fields(prefix string, v cue.Value) (a []*Field) {
+	iter, err := v.Fields(cue.Optional(true), cue.Definitions(true))
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		label := iter.Label()
+		if strings.HasPrefix(label, "_") {
+			continue // hidden
+		}
+		f := &Field{
+			Name:       label,
+			Path:       join(prefix, label),
+			Optional:   iter.IsOptional(),
+			Definition: iter.IsDefinition(),
+		}
+		if f.Optional {
+			f.Name += "?"
+		}
+		if f.Definition {
+			p.defs[f.Path] = true
+		}
+		p.field(f, iter.Value())
+		a = append(a, f)
+	}
+	return a
+}
+
+func join(prefix, label string) string {
+	if prefix == "" {
+		return label
+	}
+	return prefix + "." + label
+}
+
+func (p *Package) field(f *Field, v cue.Value) {
+	f.Doc = docText(v.Doc())
+
+	_, x := internal.CoreValue(v)
+	vertex, _ := x.(*adt.Vertex)
+	if vertex == nil {
+		return
+	}
+	if b, ok := vertex.BaseValue.(*adt.Builtin); ok {
+		f.Function = true
+		f.Type = signature(b)
+		return
+	}
+
+	var types []string
+	var structs []*ast.StructLit
+	for _, c := range vertex.Conjuncts {
+		// Only document the constraints declared for the field itself and
+		// not, for instance, those of matching pattern constraints.
+		src, ok := c.Source().(*ast.Field)
+		if !ok {
+			continue
+		}
+		if _, ok := src.Label.(*ast.ListLit); ok {
+			continue
+		}
+		for _, attr := range src.Attrs {
+			f.Attrs = appendUnique(f.Attrs, attr.Text)
+		}
+		switch x := src.Value.(type) {
+		case *ast.StructLit:
+			structs = append(structs, x)
+		default:
+			types = appendUnique(types, exprString(x))
+		}
+	}
+
+	if len(structs) > 0 && v.IncompleteKind() == cue.StructKind {
+		f.Fields = p.fields(f.Path, v)
+		for _, s := range structs {
+			f.Fields = append(f.Fields, p.patterns(f.Path, s)...)
+		}
+	}
+
+	f.Type = strings.Join(types, " & ")
+	if f.Type == "" {
+		f.Type = v.IncompleteKind().String()
+		if s := scalarString(v); s != "" {
+			f.Type = s
+		}
+	}
+	if d, ok := v.Default(); ok {
+		f.Default = scalarString(d)
+	}
+}
+
+// scalarString returns the CUE representation of v if it is a concrete
+// scalar or list or "" otherwise.
+func scalarString(v cue.Value) string {
+	if !v.IsConcrete() || v.Kind() == cue.StructKind {
+		return ""
+	}
+	x, ok := v.Syntax(cue.Final()).(ast.Expr)
+	if !ok {
+		return ""
+	}
+	return exprString(x)
+}
+
+// patterns documents the pattern constraints and constraints for
+// additional fields of a struct literal.
+func (p *Package) patterns(prefix string, s *ast.StructLit) (a []*Field) {
+	for _, elt := range s.Elts {
+		var name string
+		var value ast.Expr
+		var attrs []*ast.Attribute
+		switch x := elt.(type) {
+		case *ast.Field:
+			l, ok := x.Label.(*ast.ListLit)
+			if !ok || len(l.Elts) != 1 {
+				continue
+			}
+			name = "[" + exprString(l.Elts[0]) + "]"
+			value, attrs = x.Value, x.Attrs
+		case *ast.Ellipsis:
+			name, value = "...", x.Type
+			if value == nil {
+				continue
+			}
+		default:
+			continue
+		}
+		f := &Field{
+			Name: name,
+			Path: join(prefix, name),
+			Doc:  docText(ast.Comments(elt)),
+		}
+		for _, attr := range attrs {
+			f.Attrs = append(f.Attrs, attr.Text)
+		}
+		if _, ok := value.(*ast.StructLit); ok {
+			f.Type = "struct"
+		} else {
+			f.Type = exprString(value)
+		}
+		a = append(a, f)
+	}
+	return a
+}
+
+func appendUnique(a []string, s string) []string {
+	for _, x := range a {
+		if x == s {
+			return a
+		}
+	}
+	return append(a, s)
+}
+
+func docText(groups []*ast.CommentGroup) string {
+	var a []string
+	for _, g := range groups {
+		if g.Doc || g.Position == 0 {
+			if s := strings.TrimSpace(g.Text()); s != "" {
+				a = appendUnique(a, s)
+			}
+		}
+	}
+	return strings.Join(a, "\n\n")
+}
+
+func signature(b *adt.Builtin) string {
+	var params []string
+	for _, p := range b.Params {
+		params = append(params, p.Kind().String())
+	}
+	return fmt.Sprintf("func(%s) %s", strings.Join(params, ", "), b.Result)
+}
+
+var space = regexp.MustCompile(`\s*\n\s*`)
+
+// exprString formats x on a single line. Non-empty struct literals are
+// abbreviated to {...}.
+func exprString(x ast.Expr) string {
+	x = astutil.Apply(x, func(c astutil.Cursor) bool {
+		if s, ok := c.Node().(*ast.StructLit); ok && len(s.Elts) > 0 {
+			c.Replace(ast.NewStruct(&ast.Ellipsis{}))
+			return false
+		}
+		return true
+	}, nil).(ast.Expr)
+	b, err := format.Node(x)
+	if err != nil {
+		return ""
+	}
+	s := space.ReplaceAllString(string(b), " ")
+	return strings.Replace(s, "{ ... }", "{...}", -1)
+}
+
+// resolveAll computes the references of the given fields, which are
+// defined in the struct at the given path.
+func (p *Package) resolveAll(fields []*Field, scope string) {
+	for _, f := range fields {
+		if !f.Function {
+			f.Refs = p.refs(f.Type, scope)
+		}
+		p.resolveAll(f.Fields, f.Path)
+	}
+}
+
+// refs finds the references to definitions and imported packages in the
+// expression src, which is evaluated within the struct at path scope.
+func (p *Package) refs(src, scope string) (refs []Ref) {
+	expr, err := parser.ParseExpr("type", src)
+	if err != nil {
+		return nil
+	}
+	add := func(n ast.Node, pkg, path string) {
+		start := n.Pos().Offset()
+		refs = append(refs, Ref{
+			Offset:  start,
+			Len:     n.End().Offset() - start,
+			Package: pkg,
+			Path:    path,
+		})
+	}
+	ast.Walk(expr, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			root, sels := selectors(x)
+			if root == nil {
+				return true
+			}
+			if pkg, ok := p.imports[root.Name]; ok && len(sels) > 0 {
+				add(x, pkg, strings.Join(sels, "."))
+				return false
+			}
+			if path := p.lookupDef(root.Name, scope); path != "" {
+				// Link to the longest prefix that is a definition.
+				for _, s := range sels {
+					if !p.defs[path+"."+s] {
+						break
+					}
+					path += "." + s
+				}
+				add(x, "", path)
+				return false
+			}
+		case *ast.Ident:
+			if path := p.lookupDef(x.Name, scope); path != "" {
+				add(x, "", path)
+			}
+		}
+		return true
+	}, nil)
+	return refs
+}
+
+// selectors returns the identifier and labels of a chain of selectors.
+func selectors(x *ast.SelectorExpr) (root *ast.Ident, sels []string) {
+	var e ast.Expr = x
+	for {
+		switch y := e.(type) {
+		case *ast.SelectorExpr:
+			name, _, err := ast.LabelName(y.Sel)
+			if err != nil {
+				return nil, nil
+			}
+			sels = append([]string{name}, sels...)
+			e = y.X
+		case *ast.Ident:
+			return y, sels
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// lookupDef returns the path of the definition with the given name visible
+// from the struct at path scope, or "" if there is none.
+func (p *Package) lookupDef(name, scope string) string {
+	if !strings.HasPrefix(name, "#") {
+		return ""
+	}
+	for {
+		if path := join(scope, name); p.defs[path] {
+			return path
+		}
+		if scope == "" {
+			return ""
+		}
+		i := strings.LastIndexByte(scope, '.')
+		if i < 0 {
+			scope = ""
+		} else {
+			scope = scope[:i]
+		}
+	}
+}
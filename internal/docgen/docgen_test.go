@@ -0,0 +1,300 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/parser"
+	_ "cuelang.org/go/pkg"
+)
+
+const schema = `
+// Package schema defines the configuration of a server.
+package schema
+
+import "strings"
+
+// Config is the configuration of a server.
+#Config: {
+	// Name names the server.
+	name: string & strings.MinRunes(1) @go(Name)
+	port?: #Port | *8080
+	tls: {
+		#Version: "1.2" | "1.3"
+		version: #Version
+	}
+	[=~"^x-"]: string
+}
+
+// Port is a TCP port.
+#Port: int & >0 & <65536
+
+server: #Config & {name: "main"}
+`
+
+func extract(t *testing.T) *Package {
+	f, err := parser.ParseFile("schema.cue", schema, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r cue.Runtime
+	inst, err := r.CompileFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Extract("example.com/schema", []*ast.File{f}, inst.Value())
+}
+
+func TestExtract(t *testing.T) {
+	p := extract(t)
+	if p.Name != "schema" {
+		t.Errorf("name: got %q; want schema", p.Name)
+	}
+	if want := "Package schema defines the configuration of a server."; p.Doc != want {
+		t.Errorf("doc: got %q; want %q", p.Doc, want)
+	}
+
+	fields := map[string]*Field{}
+	var add func(a []*Field)
+	add = func(a []*Field) {
+		for _, f := range a {
+			fields[f.Path] = f
+			add(f.Fields)
+		}
+	}
+	add(p.Fields)
+
+	testCases := []struct {
+		path string
+		typ  string
+		def  string
+		doc  string
+		refs []string // package:path
+	}{{
+		path: "#Config",
+		typ:  "struct",
+		doc:  "Config is the configuration of a server.",
+	}, {
+		path: "#Config.name",
+		typ:  "string & strings.MinRunes(1)",
+		doc:  "Name names the server.",
+		refs: []string{"strings:MinRunes"},
+	}, {
+		path: "#Config.port",
+		typ:  "#Port | *8080",
+		def:  "8080",
+		refs: []string{":#Port"},
+	}, {
+		path: "#Config.tls.version",
+		typ:  "#Version",
+		refs: []string{":#Config.tls.#Version"},
+	}, {
+		path: `#Config.[=~"^x-"]`,
+		typ:  "string",
+	}, {
+		path: "#Port",
+		typ:  "int & >0 & <65536",
+		doc:  "Port is a TCP port.",
+	}, {
+		path: "server",
+		typ:  "#Config & {...}",
+		refs: []string{":#Config"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			f := fields[tc.path]
+			if f == nil {
+				t.Fatalf("field not found")
+			}
+			if f.Type != tc.typ {
+				t.Errorf("type: got %q; want %q", f.Type, tc.typ)
+			}
+			if f.Default != tc.def {
+				t.Errorf("default: got %q; want %q", f.Default, tc.def)
+			}
+			if f.Doc != tc.doc {
+				t.Errorf("doc: got %q; want %q", f.Doc, tc.doc)
+			}
+			var refs []string
+			for _, r := range f.Refs {
+				refs = append(refs, r.Package+":"+r.Path)
+			}
+			if strings.Join(refs, " ") != strings.Join(tc.refs, " ") {
+				t.Errorf("refs: got %v; want %v", refs, tc.refs)
+			}
+		})
+	}
+
+	if got := fields["#Config.name"].Attrs; len(got) != 1 || got[0] != "@go(Name)" {
+		t.Errorf("attrs: got %v; want [@go(Name)]", got)
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	if _, err := Builtin("nonexisting"); err == nil {
+		t.Errorf("expected error for non-existing package")
+	}
+
+	p, err := Builtin("strings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Builtin || p.Name != "strings" {
+		t.Errorf("got builtin %v, name %q", p.Builtin, p.Name)
+	}
+	for _, f := range p.Fields {
+		if f.Name == "HasPrefix" {
+			if want := "func(string, string) bool"; f.Type != want || !f.Function {
+				t.Errorf("got %q; want function %q", f.Type, want)
+			}
+			return
+		}
+	}
+	t.Errorf("HasPrefix not found")
+}
+
+func TestWriteDocument(t *testing.T) {
+	p := extract(t)
+
+	testCases := []struct {
+		format Format
+		want   []string
+	}{{
+		format: Markdown,
+		want: []string{
+			"# Package schema\n",
+			"`import \"example.com/schema\"`",
+			"<a name=\"def-Config\"></a>\n\n## #Config\n",
+			"## #Config.tls.#Version\n",
+			"| <a name=\"def-Config.name\"></a>`name` | `string & strings.MinRunes(1)` |  | " +
+				"Name names the server. Attributes: `@go(Name)`. " +
+				"See [strings.MinRunes](https://pkg.go.dev/cuelang.org/go/pkg/strings#MinRunes). |",
+			"| <a name=\"def-Config.port\"></a>`port?` | `#Port \\| *8080` | `8080` | See [#Port](#def-Port). |",
+			"| <a name=\"def-Config.tls.version\"></a>`tls.version` | `#Version` |  | " +
+				"See [#Version](#def-Config.tls.def-Version). |",
+			"Type: `int & >0 & <65536`",
+			"- [Fields](#_fields)",
+		},
+	}, {
+		format: HTML,
+		want: []string{
+			"<title>Package schema</title>",
+			`<h2 id="def-Config">#Config</h2>`,
+			`<td id="def-Config.port"><code>port?</code></td><td><code><a href="#def-Port">#Port</a> | *8080</code></td>`,
+			`<a href="https://pkg.go.dev/cuelang.org/go/pkg/strings#MinRunes">strings.MinRunes</a>(1)`,
+			"<p>Type: <code>int &amp; &gt;0 &amp; &lt;65536</code></p>",
+		},
+	}}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		s := &Site{Format: tc.format, Packages: []*Package{p}}
+		if err := s.WriteDocument(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("output does not contain %q:\n%s", want, buf.String())
+			}
+		}
+	}
+}
+
+func TestWritePages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := Builtin("strings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Site{Format: Markdown, Packages: []*Package{extract(t), b}}
+	files, err := s.WritePages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	if got, want := strings.Join(names, " "), "index.md example.com_schema.md strings.md"; got != want {
+		t.Fatalf("got files %s; want %s", got, want)
+	}
+
+	index, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [example.com/schema](example.com_schema.md) — Package schema defines the configuration of a server.\n"
+	if !strings.Contains(string(index), want) {
+		t.Errorf("index does not contain %q:\n%s", want, index)
+	}
+
+	page, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "[strings.MinRunes](strings.md#MinRunes)"
+	if !strings.Contains(string(page), want) {
+		t.Errorf("page does not contain %q:\n%s", want, page)
+	}
+}
+
+func TestWritePagesDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := &Package{ImportPath: ":kube", Name: "kube", Dir: "./services/a"}
+	b := &Package{ImportPath: ":kube", Name: "kube", Dir: "./services/b"}
+	s := &Site{Format: Markdown, Packages: []*Package{a, b}}
+	files, err := s.WritePages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	if got, want := strings.Join(names, " "), "index.md services_a.md services_b.md"; got != want {
+		t.Fatalf("got files %s; want %s", got, want)
+	}
+	page, err := ioutil.ReadFile(files[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Directory `./services/b`"; !strings.Contains(string(page), want) {
+		t.Errorf("page does not contain %q:\n%s", want, page)
+	}
+
+	b.Dir = "./services_a"
+	_, err = s.WritePages(dir)
+	want := "page services_a.md of package ./services_a is already used by package ./services/a"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v; want %s", err, want)
+	}
+}
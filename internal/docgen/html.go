@@ -0,0 +1,171 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen
+
+import (
+	"html"
+	"strings"
+)
+
+const style = `body { font-family: sans-serif; max-width: 60em; margin: auto; }
+code { font-family: monospace; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+`
+
+// htmlRenderer renders documentation as static HTML pages. References are
+// linked within the code.
+type htmlRenderer struct {
+	errWriter
+	site *Site
+}
+
+func (r *htmlRenderer) err() error { return r.errWriter.err }
+
+func (r *htmlRenderer) begin(title string) {
+	r.printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	r.printf("<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		esc(title), style)
+}
+
+func (r *htmlRenderer) end() {
+	r.printf("</body>\n</html>\n")
+}
+
+func (r *htmlRenderer) index(pkgs []*Package) {
+	r.printf("<h1>CUE packages</h1>\n<ul>\n")
+	for _, p := range pkgs {
+		r.printf("<li><a href=\"%s\">%s</a>",
+			esc(r.site.packageLink(p)), esc(p.path()))
+		if s := synopsis(p.Doc); s != "" {
+			r.printf(" — %s", esc(s))
+		}
+		r.printf("</li>\n")
+	}
+	r.printf("</ul>\n")
+}
+
+func (r *htmlRenderer) pkg(p *pkgSections) {
+	r.printf("<h1%s>Package %s</h1>\n", idAttr(p.id), esc(p.Name))
+	if p.Dir != "" {
+		r.printf("<p>Directory <code>%s</code></p>\n", esc(p.Dir))
+	} else {
+		r.printf("<p><code>import %s</code></p>\n", esc(`"`+p.ImportPath+`"`))
+	}
+	r.doc(p.Doc)
+	if len(p.sections) == 0 {
+		return
+	}
+	r.printf("<h2>Index</h2>\n<ul>\n")
+	for _, s := range p.sections {
+		r.printf("<li><a href=\"#%s\">%s</a></li>\n", esc(s.id), esc(s.title))
+	}
+	r.printf("</ul>\n")
+	for _, s := range p.sections {
+		r.section(p.Package, s)
+	}
+}
+
+func (r *htmlRenderer) section(p *Package, s *section) {
+	r.printf("<h2%s>%s</h2>\n", idAttr(s.id), esc(s.title))
+	if f := s.field; f != nil {
+		r.doc(f.Doc)
+		if f.Type != "struct" {
+			r.printf("<p>Type: %s</p>\n", r.typ(p, f))
+		}
+		if f.Default != "" {
+			r.printf("<p>Default: <code>%s</code></p>\n", esc(f.Default))
+		}
+		if len(f.Attrs) > 0 {
+			r.printf("<p>Attributes: %s</p>\n", attrs(f.Attrs))
+		}
+	}
+	if len(s.rows) == 0 {
+		return
+	}
+	if s.field == nil && s.title == "Functions" {
+		r.printf("<table>\n<tr><th>Function</th><th>Signature</th></tr>\n")
+		for _, row := range s.rows {
+			r.printf("<tr><td%s><code>%s</code></td><td>%s</td></tr>\n",
+				idAttr(row.id), esc(row.name), r.typ(p, row.field))
+		}
+		r.printf("</table>\n")
+		return
+	}
+	r.printf("<table>\n<tr><th>Field</th><th>Type</th><th>Default</th><th>Description</th></tr>\n")
+	for _, row := range s.rows {
+		f := row.field
+		def := ""
+		if f.Default != "" {
+			def = "<code>" + esc(f.Default) + "</code>"
+		}
+		desc := esc(strings.Join(strings.Fields(f.Doc), " "))
+		if len(f.Attrs) > 0 {
+			if desc != "" {
+				desc += "<br>"
+			}
+			desc += "Attributes: " + attrs(f.Attrs)
+		}
+		r.printf("<tr><td%s><code>%s</code></td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			idAttr(row.id), esc(row.name), r.typ(p, f), def, desc)
+	}
+	r.printf("</table>\n")
+}
+
+// typ returns the type of f as code, with its references linked to their
+// documentation.
+func (r *htmlRenderer) typ(p *Package, f *Field) string {
+	var b strings.Builder
+	b.WriteString("<code>")
+	last := 0
+	for _, ref := range f.Refs {
+		url := r.site.link(p, ref)
+		if url == "" {
+			continue
+		}
+		end := ref.Offset + ref.Len
+		b.WriteString(esc(f.Type[last:ref.Offset]))
+		b.WriteString(`<a href="` + esc(url) + `">`)
+		b.WriteString(esc(f.Type[ref.Offset:end]))
+		b.WriteString("</a>")
+		last = end
+	}
+	b.WriteString(esc(f.Type[last:]))
+	b.WriteString("</code>")
+	return b.String()
+}
+
+func (r *htmlRenderer) doc(doc string) {
+	for _, para := range paragraphs(doc) {
+		r.printf("<p>%s</p>\n", esc(para))
+	}
+}
+
+func idAttr(id string) string {
+	if id == "" {
+		return ""
+	}
+	return ` id="` + esc(id) + `"`
+}
+
+func attrs(a []string) string {
+	var b []string
+	for _, s := range a {
+		b = append(b, "<code>"+esc(s)+"</code>")
+	}
+	return strings.Join(b, " ")
+}
+
+func esc(s string) string { return html.EscapeString(s) }
@@ -0,0 +1,185 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownRenderer renders documentation as GitHub flavored Markdown.
+// References cannot be linked within code spans, so they are listed
+// separately.
+type markdownRenderer struct {
+	errWriter
+	site *Site
+}
+
+func (r *markdownRenderer) err() error { return r.errWriter.err }
+
+func (r *markdownRenderer) begin(title string) {}
+
+func (r *markdownRenderer) end() {}
+
+func (r *markdownRenderer) index(pkgs []*Package) {
+	r.printf("# CUE packages\n\n")
+	for _, p := range pkgs {
+		r.printf("- [%s](%s)", p.path(), r.site.packageLink(p))
+		if s := synopsis(p.Doc); s != "" {
+			r.printf(" — %s", s)
+		}
+		r.printf("\n")
+	}
+}
+
+func (r *markdownRenderer) pkg(p *pkgSections) {
+	r.anchor(p.id)
+	r.printf("# Package %s\n\n", p.Name)
+	if p.Dir != "" {
+		r.printf("Directory `%s`\n\n", p.Dir)
+	} else {
+		r.printf("`import %q`\n\n", p.ImportPath)
+	}
+	for _, para := range paragraphs(p.Doc) {
+		r.printf("%s\n\n", para)
+	}
+	if len(p.sections) == 0 {
+		return
+	}
+	r.printf("## Index\n\n")
+	for _, s := range p.sections {
+		r.printf("- [%s](#%s)\n", s.title, s.id)
+	}
+	r.printf("\n")
+	for _, s := range p.sections {
+		r.section(p.Package, s)
+	}
+}
+
+func (r *markdownRenderer) section(p *Package, s *section) {
+	r.anchor(s.id)
+	r.printf("## %s\n\n", s.title)
+	if f := s.field; f != nil {
+		for _, para := range paragraphs(f.Doc) {
+			r.printf("%s\n\n", para)
+		}
+		var lines []string
+		if f.Type != "struct" {
+			lines = append(lines, "Type: "+code(f.Type))
+		}
+		if f.Default != "" {
+			lines = append(lines, "Default: "+code(f.Default))
+		}
+		if len(f.Attrs) > 0 {
+			lines = append(lines, "Attributes: "+codes(f.Attrs))
+		}
+		if see := r.see(p, f); see != "" {
+			lines = append(lines, "See: "+see)
+		}
+		if len(lines) > 0 {
+			r.printf("%s\n\n", strings.Join(lines, "  \n"))
+		}
+	}
+	if len(s.rows) == 0 {
+		return
+	}
+	if s.field == nil && s.title == "Functions" {
+		r.printf("| Function | Signature |\n|---|---|\n")
+		for _, row := range s.rows {
+			r.printf("| %s%s | %s |\n",
+				r.cellAnchor(row.id), cell(code(row.name)), cell(code(row.field.Type)))
+		}
+		r.printf("\n")
+		return
+	}
+	r.printf("| Field | Type | Default | Description |\n|---|---|---|---|\n")
+	for _, row := range s.rows {
+		f := row.field
+		var desc []string
+		if f.Doc != "" {
+			desc = append(desc, strings.Join(strings.Fields(f.Doc), " "))
+		}
+		if len(f.Attrs) > 0 {
+			desc = append(desc, "Attributes: "+codes(f.Attrs)+".")
+		}
+		if see := r.see(p, f); see != "" {
+			desc = append(desc, "See "+see+".")
+		}
+		def := ""
+		if f.Default != "" {
+			def = code(f.Default)
+		}
+		r.printf("| %s%s | %s | %s | %s |\n",
+			r.cellAnchor(row.id), cell(code(row.name)), cell(code(f.Type)),
+			cell(def), cell(strings.Join(desc, " ")))
+	}
+	r.printf("\n")
+}
+
+// see returns links to the documentation of the references of f.
+func (r *markdownRenderer) see(p *Package, f *Field) string {
+	var links []string
+	for _, ref := range f.Refs {
+		text := f.Type[ref.Offset : ref.Offset+ref.Len]
+		if url := r.site.link(p, ref); url != "" {
+			text = fmt.Sprintf("[%s](%s)", text, url)
+		} else {
+			text = code(text)
+		}
+		links = appendUnique(links, text)
+	}
+	return strings.Join(links, ", ")
+}
+
+func (r *markdownRenderer) anchor(id string) {
+	if id != "" {
+		r.printf("<a name=%q></a>\n\n", id)
+	}
+}
+
+func (r *markdownRenderer) cellAnchor(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("<a name=%q></a>", id)
+}
+
+// code returns s as a code span.
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+func codes(a []string) string {
+	var b []string
+	for _, s := range a {
+		b = append(b, code(s))
+	}
+	return strings.Join(b, " ")
+}
+
+// cell escapes s for use in a table cell.
+func cell(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Format is an output format for documentation.
+type Format int
+
+const (
+	Markdown Format = iota
+	HTML
+)
+
+// Ext returns the file extension for pages in format f.
+func (f Format) Ext() string {
+	if f == HTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+// A Site renders the documentation of a set of packages. References to
+// definitions and fields of packages within the site link to their
+// documentation, as do references to builtin packages not in the site, which
+// link to pkg.go.dev.
+type Site struct {
+	Format   Format
+	Packages []*Package
+
+	pages bool
+}
+
+// WriteDocument writes the documentation of all packages as a single
+// document.
+func (s *Site) WriteDocument(w io.Writer) error {
+	s.pages = false
+	title := "CUE packages"
+	if len(s.Packages) == 1 {
+		title = "Package " + s.Packages[0].Name
+	}
+	r := s.renderer(w)
+	r.begin(title)
+	for _, p := range s.Packages {
+		r.pkg(s.sections(p))
+	}
+	r.end()
+	return r.err()
+}
+
+// WritePages writes the documentation of each package to its own page in
+// dir, along with an index page listing all packages. It returns the names of
+// the files written.
+func (s *Site) WritePages(dir string) (files []string, err error) {
+	s.pages = true
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	write := func(name string, f func(r renderer)) error {
+		var buf bytes.Buffer
+		r := s.renderer(&buf)
+		f(r)
+		if err := r.err(); err != nil {
+			return err
+		}
+		name = filepath.Join(dir, name)
+		files = append(files, name)
+		return ioutil.WriteFile(name, buf.Bytes(), 0666)
+	}
+	index := "index" + s.Format.Ext()
+	used := map[string]string{index: "the index"}
+	for _, p := range s.Packages {
+		page := s.page(p)
+		if other, ok := used[page]; ok {
+			return nil, fmt.Errorf("page %s of package %s is already used by %s",
+				page, p.path(), other)
+		}
+		used[page] = "package " + p.path()
+	}
+
+	err = write(index, func(r renderer) {
+		r.begin("CUE packages")
+		r.index(s.Packages)
+		r.end()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range s.Packages {
+		err := write(s.page(p), func(r renderer) {
+			r.begin("Package " + p.Name)
+			r.pkg(s.sections(p))
+			r.end()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// page returns the file name of the page for p.
+func (s *Site) page(p *Package) string {
+	path := p.path()
+	if p.Dir != "" {
+		path = strings.TrimPrefix(path, "./")
+	}
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(path)
+	return name + s.Format.Ext()
+}
+
+func (s *Site) renderer(w io.Writer) renderer {
+	if s.Format == HTML {
+		return &htmlRenderer{site: s, errWriter: errWriter{w: w}}
+	}
+	return &markdownRenderer{site: s, errWriter: errWriter{w: w}}
+}
+
+// A renderer writes documentation in a specific format. Write errors are
+// reported by err.
+type renderer interface {
+	begin(title string)
+	index(pkgs []*Package)
+	pkg(p *pkgSections)
+	end()
+	err() error
+}
+
+// pkgSections holds the documentation of a package as it is laid out on a
+// page.
+type pkgSections struct {
+	*Package
+	id       string
+	sections []*section
+}
+
+// A section documents a definition, a top-level struct or, if field is nil,
+// a group of fields or functions.
+type section struct {
+	title string
+	id    string
+	field *Field
+	rows  []row
+}
+
+// A row documents a field within a section.
+type row struct {
+	name  string
+	id    string
+	field *Field
+}
+
+func (s *Site) sections(p *Package) *pkgSections {
+	ps := &pkgSections{Package: p}
+	if s.multi() {
+		ps.id = s.anchor(p, "")
+	}
+	var fields, funcs []row
+	for _, f := range p.Fields {
+		switch {
+		case f.Function:
+			funcs = append(funcs, s.row(p, f.Name, f))
+		case f.Definition || len(f.Fields) > 0:
+			ps.addSection(s, f)
+		default:
+			fields = append(fields, s.row(p, f.Name, f))
+		}
+	}
+	if len(fields) > 0 {
+		ps.sections = append(ps.sections, &section{
+			title: "Fields",
+			id:    s.anchor(p, "_fields"),
+			rows:  fields,
+		})
+	}
+	if len(funcs) > 0 {
+		ps.sections = append(ps.sections, &section{
+			title: "Functions",
+			id:    s.anchor(p, "_functions"),
+			rows:  funcs,
+		})
+	}
+	return ps
+}
+
+// addSection adds a section for f and for any definitions nested within f.
+func (ps *pkgSections) addSection(s *Site, f *Field) {
+	sec := &section{title: f.Path, id: s.anchor(ps.Package, f.Path), field: f}
+	ps.sections = append(ps.sections, sec)
+
+	var nested []*Field
+	var add func(prefix string, fields []*Field)
+	add = func(prefix string, fields []*Field) {
+		for _, f := range fields {
+			if f.Definition {
+				nested = append(nested, f)
+				continue
+			}
+			sec.rows = append(sec.rows, s.row(ps.Package, prefix+f.Name, f))
+			add(prefix+strings.TrimSuffix(f.Name, "?")+".", f.Fields)
+		}
+	}
+	add("", f.Fields)
+
+	for _, f := range nested {
+		ps.addSection(s, f)
+	}
+}
+
+func (s *Site) row(p *Package, name string, f *Field) row {
+	r := row{name: name, field: f}
+	if !strings.HasPrefix(f.Name, "[") && f.Name != "..." {
+		r.id = s.anchor(p, f.Path)
+	}
+	return r
+}
+
+func (s *Site) multi() bool {
+	return !s.pages && len(s.Packages) > 1
+}
+
+// anchor returns the identifier of the element documenting the value at the
+// given path in p.
+// Section identifiers that do not correspond to a path start with an
+// underscore, so that they do not clash with the identifiers of fields.
+func (s *Site) anchor(p *Package, path string) string {
+	id := strings.Replace(path, "#", "def-", -1)
+	if s.multi() {
+		if id == "" {
+			return p.path()
+		}
+		id = p.path() + "." + id
+	}
+	return id
+}
+
+// link returns the URL of the documentation for the reference r within p or
+// "" if it is not documented.
+func (s *Site) link(p *Package, r Ref) string {
+	if r.Package == "" {
+		return "#" + s.anchor(p, r.Path)
+	}
+	for _, q := range s.Packages {
+		if q.Dir != "" || q.ImportPath != r.Package {
+			continue
+		}
+		id := s.anchor(q, r.Path)
+		if s.pages && q != p {
+			return s.page(q) + "#" + id
+		}
+		return "#" + id
+	}
+	if IsBuiltin(r.Package) {
+		return fmt.Sprintf("https://pkg.go.dev/cuelang.org/go/pkg/%s#%s",
+			r.Package, r.Path)
+	}
+	return ""
+}
+
+// packageLink returns the URL of the documentation for p.
+func (s *Site) packageLink(p *Package) string {
+	if s.pages {
+		return s.page(p)
+	}
+	return "#" + s.anchor(p, "")
+}
+
+// synopsis returns the first sentence of doc.
+func synopsis(doc string) string {
+	doc = strings.Join(strings.Fields(doc), " ")
+	if i := strings.Index(doc, ". "); i >= 0 {
+		doc = doc[:i+1]
+	}
+	return doc
+}
+
+// paragraphs splits doc into paragraphs, each on a single line.
+func paragraphs(doc string) (a []string) {
+	for _, p := range strings.Split(doc, "\n\n") {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			a = append(a, p)
+		}
+	}
+	return a
+}
+
+// errWriter records the first error writing to w.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
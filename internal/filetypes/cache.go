@@ -0,0 +1,86 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filetypes
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue/build"
+)
+
+// Evaluating the type of a file is expensive, whereas the number of distinct
+// types in a single run is typically small. Successful results are therefore
+// cached, keyed by all inputs other than the file name. The file name only
+// affects the result through its extension.
+var (
+	fileCache sync.Map // fileKey -> *build.File
+	infoCache sync.Map // infoKey -> *FileInfo
+)
+
+type fileKey struct {
+	scope string
+	ext   string
+	mode  Mode
+}
+
+type infoKey struct {
+	encoding       build.Encoding
+	interpretation build.Interpretation
+	form           build.Form
+	tags           string
+	ext            string
+	mode           Mode
+}
+
+// extKey returns the part of a file name that determines its type.
+func extKey(filename string) string {
+	if filename == "-" {
+		return filename
+	}
+	return filepath.Ext(filename)
+}
+
+func tagsKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// copyFile returns a copy of f for the given file name that does not share
+// mutable state with f.
+func copyFile(f *build.File, filename string) *build.File {
+	c := *f
+	c.Filename = filename
+	if f.Tags != nil {
+		c.Tags = make(map[string]string, len(f.Tags))
+		for k, v := range f.Tags {
+			c.Tags[k] = v
+		}
+	}
+	return &c
+}
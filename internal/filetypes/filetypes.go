@@ -96,6 +96,22 @@ func FromFile(b *build.File, mode Mode) (*FileInfo, error) {
 		}, nil
 	}
 
+	key := infoKey{
+		encoding:       b.Encoding,
+		interpretation: b.Interpretation,
+		form:           b.Form,
+		tags:           tagsKey(b.Tags),
+		mode:           mode,
+	}
+	if b.Encoding == "" {
+		key.ext = extKey(b.Filename)
+	}
+	if x, ok := infoCache.Load(key); ok {
+		fi := *x.(*FileInfo)
+		fi.File = copyFile(fi.File, b.Filename)
+		return &fi, nil
+	}
+
 	i := cuegenInstance.Value()
 	i, errs := update(nil, i, i, "modes", mode.String())
 	v := i.LookupDef("FileInfo")
@@ -128,6 +144,11 @@ func FromFile(b *build.File, mode Mode) (*FileInfo, error) {
 	if err := v.Decode(fi); err != nil {
 		return nil, errors.Wrapf(err, token.NoPos, "could not parse arguments")
 	}
+	if errs == nil && fi.File != nil {
+		c := *fi
+		c.File = copyFile(fi.File, "")
+		infoCache.Store(key, &c)
+	}
 	return fi, errs
 }
 
@@ -233,8 +254,17 @@ func ParseFile(s string, mode Mode) (*build.File, error) {
 		return nil, errors.Newf(token.NoPos, "empty file name in %q", s)
 	}
 
+	key := fileKey{scope: scope, ext: extKey(file), mode: mode}
+	if f, ok := fileCache.Load(key); ok {
+		return copyFile(f.(*build.File), file), nil
+	}
+
 	inst, val := parseType(scope, mode)
-	return toFile(inst, val, file)
+	f, err := toFile(inst, val, file)
+	if err == nil {
+		fileCache.Store(key, copyFile(f, ""))
+	}
+	return f, err
 }
 
 func hasEncoding(v cue.Value) (concrete, hasDefault bool) {
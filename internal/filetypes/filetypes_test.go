@@ -381,3 +381,47 @@ func TestParseArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestCachedResults(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		f, err := ParseFile("foo=bar:a.json", Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &build.File{
+			Filename:       "a.json",
+			Encoding:       build.JSON,
+			Interpretation: build.Auto,
+			Tags:           map[string]string{"foo": "bar"},
+		}
+		check(t, want, f, nil)
+		// Modifying a result must not affect later results.
+		f.Tags["foo"] = "baz"
+
+		fi, err := FromFile(&build.File{Filename: "b.yaml"}, Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Filename != "b.yaml" || fi.Encoding != build.YAML {
+			t.Errorf("%d: got %s with encoding %s", i, fi.Filename, fi.Encoding)
+		}
+		fi.Encoding = build.JSON
+	}
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseFile("foo.cue", Input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFromFile(b *testing.B) {
+	f := &build.File{Filename: "foo.cue", Encoding: build.CUE}
+	for i := 0; i < b.N; i++ {
+		if _, err := FromFile(f, Input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
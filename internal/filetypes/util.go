@@ -56,3 +56,48 @@ func IsPackage(s string) bool {
 	// the requirement that packages be dots if it is clear that the package
 	// name will not interfere with command names in all circumstances.
 }
+
+// archiveExts maps the file extensions of supported archive formats to the
+// name of the format.
+var archiveExts = []struct{ ext, format string }{
+	{".zip", "zip"},
+	{".tar", "tar"},
+	{".tar.gz", "tgz"},
+	{".tgz", "tgz"},
+	{".txtar", "txtar"},
+}
+
+// ArchiveFormat reports the format of the archive with the given file name,
+// which is one of "zip", "tar", "tgz" or "txtar", based on its extension.
+// It returns "" if the name does not refer to a supported archive.
+func ArchiveFormat(filename string) string {
+	for _, a := range archiveExts {
+		if strings.HasSuffix(filename, a.ext) && len(filename) > len(a.ext) {
+			return a.format
+		}
+	}
+	return ""
+}
+
+// SplitArchive splits a command-line argument of the form archive[:arg],
+// where archive is the name of an archive file, into the name of the archive
+// and the argument, which is a package pattern or file name relative to the
+// root of the archive. If no argument is given, "." is returned, referring to
+// the package at the root of the archive. The last result reports whether s
+// refers to an archive.
+func SplitArchive(s string) (archive, arg string, ok bool) {
+	if ArchiveFormat(s) != "" {
+		return s, ".", true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != ':' || ArchiveFormat(s[:i]) == "" {
+			continue
+		}
+		arg = s[i+1:]
+		if arg == "" {
+			arg = "."
+		}
+		return s[:i], arg, true
+	}
+	return "", "", false
+}
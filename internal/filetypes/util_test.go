@@ -46,3 +46,34 @@ func TestIsPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitArchive(t *testing.T) {
+	testCases := []struct {
+		in      string
+		archive string
+		arg     string
+		ok      bool
+	}{
+		{"bundle.zip", "bundle.zip", ".", true},
+		{"bundle.zip:", "bundle.zip", ".", true},
+		{"bundle.zip:./...", "bundle.zip", "./...", true},
+		{"dir/bundle.tar.gz:./foo", "dir/bundle.tar.gz", "./foo", true},
+		{"bundle.tgz:data.json", "bundle.tgz", "data.json", true},
+		{"bundle.txtar:./...:pkg", "bundle.txtar", "./...:pkg", true},
+		{"bundle.tar", "bundle.tar", ".", true},
+
+		{".zip", "", "", false},
+		{"foo.cue", "", "", false},
+		{"json:foo.zip.json", "", "", false},
+		{"./...", "", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			archive, arg, ok := SplitArchive(tc.in)
+			if archive != tc.archive || arg != tc.arg || ok != tc.ok {
+				t.Errorf("got %q, %q, %v; want %q, %q, %v",
+					archive, arg, ok, tc.archive, tc.arg, tc.ok)
+			}
+		})
+	}
+}
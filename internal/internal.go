@@ -77,6 +77,10 @@ var GetRuntime func(instance interface{}) interface{}
 // It returns nil if value is not a cue.Value.
 var CoreValue func(value interface{}) (runtime, vertex interface{})
 
+// CoreInstance returns the *runtime.Runtime and the root *adt.Vertex of a
+// *cue.Instance without evaluating it.
+var CoreInstance func(instance interface{}) (runtime, vertex interface{})
+
 // MakeInstance makes a new instance from a value.
 var MakeInstance func(value interface{}) (instance interface{})
 
@@ -141,6 +145,7 @@ func SetPackage(f *ast.File, name string, overwrite bool) {
 		}
 		ident := ast.NewIdent(name)
 		astutil.CopyMeta(ident, p.Name)
+		p.Name = ident
 		return
 	}
 
@@ -415,7 +420,7 @@ func IsEllipsis(x ast.Decl) bool {
 // GenPath reports the directory in which to store generated files.
 func GenPath(root string) string {
 	info, err := os.Stat(filepath.Join(root, "cue.mod"))
-	if os.IsNotExist(err) || !info.IsDir() {
+	if err != nil || !info.IsDir() {
 		// Try legacy pkgDir mode
 		pkgDir := filepath.Join(root, "pkg")
 		if err == nil && !info.IsDir() {
@@ -0,0 +1,348 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lineedit implements a minimal line editor for interactive use.
+//
+// When reading from a terminal, the editor puts the terminal in raw mode
+// while reading a line and supports the usual Emacs-style key bindings,
+// a history and completion. Otherwise lines are read as is.
+//
+// The editor assumes that every character occupies a single column and that
+// lines fit the width of the terminal.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupt is returned by ReadLine if the user pressed Ctrl-C.
+var ErrInterrupt = errors.New("interrupt")
+
+// An Editor reads lines of input.
+type Editor struct {
+	// Complete, if non-nil, is called for the tab key with the text before
+	// the cursor. It returns the candidates for replacing line[start:].
+	Complete func(line string) (start int, candidates []string)
+
+	r *bufio.Reader
+	w io.Writer
+
+	fd  int
+	raw bool // whether r is a terminal
+
+	history []string
+}
+
+// New returns an Editor reading from r and echoing to w. Line editing is
+// only enabled if r is a terminal.
+func New(r io.Reader, w io.Writer) *Editor {
+	e := &Editor{r: bufio.NewReader(r), w: w}
+	if f, ok := r.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e.fd = int(f.Fd())
+		e.raw = true
+	}
+	return e
+}
+
+// IsTerminal reports whether the editor reads from a terminal.
+func (e *Editor) IsTerminal() bool {
+	return e.raw
+}
+
+// AddHistory adds a line to the history.
+func (e *Editor) AddHistory(line string) {
+	if line == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// ReadLine reads a line of input, without the line terminator. The prompt is
+// only shown when reading from a terminal. ReadLine returns io.EOF at the end
+// of the input and ErrInterrupt if the user pressed Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.raw {
+		return e.readPlain()
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		fmt.Fprint(e.w, prompt)
+		return e.readPlain()
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+func (e *Editor) readPlain() (string, error) {
+	s, err := e.r.ReadString('\n')
+	if err == io.EOF && s != "" {
+		err = nil
+	}
+	return strings.TrimRight(s, "\r\n"), err
+}
+
+// A line is the state of a line being edited.
+type line struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+// Control characters.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+func (e *Editor) edit(prompt string) (string, error) {
+	l := &line{e: e, prompt: prompt}
+	hist := len(e.history)
+	var draft []rune // the line being edited while browsing the history
+
+	setHistory := func(i int) {
+		if i < 0 || i > len(e.history) || i == hist {
+			return
+		}
+		if hist == len(e.history) {
+			draft = l.buf
+		}
+		hist = i
+		if i == len(e.history) {
+			l.buf = draft
+		} else {
+			l.buf = []rune(e.history[i])
+		}
+		l.pos = len(l.buf)
+	}
+
+	l.refresh()
+	for {
+		r, _, err := e.r.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(l.buf) > 0 {
+				err = nil
+			}
+			fmt.Fprint(e.w, "\r\n")
+			return string(l.buf), err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.w, "\r\n")
+			return string(l.buf), nil
+		case ctrlC:
+			fmt.Fprint(e.w, "^C\r\n")
+			return "", ErrInterrupt
+		case ctrlD:
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.w, "\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case ctrlA:
+			l.pos = 0
+		case ctrlE:
+			l.pos = len(l.buf)
+		case ctrlB:
+			l.move(-1)
+		case ctrlF:
+			l.move(1)
+		case ctrlH, backspace:
+			l.delete(l.pos-1, l.pos)
+		case ctrlK:
+			l.delete(l.pos, len(l.buf))
+		case ctrlU:
+			l.delete(0, l.pos)
+		case ctrlW:
+			l.delete(l.wordStart(), l.pos)
+		case ctrlL:
+			fmt.Fprint(e.w, "\x1b[H\x1b[2J")
+		case ctrlP:
+			setHistory(hist - 1)
+		case ctrlN:
+			setHistory(hist + 1)
+		case tab:
+			l.complete()
+		case escape:
+			switch l.readEscape() {
+			case "[A", "OA":
+				setHistory(hist - 1)
+			case "[B", "OB":
+				setHistory(hist + 1)
+			case "[C", "OC":
+				l.move(1)
+			case "[D", "OD":
+				l.move(-1)
+			case "[H", "OH", "[1~", "[7~":
+				l.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				l.pos = len(l.buf)
+			case "[3~":
+				l.delete(l.pos, l.pos+1)
+			case "b":
+				l.pos = l.wordStart()
+			case "f":
+				l.pos = l.wordEnd()
+			}
+		default:
+			if r >= ' ' {
+				l.insert([]rune{r})
+			}
+		}
+		l.refresh()
+	}
+}
+
+// readEscape reads the remainder of an escape sequence.
+func (l *line) readEscape() string {
+	r, _, err := l.e.r.ReadRune()
+	if err != nil {
+		return ""
+	}
+	if r != '[' && r != 'O' {
+		return string(r)
+	}
+	seq := []rune{r}
+	for {
+		r, _, err := l.e.r.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			return string(seq)
+		}
+	}
+}
+
+func (l *line) refresh() {
+	fmt.Fprintf(l.e.w, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if n := len(l.buf) - l.pos; n > 0 {
+		fmt.Fprintf(l.e.w, "\x1b[%dD", n)
+	}
+}
+
+func (l *line) move(n int) {
+	l.pos += n
+	if l.pos < 0 {
+		l.pos = 0
+	}
+	if l.pos > len(l.buf) {
+		l.pos = len(l.buf)
+	}
+}
+
+func (l *line) insert(r []rune) {
+	buf := make([]rune, 0, len(l.buf)+len(r))
+	buf = append(buf, l.buf[:l.pos]...)
+	buf = append(buf, r...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(r)
+}
+
+// delete deletes the runes in [start, end), clamped to the line.
+func (l *line) delete(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(l.buf) {
+		end = len(l.buf)
+	}
+	if start >= end {
+		return
+	}
+	buf := make([]rune, 0, len(l.buf)-(end-start))
+	buf = append(buf, l.buf[:start]...)
+	l.buf = append(buf, l.buf[end:]...)
+	if l.pos > end {
+		l.pos -= end - start
+	} else if l.pos > start {
+		l.pos = start
+	}
+}
+
+func (l *line) wordStart() int {
+	i := l.pos
+	for i > 0 && l.buf[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && l.buf[i-1] != ' ' {
+		i--
+	}
+	return i
+}
+
+func (l *line) wordEnd() int {
+	i := l.pos
+	for i < len(l.buf) && l.buf[i] == ' ' {
+		i++
+	}
+	for i < len(l.buf) && l.buf[i] != ' ' {
+		i++
+	}
+	return i
+}
+
+func (l *line) complete() {
+	if l.e.Complete == nil {
+		return
+	}
+	before := string(l.buf[:l.pos])
+	start, candidates := l.e.Complete(before)
+	if start < 0 || start > len(before) || len(candidates) == 0 {
+		fmt.Fprint(l.e.w, "\a")
+		return
+	}
+	word := before[start:]
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	if len(candidates) > 1 && prefix == word {
+		fmt.Fprintf(l.e.w, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return
+	}
+	n := len([]rune(word))
+	l.delete(l.pos-n, l.pos)
+	l.insert([]rune(prefix))
+}
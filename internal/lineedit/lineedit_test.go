@@ -0,0 +1,116 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lineedit
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{in: "abc\r", want: "abc"},
+		{in: "ac\x02b\r", want: "abc"},                 // Ctrl-B
+		{in: "bc\x01a\x05d\r", want: "abcd"},           // Ctrl-A, Ctrl-E
+		{in: "abx\x7fc\r", want: "abc"},                // backspace
+		{in: "abcx\x1b[D\x1b[D\x1b[3~\r", want: "abx"}, // left, delete
+		{in: "ab cd\x17\r", want: "ab "},               // Ctrl-W
+		{in: "ab cd\x1bb\x0b\r", want: "ab "},          // Alt-B, Ctrl-K
+		{in: "ab cd\x1bb\x15\r", want: "cd"},           // Ctrl-U
+		{in: "a€c\x02\x02\x7f\r", want: "€c"},
+		{in: "\x04", err: io.EOF},
+		{in: "abc\x03", err: ErrInterrupt},
+		{in: "abc", want: "abc"},
+	}
+	for _, tc := range testCases {
+		e := newTestEditor(tc.in)
+		got, err := e.edit("> ")
+		if got != tc.want || err != tc.err {
+			t.Errorf("%q: got %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := newTestEditor("\x1b[A\x1b[A\r\x10\x10\x0e\r\x1b[A\x1b[Bnew\r")
+	e.AddHistory("first")
+	e.AddHistory("second")
+	e.AddHistory("second")
+	for _, want := range []string{"first", "second", "new"} {
+		got, err := e.edit("> ")
+		if err != nil || got != want {
+			t.Errorf("got %q, %v; want %q", got, err, want)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	names := []string{"alpha", "alpine", "beta"}
+	complete := func(line string) (int, []string) {
+		start := strings.LastIndexByte(line, ' ') + 1
+		var a []string
+		for _, s := range names {
+			if strings.HasPrefix(s, line[start:]) {
+				a = append(a, s)
+			}
+		}
+		return start, a
+	}
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{in: "x b\t\r", want: "x beta"},
+		{in: "x a\t\r", want: "x alp"},
+		{in: "x a\t\ti\t\r", want: "x alpine"},
+		{in: "x c\t\r", want: "x c"},
+	}
+	for _, tc := range testCases {
+		e := newTestEditor(tc.in)
+		e.Complete = complete
+		got, err := e.edit("> ")
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	e := New(strings.NewReader("a\r\nb\nc"), ioutil.Discard)
+	for _, want := range []string{"a", "b", "c"} {
+		got, err := e.ReadLine("> ")
+		if err != nil || got != want {
+			t.Errorf("got %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("got %v; want EOF", err)
+	}
+}
+
+func newTestEditor(in string) *Editor {
+	return &Editor{
+		r:   bufio.NewReader(strings.NewReader(in)),
+		w:   ioutil.Discard,
+		raw: true,
+	}
+}
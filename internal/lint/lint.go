@@ -0,0 +1,294 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint reports suspicious constructs in CUE packages.
+//
+// Each check is implemented by a Rule, which can be enabled or disabled
+// individually. Findings can be suppressed for a single line with a comment
+// of the form
+//
+//	//cue:lint-ignore rule[,rule...] [reason]
+//
+// at the end of the line or on the line before it. The rule name "all"
+// suppresses all rules.
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// A Rule checks a package for one kind of problem.
+type Rule struct {
+	// Name identifies the rule in configuration, suppression comments and
+	// the reported findings.
+	Name string
+
+	// Doc is a one-line description of the rule.
+	Doc string
+
+	// Default reports whether the rule is enabled by default.
+	Default bool
+
+	check func(p *pass)
+}
+
+// Rules lists all rules, sorted by name.
+var Rules = []*Rule{
+	deprecatedFieldRule,
+	fieldShouldBeDefinitionRule,
+	fixRule,
+	shadowRule,
+	unusedDefinitionRule,
+	unusedImportRule,
+	unusedLetRule,
+}
+
+// Lookup returns the rule with the given name or nil if there is no such
+// rule.
+func Lookup(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Select returns the default rules, with the rules named in enable added and
+// those named in disable removed. The name "all" refers to all rules. Rules
+// that are named explicitly take precedence over "all", so that, for instance,
+// disabling all rules and enabling one selects just that rule.
+func Select(enable, disable []string) ([]*Rule, error) {
+	selected := map[*Rule]bool{}
+	for _, r := range Rules {
+		selected[r] = r.Default
+	}
+	setAll := func(names []string, value bool) {
+		for _, name := range names {
+			if name == "all" {
+				for _, r := range Rules {
+					selected[r] = value
+				}
+			}
+		}
+	}
+	set := func(names []string, value bool) error {
+		for _, name := range names {
+			if name == "all" {
+				continue
+			}
+			r := Lookup(name)
+			if r == nil {
+				return errors.Newf(token.NoPos, "unknown lint rule %q", name)
+			}
+			selected[r] = value
+		}
+		return nil
+	}
+	setAll(disable, false)
+	setAll(enable, true)
+	if err := set(enable, true); err != nil {
+		return nil, err
+	}
+	if err := set(disable, false); err != nil {
+		return nil, err
+	}
+	a := []*Rule{}
+	for _, r := range Rules {
+		if selected[r] {
+			a = append(a, r)
+		}
+	}
+	return a, nil
+}
+
+// A Finding is a problem reported by a rule. It implements errors.Error and
+// reports the name of its rule as its code and "warning" as its severity.
+type Finding struct {
+	// Rule is the name of the rule that reported the finding.
+	Rule string
+
+	errors.Message
+	pos    token.Pos
+	inputs []token.Pos
+	path   []string
+}
+
+func (f *Finding) Position() token.Pos         { return f.pos }
+func (f *Finding) InputPositions() []token.Pos { return f.inputs }
+func (f *Finding) Path() []string              { return f.path }
+
+// Code returns the name of the rule that reported the finding.
+func (f *Finding) Code() string { return f.Rule }
+
+// Severity returns "warning".
+func (f *Finding) Severity() string { return "warning" }
+
+// Package checks the given files of a package, which evaluate to v, with the
+// given rules. If rules is nil, the default rules are used. It returns a list
+// of Findings, or nil if there are none.
+//
+// Rules that require evaluation are skipped if v does not exist, for instance
+// because the package failed to compile. The other rules only inspect files.
+func Package(files []*ast.File, v cue.Value, rules []*Rule) errors.Error {
+	if rules == nil {
+		rules, _ = Select(nil, nil)
+	}
+	p := &pass{
+		files:   files,
+		value:   v,
+		ignored: map[string]map[int][]string{},
+	}
+	p.collect()
+	for _, r := range rules {
+		p.rule = r
+		r.check(p)
+	}
+	sort.SliceStable(p.findings, func(i, j int) bool {
+		a, b := p.findings[i].pos, p.findings[j].pos
+		if a.Filename() != b.Filename() {
+			return a.Filename() < b.Filename()
+		}
+		return a.Offset() < b.Offset()
+	})
+	var errs errors.Error
+	for _, f := range p.findings {
+		errs = errors.Append(errs, f)
+	}
+	return errs
+}
+
+// A pass holds the state for checking a single package.
+type pass struct {
+	files []*ast.File
+	value cue.Value
+	rule  *Rule
+
+	// uses holds the names of all identifiers and selectors that are not
+	// declarations.
+	uses map[string]bool
+
+	// resolved holds the nodes referenced by resolved identifiers.
+	resolved map[ast.Node]bool
+
+	// ignored maps filenames and line numbers to the rules suppressed for
+	// that line.
+	ignored map[string]map[int][]string
+
+	findings []*Finding
+}
+
+const ignoreDirective = "//cue:lint-ignore"
+
+// collect gathers the information shared by the rules.
+func (p *pass) collect() {
+	p.uses = map[string]bool{}
+	p.resolved = map[ast.Node]bool{}
+	for _, f := range p.files {
+		decls := map[*ast.Ident]bool{}
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Field:
+				label := x.Label
+				if a, ok := label.(*ast.Alias); ok {
+					decls[a.Ident] = true
+					label, _ = a.Expr.(ast.Label)
+				}
+				if id, ok := label.(*ast.Ident); ok {
+					decls[id] = true
+				}
+			case *ast.Alias:
+				decls[x.Ident] = true
+			case *ast.LetClause:
+				decls[x.Ident] = true
+			case *ast.ImportSpec:
+				if x.Name != nil {
+					decls[x.Name] = true
+				}
+			case *ast.ForClause:
+				if x.Key != nil {
+					decls[x.Key] = true
+				}
+				decls[x.Value] = true
+			case *ast.Package:
+				decls[x.Name] = true
+			case *ast.Ident:
+				if !decls[x] {
+					p.uses[x.Name] = true
+				}
+				if x.Node != nil {
+					p.resolved[x.Node] = true
+				}
+			case *ast.CommentGroup:
+				p.collectIgnored(x)
+			}
+			return true
+		}, nil)
+	}
+}
+
+// collectIgnored records the suppression comments in g. A comment at the end
+// of a line applies to that line; other comments apply to the next line.
+func (p *pass) collectIgnored(g *ast.CommentGroup) {
+	for _, c := range g.List {
+		text := strings.TrimPrefix(c.Text, "//")
+		text = "//" + strings.TrimSpace(text)
+		if !strings.HasPrefix(text, ignoreDirective+" ") {
+			continue
+		}
+		fields := strings.Fields(text[len(ignoreDirective):])
+		rules := strings.Split(fields[0], ",")
+		pos := c.Pos().Position()
+		lines := p.ignored[pos.Filename]
+		if lines == nil {
+			lines = map[int][]string{}
+			p.ignored[pos.Filename] = lines
+		}
+		line := pos.Line
+		if !g.Line {
+			line++
+		}
+		lines[line] = append(lines[line], rules...)
+	}
+}
+
+func (p *pass) isIgnored(pos token.Pos) bool {
+	position := pos.Position()
+	for _, r := range p.ignored[position.Filename][position.Line] {
+		if r == "all" || r == p.rule.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// report adds a finding for the current rule at pos, unless it is suppressed.
+func (p *pass) report(pos token.Pos, path []string, inputs []token.Pos, format string, args ...interface{}) {
+	if p.isIgnored(pos) {
+		return
+	}
+	p.findings = append(p.findings, &Finding{
+		Rule:    p.rule.Name,
+		Message: errors.NewMessage(format, args),
+		pos:     pos,
+		inputs:  inputs,
+		path:    path,
+	})
+}
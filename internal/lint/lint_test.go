@@ -0,0 +1,241 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	_ "cuelang.org/go/pkg"
+)
+
+func lint(t *testing.T, src string, rules ...string) string {
+	f, err := parser.ParseFile("test.cue", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r cue.Runtime
+	// Lint packages that fail to compile, such as those with unused imports,
+	// without evaluation.
+	var v cue.Value
+	if inst, err := r.CompileFile(f); err == nil {
+		v = inst.Value()
+	}
+	var selected []*Rule
+	for _, name := range rules {
+		selected = append(selected, Lookup(name))
+	}
+	var a []string
+	for _, e := range errors.Errors(Package([]*ast.File{f}, v, selected)) {
+		pos := e.Position().Position()
+		msg := e.Error()
+		if path := e.Path(); path != nil {
+			msg = strings.Join(path, ".") + ": " + msg
+		}
+		a = append(a, fmt.Sprintf("%d:%d %s: %s",
+			pos.Line, pos.Column, e.(*Finding).Rule, msg))
+	}
+	return strings.Join(a, "\n")
+}
+
+func TestRules(t *testing.T) {
+	testCases := []struct {
+		rule string
+		src  string
+		want string
+	}{{
+		rule: "unused-definition",
+		src: `
+#Used: int
+#Unused: int
+_#Hidden: int
+a: #Used
+b: {
+	#Nested: int
+	c: b.#Nested
+	#NestedUnused: int
+}
+`,
+		want: `3:1 unused-definition: definition #Unused is not used
+4:1 unused-definition: definition _#Hidden is not used
+9:2 unused-definition: definition #NestedUnused is not used`,
+	}, {
+		rule: "unused-definition",
+		src: `
+package p
+
+#Exported: int
+_#Hidden: int
+_h: #NotExported: int
+`,
+		want: `5:1 unused-definition: definition _#Hidden is not used
+6:5 unused-definition: definition #NotExported is not used`,
+	}, {
+		rule: "unused-let",
+		src: `
+let used = 1
+let unused = 2
+a: used
+b: [ for x in [1] let y = x {x} ]
+`,
+		want: `3:5 unused-let: let unused is not used
+5:23 unused-let: let y is not used`,
+	}, {
+		rule: "unused-import",
+		src: `
+import (
+	"strings"
+	"list"
+	m "math"
+)
+
+a: strings.ToUpper("a")
+`,
+		want: `4:2 unused-import: import "list" is not used
+5:2 unused-import: import "math" is not used`,
+	}, {
+		rule: "shadow",
+		src: `
+import "strings"
+
+let x = 1
+a: {
+	let x = 2
+	b: x
+	strings: "s"
+	a: 3
+}
+c: [ for a in [1] {a} ]
+d: [X=string]: {
+	let X = 1
+	e: X
+}
+`,
+		want: `6:6 shadow: let x shadows let
+8:2 shadow: field strings shadows import
+11:10 shadow: comprehension variable a shadows field
+13:6 shadow: let X shadows alias`,
+	}, {
+		rule: "field-should-be-definition",
+		src: `
+Schema: {name: string}
+config: {name: string}
+a: Schema & {name: "a"}
+b: config.name
+data: {name: "x"}
+c: data
+`,
+		want: `2:1 field-should-be-definition: Schema: field is used as a schema; consider making it a definition`,
+	}, {
+		rule: "deprecated-field",
+		src: `
+#Config: {
+	name?: string
+	old?: string @deprecated("use name")
+
+	// Deprecated: use name.
+	older?: string
+}
+a: #Config & {
+	name: "a"
+	old: "b"
+	older: "c"
+}
+`,
+		want: `11:2 deprecated-field: a.old: field is deprecated: use name
+12:2 deprecated-field: a.older: field is deprecated: use name.`,
+	}, {
+		rule: "fix",
+		src: `
+a: 5 div 2
+X = 3
+b: X
+c: {
+	d: 1
+	e: 7 mod 3
+}
+`,
+		want: `2:1 fix: non-canonical syntax; run 'cue fix'
+3:1 fix: non-canonical syntax; run 'cue fix'
+7:2 fix: non-canonical syntax; run 'cue fix'`,
+	}, {
+		rule: "unused-let",
+		src: `
+//cue:lint-ignore unused-let kept for reference
+let a = 1
+let b = 2 // cue:lint-ignore all
+let c = 3 //cue:lint-ignore shadow
+let d = 4
+`,
+		want: `5:5 unused-let: let c is not used
+6:5 unused-let: let d is not used`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			got := lint(t, tc.src, tc.rule)
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	names := func(rules []*Rule) string {
+		var a []string
+		for _, r := range rules {
+			a = append(a, r.Name)
+		}
+		return strings.Join(a, ",")
+	}
+	testCases := []struct {
+		enable, disable []string
+		want            string
+	}{{
+		want: "deprecated-field,fix,shadow,unused-definition,unused-import,unused-let",
+	}, {
+		enable:  []string{"field-should-be-definition"},
+		disable: []string{"fix", "shadow"},
+		want:    "deprecated-field,field-should-be-definition,unused-definition,unused-import,unused-let",
+	}, {
+		enable:  []string{"shadow"},
+		disable: []string{"all"},
+		want:    "shadow",
+	}, {
+		disable: []string{"all"},
+		want:    "",
+	}, {
+		enable:  []string{"all"},
+		disable: []string{"fix"},
+		want:    "deprecated-field,field-should-be-definition,shadow,unused-definition,unused-import,unused-let",
+	}}
+	for _, tc := range testCases {
+		rules, err := Select(tc.enable, tc.disable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(rules); got != tc.want {
+			t.Errorf("Select(%v, %v) = %s; want %s", tc.enable, tc.disable, got, tc.want)
+		}
+	}
+	if _, err := Select([]string{"nope"}, nil); err == nil {
+		t.Error("Select: expected error for unknown rule")
+	}
+}
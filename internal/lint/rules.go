@@ -0,0 +1,489 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/eval"
+	"cuelang.org/go/internal/core/runtime"
+	"cuelang.org/go/tools/fix"
+)
+
+var unusedDefinitionRule = &Rule{
+	Name:    "unused-definition",
+	Doc:     "definitions that are not referenced and cannot be referenced by importing packages",
+	Default: true,
+	check:   (*pass).unusedDefinitions,
+}
+
+// unusedDefinitions reports definitions whose name is not referenced
+// anywhere in the package. Definitions that are visible to importing
+// packages, that is, those in a file with a package clause that are not
+// hidden and not within a hidden field, are not reported.
+func (p *pass) unusedDefinitions() {
+	for _, f := range p.files {
+		_, pkg, _ := internal.PackageInfo(f)
+		p.definitions(f.Decls, pkg != "")
+	}
+}
+
+func (p *pass) definitions(decls []ast.Decl, exported bool) {
+	for _, d := range decls {
+		field, ok := d.(*ast.Field)
+		if !ok {
+			if e, ok := d.(*ast.EmbedDecl); ok {
+				for _, s := range structs(e.Expr) {
+					p.definitions(s.Elts, exported)
+				}
+			}
+			continue
+		}
+		name, isIdent, err := ast.LabelName(field.Label)
+		if err != nil || !isIdent {
+			continue
+		}
+		exported := exported && !internal.IsHidden(name)
+		if internal.IsDef(name) && !exported && !p.uses[name] {
+			p.report(field.Label.Pos(), nil, nil,
+				"definition %s is not used", name)
+		}
+		for _, s := range structs(field.Value) {
+			p.definitions(s.Elts, exported)
+		}
+	}
+}
+
+// structs returns the struct literals that are unified to form x.
+func structs(x ast.Expr) []*ast.StructLit {
+	switch x := x.(type) {
+	case *ast.StructLit:
+		return []*ast.StructLit{x}
+	case *ast.ParenExpr:
+		return structs(x.X)
+	case *ast.BinaryExpr:
+		if x.Op == token.AND {
+			return append(structs(x.X), structs(x.Y)...)
+		}
+	}
+	return nil
+}
+
+var unusedLetRule = &Rule{
+	Name:    "unused-let",
+	Doc:     "let clauses that are not referenced",
+	Default: true,
+	check:   (*pass).unusedLets,
+}
+
+func (p *pass) unusedLets() {
+	p.walk(func(n ast.Node) {
+		if x, ok := n.(*ast.LetClause); ok && !p.resolved[x] {
+			p.report(x.Ident.Pos(), nil, nil, "let %s is not used", x.Ident.Name)
+		}
+	})
+}
+
+var unusedImportRule = &Rule{
+	Name:    "unused-import",
+	Doc:     "imports that are not referenced",
+	Default: true,
+	check:   (*pass).unusedImports,
+}
+
+func (p *pass) unusedImports() {
+	for _, f := range p.files {
+		for _, spec := range f.Imports {
+			if !p.resolved[spec] {
+				p.report(spec.Pos(), nil, nil,
+					"import %s is not used", spec.Path.Value)
+			}
+		}
+	}
+}
+
+var shadowRule = &Rule{
+	Name:    "shadow",
+	Doc:     "let clauses, aliases, comprehension variables and imports that shadow other identifiers, or are shadowed by them",
+	Default: true,
+	check:   (*pass).shadows,
+}
+
+// A scope holds the identifiers declared in a file, struct, comprehension or
+// pattern constraint.
+type scope struct {
+	node  ast.Node
+	outer *scope
+	names map[string]*declaration
+}
+
+type declaration struct {
+	kind string
+	pos  token.Pos
+}
+
+// shadows reports identifiers that shadow an identifier with the same name
+// in an enclosing scope. Fields shadowing fields are not reported, as that is
+// how nested structs commonly refer to their own fields.
+func (p *pass) shadows() {
+	for _, f := range p.files {
+		var s *scope
+		push := func(n ast.Node) {
+			s = &scope{node: n, outer: s, names: map[string]*declaration{}}
+		}
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.File:
+				push(x)
+				for _, spec := range x.Imports {
+					p.declare(s, importName(spec), "import", spec.Pos())
+				}
+				p.declareDecls(s, x.Decls)
+
+			case *ast.StructLit:
+				push(x)
+				p.declareDecls(s, x.Elts)
+
+			case *ast.Comprehension:
+				push(x)
+				for _, c := range x.Clauses {
+					switch c := c.(type) {
+					case *ast.ForClause:
+						if c.Key != nil {
+							p.declare(s, c.Key.Name, "comprehension variable", c.Key.Pos())
+						}
+						p.declare(s, c.Value.Name, "comprehension variable", c.Value.Pos())
+					case *ast.LetClause:
+						p.declare(s, c.Ident.Name, "let", c.Ident.Pos())
+					}
+				}
+
+			case *ast.Field:
+				// Aliases in pattern constraints are visible in the value.
+				if l, ok := x.Label.(*ast.ListLit); ok && len(l.Elts) == 1 {
+					if a, ok := l.Elts[0].(*ast.Alias); ok {
+						push(x)
+						p.declare(s, a.Ident.Name, "alias", a.Ident.Pos())
+					}
+				}
+			}
+			return true
+		}, func(n ast.Node) {
+			if s != nil && s.node == n {
+				s = s.outer
+			}
+		})
+	}
+}
+
+func (p *pass) declareDecls(s *scope, decls []ast.Decl) {
+	for _, d := range decls {
+		switch x := d.(type) {
+		case *ast.Field:
+			label := x.Label
+			if a, ok := label.(*ast.Alias); ok {
+				p.declare(s, a.Ident.Name, "alias", a.Ident.Pos())
+				label, _ = a.Expr.(ast.Label)
+			}
+			if id, ok := label.(*ast.Ident); ok {
+				p.declare(s, id.Name, "field", id.Pos())
+			}
+		case *ast.Alias:
+			p.declare(s, x.Ident.Name, "alias", x.Ident.Pos())
+		case *ast.LetClause:
+			p.declare(s, x.Ident.Name, "let", x.Ident.Pos())
+		}
+	}
+}
+
+func (p *pass) declare(s *scope, name, kind string, pos token.Pos) {
+	if name == "_" || s.names[name] != nil {
+		return
+	}
+	d := &declaration{kind: kind, pos: pos}
+	s.names[name] = d
+	for o := s.outer; o != nil; o = o.outer {
+		x := o.names[name]
+		if x == nil {
+			continue
+		}
+		if kind != "field" || x.kind != "field" {
+			p.report(pos, nil, []token.Pos{x.pos},
+				"%s %s shadows %s", kind, name, x.kind)
+		}
+		return
+	}
+}
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	s, _ := strconv.Unquote(spec.Path.Value)
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		return s[i+1:]
+	}
+	return path.Base(s)
+}
+
+var fieldShouldBeDefinitionRule = &Rule{
+	Name:    "field-should-be-definition",
+	Doc:     "regular top-level fields that are not concrete and are unified with other values as a schema",
+	Default: false,
+	check:   (*pass).fieldsShouldBeDefinitions,
+}
+
+// fieldsShouldBeDefinitions reports top-level regular fields that evaluate
+// to a struct or list that is not concrete and that are referenced as a
+// whole as an operand of a unification, an embedding or the value of a
+// field. Such fields are typically meant as schemas and should be
+// definitions, which are closed and are not part of the output.
+//
+// This rule is a heuristic and is therefore not enabled by default.
+func (p *pass) fieldsShouldBeDefinitions() {
+	if !p.value.Exists() {
+		return
+	}
+	schemas := map[string]bool{}
+	addRef := func(x ast.Expr) {
+		if id, ok := x.(*ast.Ident); ok {
+			schemas[id.Name] = true
+		}
+	}
+	p.walk(func(n ast.Node) {
+		switch x := n.(type) {
+		case *ast.BinaryExpr:
+			if x.Op == token.AND {
+				addRef(x.X)
+				addRef(x.Y)
+			}
+		case *ast.EmbedDecl:
+			addRef(x.Expr)
+		case *ast.Field:
+			addRef(x.Value)
+		}
+	})
+	for _, f := range p.files {
+		for _, d := range f.Decls {
+			field, ok := d.(*ast.Field)
+			if !ok || !internal.IsRegularField(field) {
+				continue
+			}
+			name, isIdent, err := ast.LabelName(field.Label)
+			if err != nil || !isIdent || !schemas[name] {
+				continue
+			}
+			v := p.value.LookupPath(cue.MakePath(cue.Str(name)))
+			if k := v.IncompleteKind(); k != cue.StructKind && k != cue.ListKind {
+				continue
+			}
+			if v.Validate(cue.Concrete(true)) == nil {
+				continue
+			}
+			p.report(field.Label.Pos(), []string{name}, nil,
+				"field is used as a schema; consider making it a definition")
+		}
+	}
+}
+
+var deprecatedFieldRule = &Rule{
+	Name:    "deprecated-field",
+	Doc:     "fields that are set although they are marked as deprecated",
+	Default: true,
+	check:   (*pass).deprecatedFields,
+}
+
+// deprecatedFields reports fields that are declared in the package and that
+// unify with a field that is marked as deprecated, either with a
+// @deprecated attribute or a paragraph in its doc comment starting with
+// "Deprecated:".
+func (p *pass) deprecatedFields() {
+	if !p.value.Exists() {
+		return
+	}
+	r, x := internal.CoreValue(p.value)
+	root, ok := x.(*adt.Vertex)
+	if !ok {
+		return
+	}
+	ctx := eval.NewContext(r.(*runtime.Runtime), root)
+	root.Finalize(ctx)
+
+	files := map[string]bool{}
+	for _, f := range p.files {
+		files[f.Filename] = true
+	}
+	reported := map[*ast.Field]bool{}
+
+	var visit func(v *adt.Vertex)
+	visit = func(v *adt.Vertex) {
+		for _, arc := range v.Arcs {
+			var deprecated *ast.Field
+			reason := ""
+			for _, c := range arc.Conjuncts {
+				if f, ok := c.Source().(*ast.Field); ok {
+					if s, ok := deprecation(f); ok {
+						deprecated, reason = f, s
+						break
+					}
+				}
+			}
+			if deprecated != nil {
+				for _, c := range arc.Conjuncts {
+					f, ok := c.Source().(*ast.Field)
+					if !ok || f == deprecated || f.Optional != token.NoPos ||
+						reported[f] || !files[f.Pos().Filename()] {
+						continue
+					}
+					if _, ok := deprecation(f); ok {
+						continue
+					}
+					reported[f] = true
+					var path []string
+					for _, sel := range cue.MakeValue(ctx, arc).Path().Selectors() {
+						path = append(path, sel.String())
+					}
+					inputs := []token.Pos{deprecated.Pos()}
+					if reason != "" {
+						p.report(f.Label.Pos(), path, inputs,
+							"field is deprecated: %s", reason)
+					} else {
+						p.report(f.Label.Pos(), path, inputs, "field is deprecated")
+					}
+				}
+			}
+			visit(arc)
+		}
+	}
+	visit(root)
+}
+
+// deprecation reports whether f is marked as deprecated and the reason
+// given, if any.
+func deprecation(f *ast.Field) (reason string, ok bool) {
+	for _, a := range f.Attrs {
+		key, body := a.Split()
+		if key != "deprecated" {
+			continue
+		}
+		attr := internal.ParseAttrBody(a.Pos(), body)
+		s, _ := attr.String(0)
+		return s, true
+	}
+	for _, c := range ast.Comments(f) {
+		if !c.Doc {
+			continue
+		}
+		for _, para := range strings.Split(c.Text(), "\n\n") {
+			if strings.HasPrefix(para, "Deprecated:") {
+				para = strings.TrimPrefix(para, "Deprecated:")
+				return strings.Join(strings.Fields(para), " "), true
+			}
+		}
+	}
+	return "", false
+}
+
+var fixRule = &Rule{
+	Name:    "fix",
+	Doc:     "non-canonical syntax that is rewritten by cue fix",
+	Default: true,
+	check:   (*pass).fixes,
+}
+
+// fixes reports declarations that would be rewritten by cue fix. It applies
+// fix to a copy of each file and reports the innermost declarations that
+// differ.
+func (p *pass) fixes() {
+	for _, f := range p.files {
+		b, err := format.Node(f)
+		if err != nil {
+			continue
+		}
+		orig, err := parser.ParseFile(f.Filename, b, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		fixed, err := parser.ParseFile(f.Filename, b, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		fixed = fix.File(fixed)
+		p.fixDecls(f.Decls, orig.Decls, fixed.Decls)
+	}
+}
+
+// fixDecls reports the declarations of decls that differ between the
+// corresponding declarations of orig and fixed.
+func (p *pass) fixDecls(decls, orig, fixed []ast.Decl) bool {
+	if len(decls) != len(orig) || len(orig) != len(fixed) {
+		return false
+	}
+	found := false
+	for i, d := range decls {
+		a, errA := format.Node(orig[i])
+		b, errB := format.Node(fixed[i])
+		if errA != nil || errB != nil || string(a) == string(b) {
+			continue
+		}
+		found = true
+		if p.fixFields(d, orig[i], fixed[i]) {
+			continue
+		}
+		p.report(d.Pos(), nil, nil, "non-canonical syntax; run 'cue fix'")
+	}
+	return found
+}
+
+// fixFields reports the differing declarations within the structs of the
+// corresponding fields d, orig and fixed, if any.
+func (p *pass) fixFields(d, orig, fixed ast.Decl) bool {
+	x, ok1 := d.(*ast.Field)
+	y, ok2 := orig.(*ast.Field)
+	z, ok3 := fixed.(*ast.Field)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+	a, b, c := structs(x.Value), structs(y.Value), structs(z.Value)
+	if len(a) != len(b) || len(b) != len(c) {
+		return false
+	}
+	found := false
+	for i := range a {
+		if p.fixDecls(a[i].Elts, b[i].Elts, c[i].Elts) {
+			found = true
+		}
+	}
+	return found
+}
+
+// walk calls fn for each node in the files of the package.
+func (p *pass) walk(fn func(n ast.Node)) {
+	for _, f := range p.files {
+		ast.Walk(f, func(n ast.Node) bool {
+			fn(n)
+			return true
+		}, nil)
+	}
+}
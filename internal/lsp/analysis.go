@@ -0,0 +1,229 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+)
+
+// A pkg is the result of analyzing the package of a document.
+type pkg struct {
+	files []*ast.File
+
+	// value is the evaluated package. It does not exist if the package
+	// could not be loaded or built.
+	value cue.Value
+
+	err errors.Error
+}
+
+func (p *pkg) file(filename string) *ast.File {
+	for _, f := range p.files {
+		if f.Filename == filename {
+			return f
+		}
+	}
+	return nil
+}
+
+// load analyzes the package of document d, using text as its contents. The
+// contents of other open documents take precedence over the files on disk.
+func (s *server) load(d *document, text string) *pkg {
+	p := &pkg{}
+	f, err := parser.ParseFile(d.filename, text, parser.ParseComments)
+	if err != nil {
+		p.files = []*ast.File{f}
+		p.err = errors.Promote(err, "")
+		return p
+	}
+
+	overlay := map[string]load.Source{}
+	for _, o := range s.docs {
+		overlay[o.filename] = load.FromString(o.text)
+	}
+	overlay[d.filename] = load.FromString(text)
+
+	cfg := &load.Config{
+		Dir:     filepath.Dir(d.filename),
+		Overlay: overlay,
+	}
+	args := []string{d.filename}
+	if name := f.PackageName(); name != "" {
+		args = []string{"."}
+		cfg.Package = name
+	}
+	insts := load.Instances(args, cfg)
+
+	p.files = insts[0].Files
+	if p.file(d.filename) == nil {
+		p.files = append(p.files, f)
+	}
+	if err := insts[0].Err; err != nil {
+		p.err = err
+		return p
+	}
+
+	inst := cue.Build(insts)[0]
+	if inst.Err != nil {
+		p.err = inst.Err
+		return p
+	}
+	p.value = inst.Value()
+	if err := p.value.Validate(); err != nil {
+		p.err = errors.Promote(err, "")
+	}
+	return p
+}
+
+// publishDiagnostics analyzes the package of d and publishes its errors for
+// all open documents of the package.
+func (s *server) publishDiagnostics(d *document) {
+	p := s.load(d, d.text)
+
+	diags := map[string][]Diagnostic{}
+	for _, e := range errors.Errors(p.err) {
+		var positions []token.Pos
+		seen := map[token.Pos]bool{}
+		for _, pos := range append([]token.Pos{e.Position()}, e.InputPositions()...) {
+			if pos.IsValid() && !seen[pos] {
+				seen[pos] = true
+				positions = append(positions, pos)
+			}
+		}
+		diag := Diagnostic{
+			Severity: SeverityError,
+			Source:   "cue",
+			Message:  e.Error(),
+		}
+		if len(positions) == 0 {
+			diags[d.filename] = append(diags[d.filename], diag)
+			continue
+		}
+		for _, pos := range positions {
+			diag.Range = s.rangeOf(pos, pos)
+			diags[pos.Filename()] = append(diags[pos.Filename()], diag)
+		}
+	}
+
+	for _, o := range s.sortedDocs() {
+		if o != d && p.file(o.filename) == nil {
+			continue
+		}
+		list := diags[o.filename]
+		if list == nil {
+			list = []Diagnostic{}
+		}
+		s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI:         o.uri,
+			Diagnostics: list,
+		})
+	}
+}
+
+// text returns the contents of the given file.
+func (s *server) text(filename string) string {
+	for _, d := range s.docs {
+		if d.filename == filename {
+			return d.text
+		}
+	}
+	b, _ := ioutil.ReadFile(filename)
+	return string(b)
+}
+
+func (s *server) rangeOf(start, end token.Pos) Range {
+	text := s.text(start.Filename())
+	return Range{
+		Start: positionOf(text, start.Offset()),
+		End:   positionOf(text, end.Offset()),
+	}
+}
+
+func (s *server) location(n ast.Node) Location {
+	return Location{
+		URI:   uriOf(n.Pos().Filename()),
+		Range: s.rangeOf(n.Pos(), n.End()),
+	}
+}
+
+// offsetOf converts an LSP position to a byte offset in text.
+func offsetOf(text string, p Position) int {
+	offset := 0
+	for line := 0; line < p.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	for n := 0; n < p.Character && offset < len(text); {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == '\n' {
+			break
+		}
+		n += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// positionOf converts a byte offset in text to an LSP position.
+func positionOf(text string, offset int) Position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	var p Position
+	for _, r := range text[:offset] {
+		if r == '\n' {
+			p.Line++
+			p.Character = 0
+		} else {
+			p.Character += utf16Len(r)
+		}
+	}
+	return p
+}
+
+// utf16Len reports the number of UTF-16 code units needed to encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func filenameOf(uri string) (string, *rpcError) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", errorf(codeInvalidParams, "unsupported document URI %q", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func uriOf(filename string) string {
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}
+	return u.String()
+}
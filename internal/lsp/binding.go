@@ -0,0 +1,351 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/token"
+)
+
+// A binding identifies the declaration to which a label or reference refers.
+//
+// Fields may be declared multiple times within the same struct and, at the
+// top level, across the files of a package. A field is therefore identified
+// by the struct literal in which it is declared, nil for the top level of a
+// package, and its name. Other declarations, such as let clauses, aliases
+// and imports, are identified by their node.
+type binding struct {
+	scope ast.Node
+	name  string
+
+	decl ast.Node
+}
+
+// bindingAt reports the binding of the label or reference at the end of the
+// given stack of nodes.
+func bindingAt(p *pkg, stack []ast.Node) (binding, bool) {
+	if len(stack) < 2 {
+		return binding{}, false
+	}
+	// Labels of fields.
+	for i := len(stack) - 1; i > 0; i-- {
+		f, ok := stack[i].(*ast.Field)
+		if !ok {
+			continue
+		}
+		if i+1 == len(stack) || stack[i+1] != f.Label {
+			break
+		}
+		name, _, err := ast.LabelName(f.Label)
+		if err != nil {
+			return binding{}, false
+		}
+		return binding{scope: fieldScope(stack[i-1]), name: name}, true
+	}
+
+	id, ok := stack[len(stack)-1].(*ast.Ident)
+	if !ok {
+		return binding{}, false
+	}
+	switch x := stack[len(stack)-2].(type) {
+	case *ast.SelectorExpr:
+		if x.Sel == id {
+			return p.selectorBinding(x)
+		}
+	case *ast.ImportSpec:
+		if x.Name == id {
+			return binding{decl: x}, true
+		}
+	case *ast.LetClause:
+		if x.Ident == id {
+			return binding{decl: x}, true
+		}
+	case *ast.Alias:
+		if x.Ident == id {
+			return binding{decl: x}, true
+		}
+	case *ast.ForClause:
+		if x.Key == id || x.Value == id {
+			return binding{decl: id}, true
+		}
+	}
+	return identBinding(id), true
+}
+
+// identBinding reports the binding of a reference.
+func identBinding(id *ast.Ident) binding {
+	switch x := id.Node.(type) {
+	case nil:
+		// Unresolved references may refer to fields declared at the top
+		// level of other files of the package.
+		return binding{name: id.Name}
+
+	case *ast.ImportSpec, *ast.LetClause, *ast.Alias:
+		return binding{decl: x}
+	}
+	switch x := id.Scope.(type) {
+	case *ast.File, *ast.StructLit:
+		// The reference X in X=x: y resolves to the field, whereas X in
+		// X: y resolves to y.
+		name := id.Name
+		if f, ok := id.Node.(*ast.Field); ok {
+			name, _, _ = ast.LabelName(f.Label)
+		}
+		return binding{scope: fieldScope(x), name: name}
+
+	case *ast.LetClause:
+		// A let clause of a comprehension.
+		return binding{decl: x}
+	}
+	return binding{decl: id.Node}
+}
+
+// selectorBinding reports the binding of the selector of x, if it refers to
+// a field declared in a struct literal.
+func (p *pkg) selectorBinding(x *ast.SelectorExpr) (binding, bool) {
+	var b binding
+	switch y := x.X.(type) {
+	case *ast.Ident:
+		b = identBinding(y)
+	case *ast.SelectorExpr:
+		var ok bool
+		if b, ok = p.selectorBinding(y); !ok {
+			return binding{}, false
+		}
+	default:
+		return binding{}, false
+	}
+	name, _, err := ast.LabelName(x.Sel)
+	if err != nil {
+		return binding{}, false
+	}
+	for _, n := range p.declarations(b) {
+		f, ok := n.(*ast.Field)
+		if !ok {
+			continue
+		}
+		for _, s := range p.structLits(f.Value, 0) {
+			if len(findFields(s.Elts, name)) > 0 {
+				return binding{scope: s, name: name}, true
+			}
+		}
+	}
+	return binding{}, false
+}
+
+// structLits returns the struct literals that are unified to form the value
+// of x, following references to fields.
+func (p *pkg) structLits(x ast.Expr, depth int) (a []*ast.StructLit) {
+	if depth > 10 {
+		return nil // Guard against reference cycles.
+	}
+	switch x := x.(type) {
+	case *ast.StructLit:
+		a = append(a, x)
+	case *ast.ParenExpr:
+		a = p.structLits(x.X, depth)
+	case *ast.BinaryExpr:
+		if x.Op == token.AND {
+			a = append(p.structLits(x.X, depth), p.structLits(x.Y, depth)...)
+		}
+	case *ast.Ident:
+		for _, n := range p.declarations(identBinding(x)) {
+			if f, ok := n.(*ast.Field); ok {
+				a = append(a, p.structLits(f.Value, depth+1)...)
+			}
+		}
+	}
+	return a
+}
+
+func fieldScope(n ast.Node) ast.Node {
+	if _, ok := n.(*ast.File); ok {
+		return nil
+	}
+	return n
+}
+
+// declarations returns the nodes declaring b.
+func (p *pkg) declarations(b binding) []ast.Node {
+	if b.decl != nil {
+		return []ast.Node{b.decl}
+	}
+	var a []ast.Node
+	switch x := b.scope.(type) {
+	case nil:
+		for _, f := range p.files {
+			a = append(a, findFields(f.Decls, b.name)...)
+		}
+	case *ast.StructLit:
+		a = findFields(x.Elts, b.name)
+	}
+	return a
+}
+
+func findFields(decls []ast.Decl, name string) (a []ast.Node) {
+	for _, d := range decls {
+		if f, ok := d.(*ast.Field); ok {
+			if s, _, _ := ast.LabelName(f.Label); s == name {
+				a = append(a, f)
+			}
+		}
+	}
+	return a
+}
+
+// declName returns the node naming the declaration n.
+func declName(n ast.Node) ast.Node {
+	switch x := n.(type) {
+	case *ast.Field:
+		if a, ok := x.Label.(*ast.Alias); ok {
+			return a.Ident
+		}
+		return x.Label
+	case *ast.LetClause:
+		return x.Ident
+	case *ast.Alias:
+		return x.Ident
+	}
+	return n
+}
+
+// lookup returns the evaluated value of the given field declaration.
+func (p *pkg) lookup(n ast.Node) cue.Value {
+	f := p.file(n.Pos().Filename())
+	if f == nil || !p.value.Exists() {
+		return cue.Value{}
+	}
+	path, ok := fieldPath(nodesAt(f, declName(n).Pos().Offset()))
+	if !ok {
+		return cue.Value{}
+	}
+	return p.value.LookupPath(path)
+}
+
+// valueOf returns the evaluated value of a reference.
+func (p *pkg) valueOf(x ast.Expr) cue.Value {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if b := identBinding(x); b.decl == nil {
+			if decls := p.declarations(b); len(decls) > 0 {
+				return p.lookup(decls[0])
+			}
+		}
+
+	case *ast.SelectorExpr:
+		v := p.valueOf(x.X)
+		name, isIdent, err := ast.LabelName(x.Sel)
+		switch {
+		case !v.Exists() || err != nil:
+		case isIdent && strings.HasPrefix(name, "#"):
+			return v.LookupPath(cue.MakePath(cue.Def(name)))
+		case isIdent && strings.HasPrefix(name, "_"):
+		default:
+			return v.LookupPath(cue.MakePath(cue.Str(name)))
+		}
+	}
+	return cue.Value{}
+}
+
+// fieldPath returns the path of the value denoted by the given stack of
+// nodes. It reports false if the value is not addressable by a path, such as
+// for hidden fields and values within lists or comprehensions.
+func fieldPath(stack []ast.Node) (cue.Path, bool) {
+	var sels []cue.Selector
+	for _, n := range stack {
+		switch x := n.(type) {
+		case *ast.Field:
+			name, isIdent, err := ast.LabelName(x.Label)
+			switch {
+			case err != nil:
+				return cue.Path{}, false
+			case isIdent && strings.HasPrefix(name, "#"):
+				sels = append(sels, cue.Def(name))
+			case isIdent && strings.HasPrefix(name, "_"):
+				return cue.Path{}, false
+			default:
+				sels = append(sels, cue.Str(name))
+			}
+
+		case *ast.ListLit, *ast.Comprehension, *ast.ListComprehension,
+			*ast.LetClause:
+			return cue.Path{}, false
+		}
+	}
+	return cue.MakePath(sels...), true
+}
+
+// nodesAt returns the nodes of f enclosing the given offset, from the
+// outermost to the innermost.
+func nodesAt(f *ast.File, offset int) []ast.Node {
+	var stack, best []ast.Node
+	ast.Walk(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.File:
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		default:
+			if !n.Pos().IsValid() || offset < n.Pos().Offset() || offset > n.End().Offset() {
+				return false
+			}
+		}
+		stack = append(stack, n)
+		if len(stack) >= len(best) {
+			best = append(best[:0:0], stack...)
+		}
+		return true
+	}, func(ast.Node) {
+		stack = stack[:len(stack)-1]
+	})
+	return best
+}
+
+// references returns the identifiers and selector expressions of f that
+// may refer to a declaration.
+func references(f *ast.File) []ast.Node {
+	var a []ast.Node
+	skip := map[ast.Node]bool{}
+	ast.Walk(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Field:
+			skip[x.Label] = true
+			if a, ok := x.Label.(*ast.Alias); ok {
+				skip[a.Ident] = true
+				skip[a.Expr] = true
+			}
+		case *ast.Alias:
+			skip[x.Ident] = true
+		case *ast.LetClause:
+			skip[x.Ident] = true
+		case *ast.ForClause:
+			skip[x.Key] = true
+			skip[x.Value] = true
+		case *ast.ImportSpec:
+			return false
+		case *ast.SelectorExpr:
+			skip[x.Sel] = true
+			a = append(a, x)
+		case *ast.Ident:
+			if !skip[x] {
+				a = append(a, x)
+			}
+		}
+		return true
+	}, nil)
+	return a
+}
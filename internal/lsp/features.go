@@ -0,0 +1,281 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/tools/fix"
+)
+
+func (s *server) hover(d *document, pos Position) *Hover {
+	p := s.load(d, d.text)
+	f := p.file(d.filename)
+	stack := nodesAt(f, offsetOf(d.text, pos))
+	b, ok := bindingAt(p, stack)
+	if !ok {
+		return nil
+	}
+
+	var docs []*ast.CommentGroup
+	var src string
+	switch x := b.decl.(type) {
+	case nil:
+		decls := p.declarations(b)
+		if len(decls) == 0 {
+			return nil // A builtin or an undefined identifier.
+		}
+		for _, d := range decls {
+			docs = append(docs, docComments(d)...)
+		}
+		v := p.lookup(decls[0])
+		if sel, ok := stack[len(stack)-2].(*ast.SelectorExpr); ok {
+			// The declaration of the selected field may be part of a
+			// schema. Show the value of the selected field instead.
+			v = p.valueOf(sel)
+		}
+		if v.Exists() {
+			if d := v.Doc(); len(d) > 0 {
+				docs = d
+			}
+			src = fmt.Sprintf("%s: %v", b.name, v)
+		}
+
+	case *ast.ImportSpec:
+		src = "import " + formatNode(x)
+
+	case *ast.LetClause:
+		docs = docComments(x)
+		src = formatNode(x)
+	}
+
+	var buf strings.Builder
+	if src != "" {
+		fmt.Fprintf(&buf, "```cue\n%s\n```\n", src)
+	}
+	for _, cg := range docs {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(cg.Text())
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	n := stack[len(stack)-1]
+	r := s.rangeOf(n.Pos(), n.End())
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: buf.String()},
+		Range:    &r,
+	}
+}
+
+func (s *server) definition(d *document, pos Position) []Location {
+	p := s.load(d, d.text)
+	f := p.file(d.filename)
+	b, ok := bindingAt(p, nodesAt(f, offsetOf(d.text, pos)))
+	if !ok {
+		return nil
+	}
+	locs := []Location{}
+	for _, n := range p.declarations(b) {
+		locs = append(locs, s.location(declName(n)))
+	}
+	return locs
+}
+
+func (s *server) references(d *document, pos Position, includeDecl bool) []Location {
+	p := s.load(d, d.text)
+	f := p.file(d.filename)
+	b, ok := bindingAt(p, nodesAt(f, offsetOf(d.text, pos)))
+	if !ok {
+		return nil
+	}
+	locs := []Location{}
+	if includeDecl {
+		for _, n := range p.declarations(b) {
+			locs = append(locs, s.location(declName(n)))
+		}
+	}
+	for _, f := range p.files {
+		for _, r := range references(f) {
+			var rb binding
+			switch x := r.(type) {
+			case *ast.Ident:
+				rb = identBinding(x)
+			case *ast.SelectorExpr:
+				if rb, ok = p.selectorBinding(x); !ok {
+					continue
+				}
+				r = x.Sel
+			}
+			if rb == b {
+				locs = append(locs, s.location(r))
+			}
+		}
+	}
+	return locs
+}
+
+func (s *server) format(d *document) ([]TextEdit, *rpcError) {
+	f, err := parser.ParseFile(d.filename, d.text, parser.ParseComments)
+	if err != nil {
+		return nil, errorf(codeRequestFailed, "cannot format: %v", err)
+	}
+	b, err := format.Node(fix.File(f))
+	if err != nil {
+		return nil, errorf(codeRequestFailed, "cannot format: %v", err)
+	}
+	edits := []TextEdit{}
+	if text := string(b); text != d.text {
+		edits = append(edits, TextEdit{
+			Range:   Range{End: positionOf(d.text, len(d.text))},
+			NewText: text,
+		})
+	}
+	return edits, nil
+}
+
+// complete proposes the fields that may be declared at the given position.
+// In the position of a field value, it proposes the definitions of the
+// package instead.
+func (s *server) complete(d *document, pos Position) *CompletionList {
+	list := &CompletionList{Items: []CompletionItem{}}
+
+	// Remove the identifier at the cursor so that the document is more
+	// likely to parse.
+	offset := offsetOf(d.text, pos)
+	start, end := offset, offset
+	for start > 0 && isIdentByte(d.text[start-1]) {
+		start--
+	}
+	for end < len(d.text) && isIdentByte(d.text[end]) {
+		end++
+	}
+	line := d.text[strings.LastIndexByte(d.text[:start], '\n')+1 : start]
+	line = line[strings.LastIndexAny(line, "{,")+1:]
+
+	if strings.Contains(line, ":") {
+		p := s.load(d, d.text[:start]+"_"+d.text[end:])
+		if !p.value.Exists() {
+			return list
+		}
+		iter, err := p.value.Fields(cue.Definitions(true))
+		if err != nil {
+			return list
+		}
+		for iter.Next() {
+			if iter.IsDefinition() {
+				list.Items = append(list.Items, completionItem(
+					CompletionClass, iter.Label(), iter.Value()))
+			}
+		}
+		return list
+	}
+
+	p := s.load(d, d.text[:start]+d.text[end:])
+	f := p.file(d.filename)
+	if f == nil || !p.value.Exists() {
+		return list
+	}
+	stack := nodesAt(f, start)
+
+	// Find the innermost struct enclosing the cursor.
+	i := len(stack) - 1
+	for ; i > 0; i-- {
+		if _, ok := stack[i].(*ast.StructLit); ok {
+			break
+		}
+	}
+	path, ok := fieldPath(stack[:i+1])
+	if !ok {
+		return list
+	}
+	declared := map[string]bool{}
+	var decls []ast.Decl
+	switch x := stack[i].(type) {
+	case *ast.File:
+		decls = x.Decls
+	case *ast.StructLit:
+		decls = x.Elts
+	}
+	for _, decl := range decls {
+		if f, ok := decl.(*ast.Field); ok {
+			name, _, _ := ast.LabelName(f.Label)
+			declared[name] = true
+		}
+	}
+
+	iter, err := p.value.LookupPath(path).Fields(
+		cue.Optional(true), cue.Definitions(true))
+	if err != nil {
+		return list
+	}
+	for iter.Next() {
+		if iter.IsHidden() || declared[iter.Label()] {
+			continue
+		}
+		list.Items = append(list.Items, completionItem(
+			CompletionField, iter.Label(), iter.Value()))
+	}
+	return list
+}
+
+func completionItem(kind int, label string, v cue.Value) CompletionItem {
+	item := CompletionItem{
+		Label:  label,
+		Kind:   kind,
+		Detail: v.IncompleteKind().String(),
+	}
+	var docs []string
+	for _, cg := range v.Doc() {
+		docs = append(docs, cg.Text())
+	}
+	if len(docs) > 0 {
+		item.Documentation = &MarkupContent{
+			Kind:  "plaintext",
+			Value: strings.Join(docs, "\n"),
+		}
+	}
+	return item
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '#' || c == '$' || c >= 0x80
+}
+
+func formatNode(n ast.Node) string {
+	b, err := format.Node(n)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// docComments returns the doc comments associated with a node.
+func docComments(n ast.Node) (docs []*ast.CommentGroup) {
+	for _, cg := range ast.Comments(n) {
+		if cg.Doc {
+			docs = append(docs, cg)
+		}
+	}
+	return docs
+}
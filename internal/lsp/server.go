@@ -0,0 +1,303 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lsp implements a Language Server Protocol server for CUE.
+//
+// The server analyzes the package of each open document, taking the
+// unsaved contents of all open documents into account. It publishes parse
+// and evaluation errors as diagnostics and supports hover, go to
+// definition, find references, formatting and completion of field names.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Serve runs a language server that reads requests from r and writes
+// responses and notifications to w. It returns when r is exhausted or when
+// the client sends an exit notification. It reports an error if the client
+// exits without requesting a shutdown first.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		w:    w,
+		docs: map[string]*document{},
+	}
+	br := bufio.NewReader(r)
+	for {
+		data, err := readMessage(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			err := errorf(codeParseError, "invalid message: %v", err)
+			if err := s.reply(nil, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		switch m.Method {
+		case "":
+			continue // A response to a request we never sent.
+		case "exit":
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, rerr := s.handleSafe(&m)
+		if m.isNotification() {
+			continue
+		}
+		if err := s.reply(m.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	w        io.Writer
+	docs     map[string]*document // by URI
+	shutdown bool
+}
+
+// A document is a file opened by the client.
+type document struct {
+	uri      string
+	filename string
+	text     string
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, err *rpcError) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if err != nil {
+		return writeMessage(s.w, &errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+	}
+	return writeMessage(s.w, &response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) notify(method string, params interface{}) {
+	// Errors writing to the client surface when the next response is sent.
+	_ = writeMessage(s.w, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handleSafe calls handle, turning a panic into an internal error, so that a
+// bug in handling one request does not bring down the server.
+func (s *server) handleSafe(m *message) (result interface{}, err *rpcError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = errorf(codeInternalError, "internal error in %s: %v", m.Method, r)
+		}
+	}()
+	return s.handle(m)
+}
+
+func (s *server) handle(m *message) (interface{}, *rpcError) {
+	if s.shutdown {
+		return nil, errorf(codeInvalidRequest, "server is shut down")
+	}
+
+	switch m.Method {
+	case "initialize":
+		return initializeResult, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		filename, err := filenameOf(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		d := &document{
+			uri:      p.TextDocument.URI,
+			filename: filename,
+			text:     p.TextDocument.Text,
+		}
+		s.docs[d.uri] = d
+		s.publishDiagnostics(d)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range p.ContentChanges {
+			if c.Range == nil {
+				d.text = c.Text
+				continue
+			}
+			start := offsetOf(d.text, c.Range.Start)
+			end := offsetOf(d.text, c.Range.End)
+			d.text = d.text[:start] + c.Text + d.text[end:]
+		}
+		s.publishDiagnostics(d)
+		return nil, nil
+
+	case "textDocument/didSave":
+		return nil, nil
+
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.docs, d.uri)
+		s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI:         d.uri,
+			Diagnostics: []Diagnostic{},
+		})
+		// The package of the remaining documents may have changed.
+		for _, o := range s.sortedDocs() {
+			if filepath.Dir(o.filename) == filepath.Dir(d.filename) {
+				s.publishDiagnostics(o)
+				break
+			}
+		}
+		return nil, nil
+
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(d, p.Position), nil
+
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(d, p.Position), nil
+
+	case "textDocument/references":
+		var p referenceParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.references(d, p.Position, p.Context.IncludeDeclaration), nil
+
+	case "textDocument/formatting":
+		var p documentFormattingParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.format(d)
+
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := decode(m, &p); err != nil {
+			return nil, err
+		}
+		d, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.complete(d, p.Position), nil
+	}
+
+	if m.isNotification() {
+		return nil, nil // Notifications such as $/cancelRequest may be ignored.
+	}
+	return nil, errorf(codeMethodNotFound, "method %q not supported", m.Method)
+}
+
+var initializeResult = map[string]interface{}{
+	"capabilities": map[string]interface{}{
+		"textDocumentSync": map[string]interface{}{
+			"openClose": true,
+			"change":    1, // Full document sync.
+			"save":      false,
+		},
+		"hoverProvider":              true,
+		"definitionProvider":         true,
+		"referencesProvider":         true,
+		"documentFormattingProvider": true,
+		"completionProvider":         map[string]interface{}{},
+	},
+	"serverInfo": map[string]interface{}{
+		"name": "cue",
+	},
+}
+
+func decode(m *message, v interface{}) *rpcError {
+	if err := json.Unmarshal(m.Params, v); err != nil {
+		return errorf(codeInvalidParams, "invalid parameters for %s: %v", m.Method, err)
+	}
+	return nil
+}
+
+func (s *server) document(uri string) (*document, *rpcError) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, errorf(codeInvalidParams, "document %s is not open", uri)
+	}
+	return d, nil
+}
+
+// sortedDocs returns the open documents in a deterministic order.
+func (s *server) sortedDocs() []*document {
+	a := make([]*document, 0, len(s.docs))
+	for _, d := range s.docs {
+		a = append(a, d)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].uri < a[j].uri })
+	return a
+}
@@ -0,0 +1,380 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const schemaFile = `package ex
+
+// #Server describes a server.
+#Server: {
+	// host is the host name.
+	host:  string
+	port?: int & >0
+}
+
+server: #Server & {
+	host: "localhost"
+}
+`
+
+const dataFile = `package ex
+
+copy: server.host
+`
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Only the schema file exists on disk.
+	schema := filepath.Join(dir, "schema.cue")
+	if err := ioutil.WriteFile(schema, []byte(schemaFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(dir, "data.cue")
+
+	c := newClient(t)
+	defer c.close()
+
+	var init struct {
+		Capabilities map[string]interface{}
+	}
+	c.call("initialize", map[string]interface{}{}, &init)
+	if init.Capabilities["hoverProvider"] != true {
+		t.Errorf("hover not supported: %v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	open(c, schema, schemaFile)
+	open(c, data, dataFile)
+	if d := c.diagnostics(data); len(d) != 0 {
+		t.Errorf("unexpected diagnostics: %v", d)
+	}
+
+	t.Run("diagnostics", func(t *testing.T) {
+		change(c, data, dataFile+"server: port: \"80\"\n")
+		d := c.diagnostics(data)
+		if len(d) == 0 || !strings.Contains(d[0].Message, "conflicting values") {
+			t.Errorf("got %v; want conflict", d)
+		} else if want := (Position{Line: 3, Character: 14}); d[0].Range.Start != want {
+			t.Errorf("got position %v; want %v", d[0].Range.Start, want)
+		}
+
+		change(c, data, dataFile)
+		if d := c.diagnostics(data); len(d) != 0 {
+			t.Errorf("diagnostics not cleared: %v", d)
+		}
+
+		change(c, data, "package ex\n\na: {\n")
+		if d := c.diagnostics(data); len(d) == 0 {
+			t.Errorf("missing parse error")
+		}
+		change(c, data, dataFile)
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		var h Hover
+		c.call("textDocument/hover", at(schema, schemaFile, "#Server: {"), &h)
+		for _, want := range []string{
+			"#Server describes a server.",
+			"host:  string",
+			"port?: >0 & int",
+		} {
+			if !strings.Contains(h.Contents.Value, want) {
+				t.Errorf("hover %q does not contain %q", h.Contents.Value, want)
+			}
+		}
+
+		c.call("textDocument/hover", at(data, dataFile, "host"), &h)
+		for _, want := range []string{"host is the host name.", `"localhost"`} {
+			if !strings.Contains(h.Contents.Value, want) {
+				t.Errorf("hover %q does not contain %q", h.Contents.Value, want)
+			}
+		}
+	})
+
+	t.Run("definition", func(t *testing.T) {
+		var locs []Location
+		c.call("textDocument/definition", at(data, dataFile, "server.host"), &locs)
+		checkLocations(t, locs, schema, schemaFile, "server: #Server")
+
+		c.call("textDocument/definition", at(data, dataFile, "host"), &locs)
+		checkLocations(t, locs, schema, schemaFile, "host:  string")
+
+		c.call("textDocument/definition", at(schema, schemaFile, "#Server &"), &locs)
+		checkLocations(t, locs, schema, schemaFile, "#Server: {")
+	})
+
+	t.Run("references", func(t *testing.T) {
+		var locs []Location
+		params := at(schema, schemaFile, "server: #Server")
+		params["context"] = map[string]interface{}{"includeDeclaration": true}
+		c.call("textDocument/references", params, &locs)
+		if len(locs) != 2 {
+			t.Fatalf("got %d references; want 2", len(locs))
+		}
+		checkLocations(t, locs[:1], schema, schemaFile, "server: #Server")
+		checkLocations(t, locs[1:], data, dataFile, "server.host")
+	})
+
+	t.Run("formatting", func(t *testing.T) {
+		var edits []TextEdit
+		change(c, data, "package ex\ncopy:    server.host\n")
+		c.call("textDocument/formatting", map[string]interface{}{
+			"textDocument": map[string]string{"uri": uriOf(data)},
+		}, &edits)
+		if len(edits) != 1 || edits[0].NewText != "package ex\n\ncopy: server.host\n" {
+			t.Errorf("got %v", edits)
+		}
+		change(c, data, dataFile)
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		text := dataFile + "server: {\n\thost: \"localhost\"\n\tpo\n}\n"
+		change(c, data, text)
+		var list CompletionList
+		c.call("textDocument/completion", atEnd(data, text, "\tpo"), &list)
+		if got := labels(list); got != "port" {
+			t.Errorf("got fields %q; want port", got)
+		}
+
+		text = dataFile + "other: #S\n"
+		change(c, data, text)
+		c.call("textDocument/completion", atEnd(data, text, "#S"), &list)
+		if got := labels(list); got != "#Server" {
+			t.Errorf("got definitions %q; want #Server", got)
+		}
+		change(c, data, dataFile)
+	})
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	// A server without documents panics when a document is opened.
+	s := &server{w: ioutil.Discard}
+	params, err := json.Marshal(map[string]interface{}{
+		"textDocument": map[string]string{
+			"uri":  uriOf(filepath.Join(os.TempDir(), "x.cue")),
+			"text": "a: 1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rerr := s.handleSafe(&message{
+		ID:     json.RawMessage("1"),
+		Method: "textDocument/didOpen",
+		Params: params,
+	})
+	if rerr == nil || rerr.Code != codeInternalError {
+		t.Fatalf("got error %v; want internal error", rerr)
+	}
+	if !strings.Contains(rerr.Message, "textDocument/didOpen") {
+		t.Errorf("error %q does not mention the method", rerr.Message)
+	}
+}
+
+func open(c *client, filename, text string) {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        uriOf(filename),
+			"languageId": "cue",
+			"version":    1,
+			"text":       text,
+		},
+	})
+}
+
+func change(c *client, filename, text string) {
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":     uriOf(filename),
+			"version": 2,
+		},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+// at returns the parameters for a request at the start of the first
+// occurrence of s in text.
+func at(filename, text, s string) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uriOf(filename)},
+		"position":     positionOf(text, strings.Index(text, s)),
+	}
+}
+
+// atEnd is like at, but for the end of s.
+func atEnd(filename, text, s string) map[string]interface{} {
+	m := at(filename, text, s)
+	m["position"] = positionOf(text, strings.Index(text, s)+len(s))
+	return m
+}
+
+func checkLocations(t *testing.T, locs []Location, filename, text, s string) {
+	t.Helper()
+	want := positionOf(text, strings.Index(text, s))
+	if len(locs) != 1 || locs[0].URI != uriOf(filename) || locs[0].Range.Start != want {
+		t.Errorf("got %v; want %s:%v", locs, uriOf(filename), want)
+	}
+}
+
+func labels(list CompletionList) string {
+	var a []string
+	for _, item := range list.Items {
+		a = append(a, item.Label)
+	}
+	return strings.Join(a, ",")
+}
+
+// A client sends requests to a server running in a separate goroutine.
+type client struct {
+	t        *testing.T
+	w        *io.PipeWriter
+	messages chan json.RawMessage
+	done     chan error
+	id       int
+
+	diags map[string][]Diagnostic
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{
+		t:        t,
+		w:        inW,
+		messages: make(chan json.RawMessage, 100),
+		done:     make(chan error, 1),
+		diags:    map[string][]Diagnostic{},
+	}
+	go func() {
+		c.done <- Serve(inR, outW)
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			data, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- data
+		}
+	}()
+	return c
+}
+
+func (c *client) close() {
+	c.w.Close()
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	err := writeMessage(c.w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// call sends a request and decodes its result into result.
+func (c *client) call(method string, params, result interface{}) {
+	c.t.Helper()
+	data, err := c.roundTrip(method, params)
+	if err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// roundTrip sends a request and waits for its response. It records the
+// diagnostics published while waiting.
+func (c *client) roundTrip(method string, params interface{}) (json.RawMessage, *rpcError) {
+	c.t.Helper()
+	c.id++
+	err := writeMessage(c.w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      c.id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for data := range c.messages {
+		var m struct {
+			ID     int
+			Method string
+			Params json.RawMessage
+			Result json.RawMessage
+			Error  *rpcError
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			c.t.Fatal(err)
+		}
+		if m.Method == "textDocument/publishDiagnostics" {
+			var p publishDiagnosticsParams
+			if err := json.Unmarshal(m.Params, &p); err != nil {
+				c.t.Fatal(err)
+			}
+			c.diags[p.URI] = p.Diagnostics
+			continue
+		}
+		if m.ID != c.id {
+			c.t.Fatalf("got response for request %d; want %d", m.ID, c.id)
+		}
+		return m.Result, m.Error
+	}
+	c.t.Fatalf("%s: server terminated", method)
+	return nil, nil
+}
+
+// diagnostics returns the diagnostics last published for the given file.
+func (c *client) diagnostics(filename string) []Diagnostic {
+	c.t.Helper()
+	// The server handles messages in order: once it has responded to a
+	// request, all earlier notifications have been sent.
+	if _, err := c.roundTrip("$/sync", nil); err == nil || err.Code != codeMethodNotFound {
+		c.t.Fatalf("unexpected response to sync request: %v", err)
+	}
+	return c.diags[uriOf(filename)]
+}
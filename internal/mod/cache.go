@@ -0,0 +1,127 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// CacheEnv is the environment variable that overrides the location of the
+// CUE cache directory.
+const CacheEnv = "CUE_CACHE_DIR"
+
+// CacheDir returns the root of the CUE cache. This is the value of
+// $CUE_CACHE_DIR if set, or the cue subdirectory of the user cache
+// directory otherwise.
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrapf(err, token.NoPos,
+			"cannot determine cache directory; set %s", CacheEnv)
+	}
+	return filepath.Join(dir, "cue"), nil
+}
+
+// Dir returns the directory of the given module version within the module
+// cache of the given cache directory. The directory may not exist.
+func Dir(cacheDir string, m Version) string {
+	return filepath.Join(cacheDir, "mod", filepath.FromSlash(m.Path)+"@"+m.Version)
+}
+
+// Download ensures that the given module version is available in the module
+// cache, fetching it from reg if necessary, and returns its directory.
+func Download(reg Registry, cacheDir string, m Version) (dir string, err error) {
+	dir = Dir(cacheDir, m)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	if reg == nil {
+		return "", errors.Newf(token.NoPos,
+			"module %s not in module cache and no registry configured", m)
+	}
+
+	// Fetch into a temporary directory first so that an interrupted download
+	// never leaves a partial module in the cache.
+	tmp := fmt.Sprintf("%s.tmp-%x", dir, rand.Int())
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := reg.Fetch(m, tmp); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, serr := os.Stat(dir); serr == nil {
+			return dir, nil // downloaded concurrently
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// ReadFile reads the module file of the module rooted at dir. It returns
+// nil if the module has no module file.
+func ReadFile(dir string) (*File, error) {
+	filename := filepath.Join(dir, "cue.mod", "module.cue")
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filename, b)
+}
+
+// BuildList returns the modules needed to build a module with the given
+// requirements. The reqs function reports the requirements of a dependency.
+// If several versions of a module are required, the highest is selected.
+func BuildList(require []Version, reqs func(Version) ([]Version, error)) ([]Version, error) {
+	selected := map[string]string{}
+	seen := map[Version]bool{}
+	work := append([]Version(nil), require...)
+	for len(work) > 0 {
+		m := work[0]
+		work = work[1:]
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		if v, ok := selected[m.Path]; !ok || CompareVersion(m.Version, v) > 0 {
+			selected[m.Path] = m.Version
+		}
+		deps, err := reqs(m)
+		if err != nil {
+			return nil, err
+		}
+		work = append(work, deps...)
+	}
+	list := make([]Version, 0, len(selected))
+	for path, v := range selected {
+		list = append(list, Version{Path: path, Version: v})
+	}
+	SortVersions(list)
+	return list, nil
+}
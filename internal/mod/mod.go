@@ -0,0 +1,342 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mod implements reading and writing of module files and the
+// resolution of module dependencies from registries into the module cache.
+//
+// A module file, cue.mod/module.cue, declares the module path and the
+// modules on which it depends:
+//
+//     module: "example.com/foo"
+//
+//     require: {
+//         "acme.com/schemas": "v1.2.0"
+//     }
+//
+// A module file may also replace the packages with a given import path prefix
+// with those in a local directory, for instance to develop a dependency
+// alongside the module that uses it:
+//
+//     replace: {
+//         "acme.com/schemas": "../schemas"
+//     }
+//
+package mod
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+)
+
+// A Version identifies a specific version of a module.
+type Version struct {
+	Path    string
+	Version string
+}
+
+// String returns the version in the form path@version.
+func (v Version) String() string {
+	if v.Version == "" {
+		return v.Path
+	}
+	return v.Path + "@" + v.Version
+}
+
+// ParseVersion parses a module argument of the form path[@version].
+func ParseVersion(s string) (Version, error) {
+	v := Version{Path: s}
+	if i := strings.LastIndexByte(s, '@'); i >= 0 {
+		v.Path, v.Version = s[:i], s[i+1:]
+		if !IsValidVersion(v.Version) {
+			return v, errors.Newf(token.NoPos, "invalid version %q in %q", v.Version, s)
+		}
+	}
+	if err := CheckPath(v.Path); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// CheckPath reports whether path is a valid module path. The first element
+// of a module path must contain a dot, which distinguishes it from the
+// paths of builtin packages.
+func CheckPath(path string) error {
+	elem := strings.Split(path, "/")
+	if !strings.Contains(elem[0], ".") {
+		return errors.Newf(token.NoPos, "invalid module path %q: first element must contain a dot", path)
+	}
+	for _, e := range elem {
+		if e == "" || e == "." || e == ".." || strings.ContainsAny(e, "@:\\") {
+			return errors.Newf(token.NoPos, "invalid module path %q", path)
+		}
+	}
+	return nil
+}
+
+// A File holds the contents of a module file.
+type File struct {
+	// Module is the path of the module.
+	Module string
+
+	// ModulePos is the position of the module path, if any.
+	ModulePos token.Pos
+
+	// Require lists the required versions of dependency modules, sorted by
+	// module path.
+	Require []Version
+
+	// Replace lists the replacements of import path prefixes with local
+	// directories, sorted by import path.
+	Replace []Replacement
+
+	// Syntax is the parsed module file. It is used to retain other fields
+	// and comments when the file is written back.
+	Syntax *ast.File
+}
+
+// A Replacement replaces the packages with import paths starting with Path
+// by the packages in directory Dir.
+type Replacement struct {
+	Path string
+
+	// Dir is the replacement directory. A relative directory is interpreted
+	// relative to the module root.
+	Dir string
+}
+
+// Parse parses the contents of a module file. The filename is used for
+// position information only.
+func Parse(filename string, data []byte) (*File, error) {
+	syntax, err := parser.ParseFile(filename, data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var r cue.Runtime
+	inst, err := r.CompileFile(syntax)
+	if err != nil {
+		return nil, err
+	}
+	v := inst.Value()
+
+	f := &File{Syntax: syntax}
+
+	if m := v.Lookup("module"); m.Exists() {
+		if f.Module, err = m.String(); err != nil {
+			return nil, err
+		}
+		f.ModulePos = m.Pos()
+	}
+
+	if req := v.Lookup("require"); req.Exists() {
+		iter, err := req.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			version, err := iter.Value().String()
+			if err != nil {
+				return nil, err
+			}
+			m := Version{Path: iter.Label(), Version: version}
+			pos := iter.Value().Pos()
+			if err := CheckPath(m.Path); err != nil {
+				return nil, errors.Wrapf(err, pos, "invalid requirement")
+			}
+			if !IsValidVersion(m.Version) {
+				return nil, errors.Newf(pos,
+					"invalid version %q for module %q", m.Version, m.Path)
+			}
+			f.Require = append(f.Require, m)
+		}
+		SortVersions(f.Require)
+	}
+
+	if repl := v.Lookup("replace"); repl.Exists() {
+		iter, err := repl.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			dir, err := iter.Value().String()
+			if err != nil {
+				return nil, err
+			}
+			r := Replacement{Path: iter.Label(), Dir: dir}
+			pos := iter.Value().Pos()
+			if err := CheckPath(r.Path); err != nil {
+				return nil, errors.Wrapf(err, pos, "invalid replacement")
+			}
+			if r.Dir == "" {
+				return nil, errors.Newf(pos,
+					"empty replacement directory for %q", r.Path)
+			}
+			f.Replace = append(f.Replace, r)
+		}
+		sort.Slice(f.Replace, func(i, j int) bool {
+			return f.Replace[i].Path < f.Replace[j].Path
+		})
+	}
+
+	return f, nil
+}
+
+// Lookup returns the required version of the module with the given path.
+func (f *File) Lookup(path string) (v Version, ok bool) {
+	for _, m := range f.Require {
+		if m.Path == path {
+			return m, true
+		}
+	}
+	return Version{}, false
+}
+
+// Replacement returns the replacement for the package with the given import
+// path. If more than one replacement matches, the one with the longest path
+// is returned.
+func (f *File) Replacement(importPath string) (r Replacement, ok bool) {
+	if i := strings.LastIndexByte(importPath, ':'); i >= 0 {
+		importPath = importPath[:i]
+	}
+	for _, x := range f.Replace {
+		if !HasPathPrefix(importPath, x.Path) {
+			continue
+		}
+		if !ok || len(x.Path) > len(r.Path) {
+			r, ok = x, true
+		}
+	}
+	return r, ok
+}
+
+// Format returns the formatted module file, with the require section
+// updated to reflect f.Require. Other fields and comments of the original
+// file are retained.
+func (f *File) Format() ([]byte, error) {
+	if f.Syntax == nil {
+		f.Syntax = &ast.File{}
+	}
+
+	if lit, ok := lookupField(f.Syntax, "module").(*ast.BasicLit); !ok ||
+		lit.Value != strconv.Quote(f.Module) {
+		setField(f.Syntax, "module", ast.NewString(f.Module))
+	}
+
+	var req ast.Expr
+	if len(f.Require) > 0 {
+		SortVersions(f.Require)
+		var fields []interface{}
+		for _, m := range f.Require {
+			fields = append(fields, ast.NewString(m.Path), ast.NewString(m.Version))
+		}
+		req = ast.NewStruct(fields...)
+	}
+	setField(f.Syntax, "require", req)
+
+	return format.Node(f.Syntax)
+}
+
+// lookupField returns the value of the top-level field with the given name or
+// nil if there is no such field.
+func lookupField(f *ast.File, name string) ast.Expr {
+	for _, d := range f.Decls {
+		if field, ok := d.(*ast.Field); ok && labelName(field.Label) == name {
+			return field.Value
+		}
+	}
+	return nil
+}
+
+// setField replaces the value of the top-level field with the given name,
+// adding the field if it does not exist. The field is removed if x is nil.
+func setField(f *ast.File, name string, x ast.Expr) {
+	for i, d := range f.Decls {
+		field, ok := d.(*ast.Field)
+		if !ok || labelName(field.Label) != name {
+			continue
+		}
+		if x == nil {
+			f.Decls = append(f.Decls[:i], f.Decls[i+1:]...)
+		} else {
+			field.Value = x
+		}
+		return
+	}
+	if x != nil {
+		f.Decls = append(f.Decls, &ast.Field{
+			Label: ast.NewIdent(name),
+			Value: x,
+		})
+	}
+}
+
+func labelName(l ast.Label) string {
+	switch x := l.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.BasicLit:
+		if s, err := strconv.Unquote(x.Value); err == nil {
+			return s
+		}
+	}
+	return ""
+}
+
+// SortVersions sorts a by module path and then by version.
+func SortVersions(a []Version) {
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Path != a[j].Path {
+			return a[i].Path < a[j].Path
+		}
+		return CompareVersion(a[i].Version, a[j].Version) < 0
+	})
+}
+
+// ModuleForImport returns the module in mods that provides the package with
+// the given import path. If more than one module matches, the one with the
+// longest path is returned.
+func ModuleForImport(mods []Version, importPath string) (m Version, ok bool) {
+	if i := strings.LastIndexByte(importPath, ':'); i >= 0 {
+		importPath = importPath[:i]
+	}
+	for _, v := range mods {
+		if !HasPathPrefix(importPath, v.Path) {
+			continue
+		}
+		if !ok || len(v.Path) > len(m.Path) {
+			m, ok = v, true
+		}
+	}
+	return m, ok
+}
+
+// HasPathPrefix reports whether the slash-separated path s begins with the
+// elements in prefix.
+func HasPathPrefix(s, prefix string) bool {
+	switch {
+	case len(s) == len(prefix):
+		return s == prefix
+	case len(s) > len(prefix):
+		return strings.HasPrefix(s, prefix) &&
+			(prefix == "" || s[len(prefix)] == '/')
+	}
+	return false
+}
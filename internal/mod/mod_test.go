@@ -0,0 +1,489 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	testCases := []struct {
+		in      string
+		require []Version
+		out     string
+		err     string
+	}{{
+		in: `module: "example.com/foo"`,
+		out: `module: "example.com/foo"
+`,
+	}, {
+		in: `
+// Comment.
+
+module: "example.com/foo"
+
+require: {
+	"b.com/x": "v0.1.0"
+	"a.com/y": "v1.2.3-beta.1"
+}
+
+other: 1
+`,
+		require: []Version{
+			{"a.com/y", "v1.2.3-beta.1"},
+			{"b.com/x", "v0.1.0"},
+		},
+		out: `// Comment.
+
+module: "example.com/foo"
+
+require: {
+	"a.com/y": "v1.2.3-beta.1"
+	"b.com/x": "v0.1.0"
+}
+
+other: 1
+`,
+	}, {
+		in:  `require: "foo/bar": "v1.0.0"`,
+		err: `invalid requirement: invalid module path "foo/bar": first element must contain a dot`,
+	}, {
+		in:  `require: "foo.com/bar": "1.0.0"`,
+		err: `invalid version "1.0.0" for module "foo.com/bar"`,
+	}}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			f, err := Parse("module.cue", []byte(tc.in))
+			if err != nil || tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("got error %v; want %v", err, tc.err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(f.Require, tc.require) {
+				t.Errorf("got %v; want %v", f.Require, tc.require)
+			}
+			b, err := f.Format()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestModulePos(t *testing.T) {
+	f, err := Parse("module.cue", []byte("// Comment.\nmodule: \"example.com/foo\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.ModulePos.String(), "module.cue:2:1"; got != want {
+		t.Errorf("got position %s; want %s", got, want)
+	}
+}
+
+func TestFormatUpdate(t *testing.T) {
+	f, err := Parse("module.cue", []byte(`
+module: "example.com/foo"
+require: "a.com/x": "v0.1.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Require = nil
+	b, err := f.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "module: \"example.com/foo\"\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	f.Require = []Version{{"b.com/y", "v1.0.0"}, {"a.com/x", "v0.2.0"}}
+	b, err = f.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `module: "example.com/foo"
+require: {
+	"a.com/x": "v0.2.0"
+	"b.com/y": "v1.0.0"
+}
+`
+	if got := string(b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	f, err := Parse("module.cue", []byte(`
+module: "example.com/foo"
+replace: {
+	"acme.com/a/b": "/abs/b"
+	"acme.com/a":   "../a"
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Replacement{
+		{"acme.com/a", "../a"},
+		{"acme.com/a/b", "/abs/b"},
+	}
+	if !reflect.DeepEqual(f.Replace, want) {
+		t.Errorf("got %v; want %v", f.Replace, want)
+	}
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{"acme.com/a", "../a"},
+		{"acme.com/a/c:pkg", "../a"},
+		{"acme.com/a/b/c", "/abs/b"},
+		{"acme.com/ab", ""},
+	}
+	for _, tc := range testCases {
+		r, _ := f.Replacement(tc.path)
+		if r.Dir != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.path, r.Dir, tc.want)
+		}
+	}
+
+	_, err = Parse("module.cue", []byte(`replace: "acme.com/a": ""`))
+	if got, want := fmt.Sprint(err), `empty replacement directory for "acme.com/a"`; got != want {
+		t.Errorf("got error %q; want %q", got, want)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	// Versions in increasing order.
+	versions := []string{
+		"bad",
+		"v0.0.1-alpha",
+		"v0.0.1-alpha.1",
+		"v0.0.1-alpha.beta",
+		"v0.0.1-beta",
+		"v0.0.1-beta.2",
+		"v0.0.1-beta.11",
+		"v0.0.1-rc.1",
+		"v0.0.1",
+		"v0.1.0",
+		"v0.9.0",
+		"v0.10.0",
+		"v1.0.0",
+		"v2.0.0+build",
+	}
+	for i, a := range versions {
+		for j, b := range versions {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := CompareVersion(a, b); got != want {
+				t.Errorf("CompareVersion(%q, %q) = %d; want %d", a, b, got, want)
+			}
+		}
+	}
+
+	if got := LatestVersion([]string{"v1.0.0", "v1.1.0-rc.1", "v0.9.0"}); got != "v1.0.0" {
+		t.Errorf("LatestVersion: got %s; want v1.0.0", got)
+	}
+	if got := LatestVersion([]string{"v1.1.0-rc.1", "v1.1.0-rc.2"}); got != "v1.1.0-rc.2" {
+		t.Errorf("LatestVersion: got %s; want v1.1.0-rc.2", got)
+	}
+}
+
+func TestModuleForImport(t *testing.T) {
+	mods := []Version{
+		{"acme.com/a", "v1.0.0"},
+		{"acme.com/a/b", "v1.0.0"},
+	}
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{"acme.com/a", "acme.com/a"},
+		{"acme.com/a:pkg", "acme.com/a"},
+		{"acme.com/a/c", "acme.com/a"},
+		{"acme.com/a/b/c", "acme.com/a/b"},
+		{"acme.com/ab", ""},
+		{"strings", ""},
+	}
+	for _, tc := range testCases {
+		m, _ := ModuleForImport(mods, tc.path)
+		if m.Path != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.path, m.Path, tc.want)
+		}
+	}
+}
+
+func TestBuildList(t *testing.T) {
+	reqs := map[Version][]Version{
+		{"a.com/a", "v1.0.0"}: {{"c.com/c", "v1.1.0"}},
+		{"b.com/b", "v1.0.0"}: {{"c.com/c", "v1.2.0"}, {"d.com/d", "v0.1.0"}},
+		{"c.com/c", "v1.2.0"}: {{"d.com/d", "v0.2.0"}},
+	}
+	list, err := BuildList(
+		[]Version{{"b.com/b", "v1.0.0"}, {"a.com/a", "v1.0.0"}},
+		func(v Version) ([]Version, error) { return reqs[v], nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Version{
+		{"a.com/a", "v1.0.0"},
+		{"b.com/b", "v1.0.0"},
+		{"c.com/c", "v1.2.0"},
+		{"d.com/d", "v0.2.0"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("got %v; want %v", list, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "cue-mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	regDir := filepath.Join(tmp, "registry")
+	for _, v := range []string{"v0.1.0", "v0.2.0", "junk"} {
+		dir := filepath.Join(regDir, "acme.com", "x@"+v)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := fmt.Sprintf("package x\nv: %q\n", v)
+		if err := ioutil.WriteFile(filepath.Join(dir, "x.cue"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg, err := NewRegistry("file://" + filepath.ToSlash(regDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := reg.Versions("acme.com/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v0.1.0", "v0.2.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("got %v; want %v", versions, want)
+	}
+	if versions, _ := reg.Versions("acme.com/y"); len(versions) != 0 {
+		t.Errorf("got %v; want none", versions)
+	}
+
+	cache := filepath.Join(tmp, "cache")
+	m := Version{"acme.com/x", "v0.2.0"}
+	dir, err := Download(reg, cache, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != Dir(cache, m) {
+		t.Errorf("got dir %s; want %s", dir, Dir(cache, m))
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "x.cue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "v0.2.0") {
+		t.Errorf("unexpected contents %q", b)
+	}
+
+	if _, err := Download(reg, cache, Version{"acme.com/x", "v0.3.0"}); err == nil {
+		t.Error("expected error for missing version")
+	}
+}
+
+func TestSums(t *testing.T) {
+	const data = `acme.com/x v0.1.0 h1:aGFzaA==
+
+cue.mod/pkg/b.com/y h1:ZGly
+acme.com/a v1.0.0 h1:b3RoZXI=
+`
+	sums, err := ParseSums("cue.sum", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Sums{
+		{"acme.com/a", "v1.0.0"}:    "h1:b3RoZXI=",
+		{"acme.com/x", "v0.1.0"}:    "h1:aGFzaA==",
+		{"cue.mod/pkg/b.com/y", ""}: "h1:ZGly",
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("got %v; want %v", sums, want)
+	}
+	const formatted = `acme.com/a v1.0.0 h1:b3RoZXI=
+acme.com/x v0.1.0 h1:aGFzaA==
+cue.mod/pkg/b.com/y h1:ZGly
+`
+	if got := string(sums.Format()); got != formatted {
+		t.Errorf("got:\n%s\nwant:\n%s", got, formatted)
+	}
+
+	_, err = ParseSums("cue.sum", []byte("acme.com/x v0.1.0\n"))
+	if got, want := fmt.Sprint(err), `cue.sum:1: malformed checksum line "acme.com/x v0.1.0"`; got != want {
+		t.Errorf("got error %q; want %q", got, want)
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "cue-mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string]string{
+		"x.cue":     "package x\n",
+		"sub/y.cue": "package y\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h1, err := HashDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := Hash([]string{"x.cue", "sub/y.cue"}, func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(files[name])), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 || !strings.HasPrefix(h1, "h1:") {
+		t.Errorf("HashDir = %s; Hash = %s", h1, h2)
+	}
+
+	path := filepath.Join(tmp, "x.cue")
+	if err := ioutil.WriteFile(path, []byte("package x\nmodified: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if h3, err := HashDir(tmp); err != nil || h3 == h1 {
+		t.Errorf("hash unchanged after modification: %s (%v)", h3, err)
+	}
+}
+
+func TestHashPkgDirs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "cue-mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if sums, err := HashPkgDirs(tmp); err != nil || len(sums) != 0 {
+		t.Errorf("got %v, %v for module without cue.mod/pkg", sums, err)
+	}
+
+	files := map[string]string{
+		"cue.mod/pkg/b.com/x.cue":   "package x\n",
+		"cue.mod/pkg/b.com/y/y.cue": "package y\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sums, err := HashPkgDirs(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for v := range sums {
+		keys = append(keys, v.String())
+	}
+	sort.Strings(keys)
+	if got, want := strings.Join(keys, " "), "cue.mod/pkg/b.com cue.mod/pkg/b.com/y"; got != want {
+		t.Errorf("got entries %s; want %s", got, want)
+	}
+
+	// The hash of a directory does not cover its subdirectories.
+	path := filepath.Join(tmp, "cue.mod", "pkg", "b.com", "y", "y.cue")
+	if err := ioutil.WriteFile(path, []byte("package y\nmodified: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified, err := HashPkgDirs(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := (Version{Path: "cue.mod/pkg/b.com"}); modified[x] != sums[x] {
+		t.Errorf("hash of %s changed", x)
+	}
+	if y := (Version{Path: "cue.mod/pkg/b.com/y"}); modified[y] == sums[y] {
+		t.Errorf("hash of %s unchanged after modification", y)
+	}
+}
+
+func TestVendorManifest(t *testing.T) {
+	const data = `# b.com/x v0.1.0
+b.com/x/y
+b.com/x
+# local.org/util
+local.org/util
+`
+	mods, err := ParseVendorManifest("modules.txt", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VendoredModule{
+		{Version{"b.com/x", "v0.1.0"}, []string{"b.com/x/y", "b.com/x"}},
+		{Version{"local.org/util", ""}, []string{"local.org/util"}},
+	}
+	if !reflect.DeepEqual(mods, want) {
+		t.Errorf("got %v; want %v", mods, want)
+	}
+
+	const formatted = `# b.com/x v0.1.0
+b.com/x
+b.com/x/y
+# local.org/util
+local.org/util
+`
+	if got := string(FormatVendorManifest(mods)); got != formatted {
+		t.Errorf("got:\n%s\nwant:\n%s", got, formatted)
+	}
+
+	for _, bad := range []string{"b.com/x\n", "# b.com/x 1.0\n", "# b.com/x\nb.com/x y\n"} {
+		if _, err := ParseVendorManifest("modules.txt", []byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/copy"
+)
+
+// RegistryEnv is the environment variable that holds the location of the
+// default module registry.
+const RegistryEnv = "CUE_REGISTRY"
+
+// A Registry provides access to the published versions of modules.
+type Registry interface {
+	// Versions returns the available versions of the module with the given
+	// path, or an empty list if the registry does not hold the module.
+	Versions(path string) ([]string, error)
+
+	// Fetch copies the contents of the given module version to dir, which
+	// must not exist.
+	Fetch(m Version, dir string) error
+}
+
+// NewRegistry returns the registry at the given location. Currently only
+// local registries are supported, specified either as a directory name or a
+// file:// URL. A local registry holds each module version in a directory
+// named <module path>@<version>:
+//
+//     acme.com/schemas@v1.0.0/
+//     acme.com/schemas@v1.2.0/
+//
+func NewRegistry(location string) (Registry, error) {
+	if location == "" {
+		return nil, errors.Newf(token.NoPos,
+			"no module registry configured; set %s", RegistryEnv)
+	}
+	dir := location
+	if strings.Contains(location, "://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, token.NoPos, "invalid registry %q", location)
+		}
+		if u.Scheme != "file" {
+			return nil, errors.Newf(token.NoPos,
+				"unsupported registry scheme %q in %q", u.Scheme, location)
+		}
+		dir = filepath.FromSlash(u.Path)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, token.NoPos, "invalid registry %q", location)
+	}
+	if !info.IsDir() {
+		return nil, errors.Newf(token.NoPos, "registry %q is not a directory", location)
+	}
+	return dirRegistry(dir), nil
+}
+
+// dirRegistry is a registry stored in a local directory.
+type dirRegistry string
+
+func (r dirRegistry) Versions(path string) ([]string, error) {
+	if err := CheckPath(path); err != nil {
+		return nil, err
+	}
+	dir, base := filepath.Split(filepath.Join(string(r), filepath.FromSlash(path)))
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, base+"@") {
+			continue
+		}
+		if v := name[len(base)+1:]; IsValidVersion(v) {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func (r dirRegistry) Fetch(m Version, dir string) error {
+	src := filepath.Join(string(r), filepath.FromSlash(m.Path)+"@"+m.Version)
+	if _, err := os.Stat(src); err != nil {
+		return errors.Newf(token.NoPos, "module %s not found in registry", m)
+	}
+	return copy.Dir(src, dir)
+}
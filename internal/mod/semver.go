@@ -0,0 +1,197 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"strings"
+)
+
+// A semver holds the components of a semantic version of the form
+// vMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD].
+type semver struct {
+	major, minor, patch string
+	prerelease          string
+}
+
+func parseSemver(v string) (s semver, ok bool) {
+	if !strings.HasPrefix(v, "v") {
+		return s, false
+	}
+	v = v[1:]
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		if !isIdentList(v[i+1:]) {
+			return s, false
+		}
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		s.prerelease = v[i+1:]
+		if !isIdentList(s.prerelease) {
+			return s, false
+		}
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return s, false
+	}
+	for _, p := range parts {
+		if !isNum(p) {
+			return s, false
+		}
+	}
+	s.major, s.minor, s.patch = parts[0], parts[1], parts[2]
+	return s, true
+}
+
+func isNum(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || '9' < c {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentList(s string) bool {
+	for _, p := range strings.Split(s, ".") {
+		if p == "" {
+			return false
+		}
+		for _, c := range p {
+			switch {
+			case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z',
+				'0' <= c && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsValidVersion reports whether v is a valid semantic version of the form
+// vMAJOR.MINOR.PATCH, optionally followed by a pre-release and build suffix.
+func IsValidVersion(v string) bool {
+	_, ok := parseSemver(v)
+	return ok
+}
+
+// IsPrerelease reports whether v is a valid pre-release version.
+func IsPrerelease(v string) bool {
+	s, ok := parseSemver(v)
+	return ok && s.prerelease != ""
+}
+
+// CompareVersion returns an integer comparing two versions according to
+// semantic version precedence. The result is 0 if a == b, -1 if a < b, and
+// +1 if a > b. An invalid version is considered less than all valid ones.
+func CompareVersion(a, b string) int {
+	x, okx := parseSemver(a)
+	y, oky := parseSemver(b)
+	switch {
+	case !okx && !oky:
+		return 0
+	case !okx:
+		return -1
+	case !oky:
+		return +1
+	}
+	if c := compareNum(x.major, y.major); c != 0 {
+		return c
+	}
+	if c := compareNum(x.minor, y.minor); c != 0 {
+		return c
+	}
+	if c := compareNum(x.patch, y.patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(x.prerelease, y.prerelease)
+}
+
+func compareNum(x, y string) int {
+	switch {
+	case len(x) < len(y):
+		return -1
+	case len(x) > len(y):
+		return +1
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+func comparePrerelease(x, y string) int {
+	switch {
+	case x == y:
+		return 0
+	case x == "":
+		return +1
+	case y == "":
+		return -1
+	}
+	xs := strings.Split(x, ".")
+	ys := strings.Split(y, ".")
+	for i := 0; i < len(xs) && i < len(ys); i++ {
+		a, b := xs[i], ys[i]
+		if a == b {
+			continue
+		}
+		na, nb := isNum(a), isNum(b)
+		switch {
+		case na && nb:
+			return compareNum(a, b)
+		case na:
+			return -1
+		case nb:
+			return +1
+		case a < b:
+			return -1
+		default:
+			return +1
+		}
+	}
+	return compareNum(
+		strings.Repeat("0", len(xs)),
+		strings.Repeat("0", len(ys)))
+}
+
+// LatestVersion returns the highest version in list, preferring release
+// versions over pre-release versions. It returns "" if list contains no
+// valid versions.
+func LatestVersion(list []string) string {
+	latest := ""
+	for _, v := range list {
+		if !IsValidVersion(v) {
+			continue
+		}
+		switch {
+		case latest == "":
+			latest = v
+		case IsPrerelease(latest) != IsPrerelease(v):
+			if !IsPrerelease(v) {
+				latest = v
+			}
+		case CompareVersion(v, latest) > 0:
+			latest = v
+		}
+	}
+	return latest
+}
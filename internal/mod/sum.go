@@ -0,0 +1,237 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// SumFile is the name of the checksum file within the cue.mod directory.
+const SumFile = "cue.sum"
+
+// PkgDir is the directory within the cue.mod directory that holds packages
+// which are placed there by hand rather than fetched as required modules.
+const PkgDir = "pkg"
+
+// Sums maps module versions to the hash of their contents.
+//
+// A checksum file holds one line per module version of the form
+//
+//     <module path> <version> <hash>
+//
+// as well as one line of the form
+//
+//     cue.mod/pkg/<dir> <hash>
+//
+// for each directory within cue.mod/pkg that holds files. The hash of a
+// directory covers only the files directly within it. Such entries have the
+// slash-separated path of the directory relative to the module root as their
+// path and an empty version.
+type Sums map[Version]string
+
+// ParseSums parses the contents of a checksum file. The filename is used for
+// error messages only.
+func ParseSums(filename string, data []byte) (Sums, error) {
+	sums := Sums{}
+	for i, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 0:
+			continue
+		case len(f) == 2 && isPkgDir(f[0]) && strings.HasPrefix(f[1], "h1:"):
+			sums[Version{Path: f[0]}] = f[1]
+		case len(f) != 3 || !IsValidVersion(f[1]) || !strings.HasPrefix(f[2], "h1:"):
+			return nil, errors.Newf(token.NoPos,
+				"%s:%d: malformed checksum line %q", filename, i+1, line)
+		default:
+			sums[Version{Path: f[0], Version: f[1]}] = f[2]
+		}
+	}
+	return sums, nil
+}
+
+// Format returns the contents of a checksum file holding s.
+func (s Sums) Format() []byte {
+	versions := make([]Version, 0, len(s))
+	for v := range s {
+		versions = append(versions, v)
+	}
+	SortVersions(versions)
+	var buf bytes.Buffer
+	for _, v := range versions {
+		if v.Version == "" {
+			fmt.Fprintf(&buf, "%s %s\n", v.Path, s[v])
+			continue
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", v.Path, v.Version, s[v])
+	}
+	return buf.Bytes()
+}
+
+func isPkgDir(path string) bool {
+	return path == "cue.mod/"+PkgDir || strings.HasPrefix(path, "cue.mod/"+PkgDir+"/")
+}
+
+// PkgDirKey returns the key of the checksum entry for the directory dir
+// within the cue.mod/pkg directory of the module rooted at root.
+func PkgDirKey(root, dir string) (Version, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{Path: filepath.ToSlash(rel)}, nil
+}
+
+// HashPkgDirs computes the checksum entries of the directories within the
+// cue.mod/pkg directory of the module rooted at root.
+func HashPkgDirs(root string) (Sums, error) {
+	sums := Sums{}
+	pkg := filepath.Join(root, "cue.mod", PkgDir)
+	err := filepath.Walk(pkg, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == pkg {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		files := DirFiles(infos)
+		if len(files) == 0 {
+			return nil
+		}
+		h, err := Hash(files, func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(path, name))
+		})
+		if err != nil {
+			return err
+		}
+		key, err := PkgDirKey(root, path)
+		if err != nil {
+			return err
+		}
+		sums[key] = h
+		return nil
+	})
+	return sums, err
+}
+
+// DirFiles returns the names of the regular files among the entries of a
+// directory, which are the files covered by the hash of a directory within
+// cue.mod/pkg.
+func DirFiles(infos []os.FileInfo) []string {
+	var files []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			files = append(files, info.Name())
+		}
+	}
+	return files
+}
+
+// Hash computes the hash of a module consisting of the given files. The
+// files are identified by slash-separated paths relative to the module
+// root, and open is called to read their contents.
+//
+// The hash is the base64-encoded SHA-256 of a summary holding the
+// SHA-256 hash and name of each file, in sorted order, prefixed with "h1:".
+func Hash(files []string, open func(name string) (io.ReadCloser, error)) (string, error) {
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+	summary := sha256.New()
+	for _, name := range files {
+		if strings.Contains(name, "\n") {
+			return "", errors.Newf(token.NoPos, "filenames with newlines are not supported")
+		}
+		r, err := open(name)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// HashDir computes the hash of the module rooted at dir.
+func HashDir(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return Hash(files, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	})
+}
+
+// A ChecksumError reports that the contents of a module do not match the
+// hash recorded in the checksum file.
+type ChecksumError struct {
+	Module Version
+	Got    string
+	Want   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Module.Version == "" {
+		return fmt.Sprintf("checksum mismatch for %s:\n"+
+			"\tfound:   %s\n"+
+			"\t%s: %s\n"+
+			"the files were modified after %s was written; "+
+			"run 'cue mod tidy' to accept the changes",
+			e.Module.Path, e.Got, SumFile, e.Want, SumFile)
+	}
+	return fmt.Sprintf("checksum mismatch for module %s:\n"+
+		"\tdownloaded: %s\n"+
+		"\t%s:    %s\n"+
+		"the module contents were modified after download; "+
+		"remove the module from the module cache to fetch it again",
+		e.Module, e.Got, SumFile, e.Want)
+}
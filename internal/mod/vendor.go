@@ -0,0 +1,110 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mod
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+const (
+	// VendorDir is the name of the vendor directory within the cue.mod
+	// directory.
+	VendorDir = "vendor"
+
+	// VendorManifest is the name of the file within the vendor directory
+	// that lists the vendored modules and packages.
+	VendorManifest = "modules.txt"
+)
+
+// A VendoredModule describes a module of which packages are vendored.
+//
+// The vendor manifest holds, for each module, a header line of the form
+//
+//     # <module path> [<version>]
+//
+// followed by the import paths of the vendored packages of that module, one
+// per line. The version is absent for modules that are not required, such
+// as replaced modules and packages copied into cue.mod/pkg.
+type VendoredModule struct {
+	Version
+	Packages []string
+}
+
+// ParseVendorManifest parses the contents of a vendor manifest. The filename
+// is used for error messages only.
+func ParseVendorManifest(filename string, data []byte) ([]VendoredModule, error) {
+	var mods []VendoredModule
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		errorf := func() error {
+			return errors.Newf(token.NoPos,
+				"%s:%d: malformed vendor manifest line %q", filename, i+1, line)
+		}
+		switch {
+		case line == "":
+
+		case strings.HasPrefix(line, "#"):
+			f := strings.Fields(line[1:])
+			if len(f) == 0 || len(f) > 2 {
+				return nil, errorf()
+			}
+			m := VendoredModule{Version: Version{Path: f[0]}}
+			if len(f) == 2 {
+				if !IsValidVersion(f[1]) {
+					return nil, errorf()
+				}
+				m.Version.Version = f[1]
+			}
+			mods = append(mods, m)
+
+		case len(mods) == 0 || strings.ContainsAny(line, " \t"):
+			return nil, errorf()
+
+		default:
+			m := &mods[len(mods)-1]
+			m.Packages = append(m.Packages, line)
+		}
+	}
+	return mods, nil
+}
+
+// FormatVendorManifest returns the contents of a vendor manifest listing the
+// given modules. Modules and packages are written in sorted order.
+func FormatVendorManifest(mods []VendoredModule) []byte {
+	mods = append([]VendoredModule(nil), mods...)
+	sort.Slice(mods, func(i, j int) bool {
+		return mods[i].Path < mods[j].Path
+	})
+	var buf bytes.Buffer
+	for _, m := range mods {
+		fmt.Fprintf(&buf, "# %s", m.Path)
+		if m.Version.Version != "" {
+			fmt.Fprintf(&buf, " %s", m.Version.Version)
+		}
+		buf.WriteByte('\n')
+		pkgs := append([]string(nil), m.Packages...)
+		sort.Strings(pkgs)
+		for _, p := range pkgs {
+			fmt.Fprintln(&buf, p)
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watch detects changes to the files of build instances.
+//
+// Changes are detected by polling the modification times and sizes of the
+// files, which does not require any OS-specific support.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"cuelang.org/go/cue/build"
+)
+
+// Files returns the files that contributed to the given instances and the
+// instances they import, including data files and the module file, as well as
+// the directories of the instances, so that added and removed files are
+// detected.
+func Files(insts []*build.Instance) []string {
+	seen := map[string]bool{}
+	var files []string
+	add := func(filename string) {
+		if filename == "" || filename == "-" || seen[filename] {
+			return
+		}
+		seen[filename] = true
+		files = append(files, filename)
+	}
+
+	visited := map[*build.Instance]bool{}
+	var visit func(p *build.Instance)
+	visit = func(p *build.Instance) {
+		if visited[p] {
+			return
+		}
+		visited[p] = true
+		add(p.Dir)
+		for _, f := range p.BuildFiles {
+			add(f.Filename)
+		}
+		for _, f := range p.OrphanedFiles {
+			add(f.Filename)
+		}
+		if p.Root != "" {
+			module := filepath.Join(p.Root, "cue.mod", "module.cue")
+			if _, err := os.Stat(module); err == nil {
+				add(module)
+			}
+		}
+		for _, i := range p.Imports {
+			visit(i)
+		}
+	}
+	for _, p := range insts {
+		visit(p)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// A Config configures how Wait polls for changes.
+type Config struct {
+	// Interval is the time between polls. It defaults to 250ms.
+	Interval time.Duration
+
+	// Debounce is the time for which no further changes may be detected
+	// before Wait returns after a change. It defaults to 100ms.
+	Debounce time.Duration
+}
+
+// Wait blocks until any of the given files changes, is created or is
+// removed, and no further changes occur for the debounce time of cfg. It
+// returns the files that changed, or an error if ctx is done first.
+func Wait(ctx context.Context, files []string, cfg *Config) ([]string, error) {
+	c := Config{Interval: 250 * time.Millisecond, Debounce: 100 * time.Millisecond}
+	if cfg != nil {
+		if cfg.Interval > 0 {
+			c.Interval = cfg.Interval
+		}
+		if cfg.Debounce > 0 {
+			c.Debounce = cfg.Debounce
+		}
+	}
+
+	last := take(files)
+	changed := map[string]bool{}
+	wait := c.Interval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		s := take(files)
+		diff := last.diff(s)
+		last = s
+		for _, f := range diff {
+			changed[f] = true
+		}
+		switch {
+		case len(diff) > 0:
+			// Wait for changes to settle.
+			wait = c.Debounce
+		case len(changed) > 0:
+			a := make([]string, 0, len(changed))
+			for f := range changed {
+				a = append(a, f)
+			}
+			sort.Strings(a)
+			return a, nil
+		}
+	}
+}
+
+type stamp struct {
+	exists  bool
+	size    int64
+	modTime int64
+}
+
+type snapshot map[string]stamp
+
+func take(files []string) snapshot {
+	s := snapshot{}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			s[f] = stamp{exists: true, size: fi.Size(), modTime: fi.ModTime().UnixNano()}
+		} else {
+			s[f] = stamp{}
+		}
+	}
+	return s
+}
+
+// diff returns the files whose stamps differ between s and t.
+func (s snapshot) diff(t snapshot) []string {
+	var a []string
+	for f, x := range t {
+		if y := s[f]; x != y {
+			a = append(a, f)
+		}
+	}
+	sort.Strings(a)
+	return a
+}
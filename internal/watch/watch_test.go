@@ -0,0 +1,108 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue/load"
+)
+
+func writeFile(t *testing.T, filename, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "cue.mod", "module.cue"), `module: "example.com"`)
+	writeFile(t, filepath.Join(dir, "a.cue"), "package a\nimport \"example.com/b\"\nx: b.y\n")
+	writeFile(t, filepath.Join(dir, "b", "b.cue"), "package b\ny: 1\n")
+	writeFile(t, filepath.Join(dir, "data.json"), `{"z": 1}`)
+
+	insts := load.Instances([]string{".", "data.json"}, &load.Config{Dir: dir})
+	for _, p := range insts {
+		if p.Err != nil {
+			t.Fatal(p.Err)
+		}
+	}
+
+	var got []string
+	for _, f := range Files(insts) {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			rel = f
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{".", "a.cue", "b", "b/b.cue", "cue.mod/module.cue", "data.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestWait(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.cue")
+	b := filepath.Join(dir, "b.cue")
+	writeFile(t, a, "a: 1\n")
+	files := []string{a, b}
+	cfg := &Config{Interval: 10 * time.Millisecond, Debounce: 50 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Wait(ctx, files, cfg); err != context.DeadlineExceeded {
+		t.Fatalf("Wait without changes: got error %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		// Change the size, as the modification time may not change on file
+		// systems with a coarse resolution.
+		_ = ioutil.WriteFile(a, []byte("a: 10\n"), 0644)
+		time.Sleep(20 * time.Millisecond)
+		_ = ioutil.WriteFile(b, []byte("b: 2\n"), 0644)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := Wait(ctx, files, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
@@ -40,14 +40,40 @@ Do: {
 	}
 }
 
-//  TODO: support serving once we have the cue serve command.
-// Serve: {
-//  port: int
-//
-//  cert: string
-//  key:  string
-//
-//  handle: [Pattern=string]: Message & {
-//   pattern: Pattern
-//  }
-// }
+// Serve listens on a port and answers HTTP requests until the command is
+// interrupted.
+Serve: {
+	$id: "tool/http.Serve"
+
+	port: int
+
+	// cert and key are the names of files containing a certificate and
+	// its private key. If they are set, the server uses HTTPS.
+	cert?: string
+	key?:  string
+
+	// handle maps URL patterns, as interpreted by Go's http.ServeMux, to
+	// handlers. For each request, the request field of the handler that
+	// matches best is filled in, after which the response is computed and
+	// sent.
+	handle: [Pattern=string]: {
+		pattern: Pattern
+
+		request: {
+			method: string
+			url:    string
+			path:   string
+			query: [string]: [...string]
+
+			body: string
+			header: [string]: [...string]
+		}
+		response: {
+			statusCode: *200 | int
+
+			body?: bytes | string
+			header: [string]:  string | [...string]
+			trailer: [string]: string | [...string]
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 func init() {
 	task.Register("tool/http.Do", newHTTPCmd)
+	task.Register("tool/http.Serve", newServeCmd)
 
 	// For backwards compatibility.
 	task.Register("http", newHTTPCmd)
@@ -101,7 +102,22 @@ func parseHeaders(obj cue.Value, label string) (http.Header, error) {
 	}
 	h := http.Header{}
 	for iter.Next() {
-		str, err := iter.Value().String()
+		v := iter.Value()
+		if v.Kind() == cue.ListKind {
+			list, err := v.List()
+			if err != nil {
+				return nil, err
+			}
+			for list.Next() {
+				str, err := list.Value().String()
+				if err != nil {
+					return nil, err
+				}
+				h.Add(iter.Label(), str)
+			}
+			continue
+		}
+		str, err := v.String()
 		if err != nil {
 			return nil, err
 		}
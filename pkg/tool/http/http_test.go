@@ -16,6 +16,7 @@ package http
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -93,3 +94,89 @@ func TestParseHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestServe(t *testing.T) {
+	r := cue.Runtime{}
+	inst, err := r.Compile("serve", `
+	import "tool/http"
+
+	serve: http.Serve & {
+		port: 0
+		handle: {
+			"/greet": {
+				request: query: name: [string]
+				response: {
+					header: "Content-Type": "text/plain"
+					body: "hello \(request.query.name[0])"
+				}
+			}
+			"/echo": {
+				request: _
+				response: {
+					statusCode: 201
+					body:       "\(request.method) \(request.path): \(request.body)"
+				}
+			}
+			"/incomplete": {
+				request: _
+				response: body: request.query.missing[0]
+			}
+		}
+	}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHandler(inst.Value().Lookup("serve", "handle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		method string
+		url    string
+		body   string
+		status int
+		header string
+		out    string
+	}{{
+		method: "GET",
+		url:    "/greet?name=World",
+		status: 200,
+		header: "text/plain",
+		out:    "hello World",
+	}, {
+		method: "POST",
+		url:    "/echo",
+		body:   "data",
+		status: 201,
+		out:    "POST /echo: data",
+	}, {
+		method: "GET",
+		url:    "/incomplete",
+		status: 500,
+		out:    "incomplete",
+	}, {
+		method: "GET",
+		url:    "/unknown",
+		status: 404,
+		out:    "404 page not found",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("got status %d; want %d", w.Code, tc.status)
+			}
+			if got := w.Header().Get("Content-Type"); tc.header != "" && got != tc.header {
+				t.Errorf("got Content-Type %q; want %q", got, tc.header)
+			}
+			if got := w.Body.String(); !strings.Contains(got, tc.out) {
+				t.Errorf("got body %q; want %q", got, tc.out)
+			}
+		})
+	}
+}
@@ -56,5 +56,38 @@ var pkg = &internal.Package{
 			}
 		}
 	}
+	Serve: {
+		$id:   "tool/http.Serve"
+		port:  int
+		cert?: string
+		key?:  string
+		handle: {
+			[Pattern=string]: {
+				pattern: Pattern
+				request: {
+					method: string
+					url:    string
+					path:   string
+					query: {
+						[string]: [...string]
+					}
+					body: string
+					header: {
+						[string]: [...string]
+					}
+				}
+				response: {
+					statusCode: *200 | int
+					body?:      bytes | string
+					header: {
+						[string]: string | [...string]
+					}
+					trailer: {
+						[string]: string | [...string]
+					}
+				}
+			}
+		}
+	}
 }`,
 }
@@ -0,0 +1,162 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/internal/task"
+)
+
+type serveCmd struct{}
+
+func newServeCmd(v cue.Value) (task.Runner, error) {
+	return &serveCmd{}, nil
+}
+
+func (c *serveCmd) Run(ctx *task.Context) (res interface{}, err error) {
+	port := ctx.Int64("port")
+	var cert, key string
+	if ctx.Obj.Lookup("cert").Exists() || ctx.Obj.Lookup("key").Exists() {
+		cert = ctx.String("cert")
+		key = ctx.String("key")
+	}
+	if ctx.Err != nil {
+		return nil, ctx.Err
+	}
+
+	h, err := newHandler(ctx.Obj.Lookup("handle"))
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: h,
+	}
+	if ctx.Context != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Context.Done():
+				srv.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	if cert != "" {
+		err = srv.ListenAndServeTLS(cert, key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	return nil, err
+}
+
+// newHandler returns a handler that dispatches requests to the handlers
+// defined by the fields of handle, keyed by URL pattern.
+func newHandler(handle cue.Value) (http.Handler, error) {
+	mux := http.NewServeMux()
+	if !handle.Exists() {
+		return mux, nil
+	}
+	iter, err := handle.Fields()
+	if err != nil {
+		return nil, err
+	}
+	// Evaluation is not safe for concurrent use.
+	mu := &sync.Mutex{}
+	for iter.Next() {
+		mux.Handle(iter.Label(), &handler{mu: mu, v: iter.Value()})
+	}
+	return mux, nil
+}
+
+type handler struct {
+	mu *sync.Mutex
+	v  cue.Value
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := map[string]interface{}{
+		"method": r.Method,
+		"url":    r.URL.String(),
+		"path":   r.URL.Path,
+		"query":  map[string][]string(r.URL.Query()),
+		"body":   string(body),
+		"header": map[string][]string(r.Header),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	resp := h.v.Fill(req, "request").Lookup("response")
+	status, header, trailer, content, err := parseResponse(resp)
+	if err != nil {
+		http.Error(w, errors.Details(err, nil), http.StatusInternalServerError)
+		return
+	}
+
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	for k := range trailer {
+		w.Header().Add("Trailer", k)
+	}
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, content)
+	for k, v := range trailer {
+		w.Header()[k] = v
+	}
+}
+
+func parseResponse(v cue.Value) (status int, header, trailer http.Header, body io.Reader, err error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return 0, nil, nil, nil, err
+	}
+	code, err := v.Lookup("statusCode").Int64()
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	if header, err = parseHeaders(v, "header"); err != nil {
+		return 0, nil, nil, nil, err
+	}
+	if trailer, err = parseHeaders(v, "trailer"); err != nil {
+		return 0, nil, nil, nil, err
+	}
+	body = strings.NewReader("")
+	if b := v.Lookup("body"); b.Exists() {
+		if body, err = b.Reader(); err != nil {
+			return 0, nil, nil, nil, err
+		}
+	}
+	return int(code), header, trailer, body, nil
+}
@@ -31,7 +31,6 @@ package flow
 // - AddDependency(a, b *Task)
 // - AddTaskGraph(root cue.Value, fn taskFunc)
 // - AddSequence(list cue.Value, fn taskFunc)
-// - Err()
 
 // TODO:
 // Should we allow lists as a shorthand for a sequence of tasks?
@@ -181,6 +180,18 @@ func (c *Controller) Tasks() []*Task {
 	return c.tasks
 }
 
+// Err reports the errors encountered while initializing or running the tasks
+// of the Controller.
+//
+// This may currently only be called before Run is called, after Run
+// completed, or from within a call to UpdateFunc.
+func (c *Controller) Err() error {
+	if c.errs == nil {
+		return nil
+	}
+	return c.errs
+}
+
 func (c *Controller) cancel() {
 	if c.cancelFunc != nil {
 		c.cancelFunc()
@@ -90,6 +90,43 @@ func TestFlow(t *testing.T) {
 	})
 }
 
+func TestControllerErr(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{{
+		in: `
+		root: {
+			a: {$id: "a", out: string}
+			b: {$id: "b", in: a.out}
+		}
+		`,
+	}, {
+		in: `
+		root: {
+			a: {$id: "a", in: b.out, out: string}
+			b: {$id: "b", in: a.out, out: string}
+		}
+		`,
+		want: "cyclic task",
+	}}
+	for _, tc := range testCases {
+		var r cue.Runtime
+		inst, err := r.Compile("in.cue", tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := flow.New(&flow.Config{Root: cue.ParsePath("root")}, inst, taskFunc)
+		err = c.Err()
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("unexpected error: %v", err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("got error %v; want %q", err, tc.want)
+		}
+	}
+}
+
 func taskFunc(v cue.Value) (flow.Runner, error) {
 	switch name, err := v.Lookup("$id").String(); name {
 	default:
@@ -0,0 +1,477 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refactor implements semantic transformations of CUE packages.
+package refactor
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/eval"
+	"cuelang.org/go/internal/core/runtime"
+)
+
+// Rename renames the field or definition at path p in the package of target
+// to name and updates all references to it in target and the instances in a.
+// If p is empty, it renames the package itself, updating the package clauses
+// of target and the imports of target in a.
+//
+// Besides the declaration of the field at p, Rename renames the fields that
+// are unified with it, such as the fields of values that embed or unify with
+// a definition, and all identifiers and selectors referring to these fields.
+// All instances must evaluate without errors.
+//
+// The files are modified in place. Rename returns the files that were
+// modified.
+func Rename(target *build.Instance, p cue.Path, name string, a []*build.Instance) ([]*ast.File, errors.Error) {
+	if err := p.Err(); err != nil {
+		return nil, errors.Promote(err, "invalid path")
+	}
+	r := &renamer{
+		name:     name,
+		labels:   map[position]bool{},
+		paths:    map[string]map[string]bool{},
+		modified: map[*ast.File]bool{},
+	}
+	if err := r.add(target); err != nil {
+		return nil, err
+	}
+	for _, inst := range a {
+		if inst.Dir == target.Dir {
+			continue
+		}
+		if err := r.add(inst); err != nil {
+			return nil, err
+		}
+	}
+
+	var err errors.Error
+	if len(p.Selectors()) == 0 {
+		err = r.renamePackage()
+	} else {
+		err = r.renameField(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+	for _, pkg := range r.pkgs {
+		for _, f := range pkg.files {
+			if r.modified[f] {
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+type renamer struct {
+	pkgs []*pkg
+
+	// old and name are the old and new name of the renamed field or package.
+	old  string
+	name string
+
+	// labels holds the positions of the fields to rename.
+	labels map[position]bool
+
+	// paths holds, for each package import path, the paths of the renamed
+	// fields in that package.
+	paths map[string]map[string]bool
+
+	modified map[*ast.File]bool
+}
+
+type pkg struct {
+	inst  *build.Instance
+	files []*ast.File
+	value cue.Value
+
+	// top holds the names of the fields declared at the top level of any of
+	// the files of the package.
+	top map[string]bool
+}
+
+// A position identifies a node independently of the instance in which its
+// file was loaded.
+type position struct {
+	filename string
+	offset   int
+}
+
+func positionOf(pos token.Pos) position {
+	return position{pos.Filename(), pos.Offset()}
+}
+
+func (r *renamer) add(inst *build.Instance) errors.Error {
+	if inst.Err != nil {
+		return inst.Err
+	}
+	v := cue.Build([]*build.Instance{inst})[0].Value()
+	if err := v.Err(); err != nil {
+		return errors.Promote(err, "build")
+	}
+	p := &pkg{inst: inst, value: v, top: map[string]bool{}}
+	for _, f := range inst.Files {
+		if !strings.HasSuffix(f.Filename, ".cue") {
+			continue
+		}
+		p.files = append(p.files, f)
+		for _, d := range f.Decls {
+			if f, ok := d.(*ast.Field); ok {
+				if name, _, err := ast.LabelName(f.Label); err == nil {
+					p.top[name] = true
+				}
+			}
+		}
+	}
+	r.pkgs = append(r.pkgs, p)
+	return nil
+}
+
+func (r *renamer) renamePackage() errors.Error {
+	if !ast.IsValidIdent(r.name) || internal.IsDefOrHidden(r.name) {
+		return errors.Newf(token.NoPos, "invalid package name %q", r.name)
+	}
+	target := r.pkgs[0]
+	for _, f := range target.files {
+		internal.SetPackage(f, r.name, true)
+		r.modified[f] = true
+	}
+
+	importPath := target.inst.ImportPath
+	if importPath == "" {
+		return nil
+	}
+	if i := strings.LastIndexByte(importPath, ':'); i >= 0 {
+		importPath = importPath[:i]
+	}
+	newPath := importPath
+	if path.Base(importPath) != r.name {
+		newPath += ":" + r.name
+	}
+	for _, p := range r.pkgs[1:] {
+		for _, f := range p.files {
+			for _, spec := range f.Imports {
+				s, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if i := strings.LastIndexByte(s, ':'); i >= 0 {
+					s = s[:i]
+				}
+				if s != importPath {
+					continue
+				}
+				spec.Path.Value = strconv.Quote(newPath)
+				r.modified[f] = true
+				if spec.Name == nil {
+					r.renameIdents(f, func(x *ast.Ident) bool { return x.Node == spec })
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *renamer) renameField(p cue.Path) errors.Error {
+	target := r.pkgs[0]
+	sels := p.Selectors()
+	old, err := labelName(sels[len(sels)-1])
+	if err != nil {
+		return err
+	}
+	r.old = old
+	if !ast.IsValidIdent(r.name) {
+		return errors.Newf(token.NoPos, "invalid name %q", r.name)
+	}
+	if internal.IsDef(old) != internal.IsDef(r.name) ||
+		internal.IsHidden(old) != internal.IsHidden(r.name) {
+		return errors.Newf(token.NoPos,
+			"cannot rename %s to %s: the kind of field would change", old, r.name)
+	}
+
+	v := target.value.LookupPath(p)
+	if !v.Exists() {
+		return errors.Newf(token.NoPos, "field %s not found", p)
+	}
+	parent := cue.MakePath(sels[:len(sels)-1]...)
+	if q := cue.ParsePath(r.name); q.Err() == nil {
+		if target.value.LookupPath(parent).LookupPath(q).Exists() {
+			return errors.Newf(token.NoPos, "field %s already exists",
+				cue.MakePath(append(sels[:len(sels)-1:len(sels)-1], q.Selectors()...)...))
+		}
+	}
+
+	// Collect the declarations of the field and, from these, all fields
+	// that unify with them.
+	_, x := internal.CoreValue(v)
+	decls := map[position]bool{}
+	for _, c := range x.(*adt.Vertex).Conjuncts {
+		if f, ok := c.Source().(*ast.Field); ok {
+			decls[positionOf(f.Pos())] = true
+		}
+	}
+	for _, p := range r.pkgs {
+		r.collectFields(p, decls)
+	}
+
+	for _, p := range r.pkgs {
+		r.renameReferences(p)
+	}
+	return nil
+}
+
+// collectFields records the fields of p that unify with the declarations in
+// decls, along with their paths.
+func (r *renamer) collectFields(p *pkg, decls map[position]bool) {
+	rt, x := internal.CoreValue(p.value)
+	root := x.(*adt.Vertex)
+	ctx := eval.NewContext(rt.(*runtime.Runtime), root)
+
+	paths := r.paths[p.inst.ImportPath]
+	if paths == nil {
+		paths = map[string]bool{}
+		r.paths[p.inst.ImportPath] = paths
+	}
+
+	var visit func(v *adt.Vertex)
+	visit = func(v *adt.Vertex) {
+		for _, arc := range v.Arcs {
+			match := false
+			for _, c := range arc.Conjuncts {
+				if f, ok := c.Source().(*ast.Field); ok && decls[positionOf(f.Pos())] {
+					match = true
+					break
+				}
+			}
+			if match {
+				for _, c := range arc.Conjuncts {
+					if f, ok := c.Source().(*ast.Field); ok {
+						r.labels[positionOf(f.Pos())] = true
+					}
+				}
+				paths[cue.MakeValue(ctx, arc).Path().String()] = true
+			}
+			visit(arc)
+		}
+	}
+	visit(root)
+}
+
+// renameReferences renames the fields to rename in the files of p as well as
+// the identifiers and selectors referring to them.
+func (r *renamer) renameReferences(p *pkg) {
+	// The fields of the package, by value, and the paths of the fields
+	// declared in struct literals. References to fields in other files of
+	// the package are resolved to values in those files when building.
+	fields := map[ast.Node]*ast.Field{}
+	valuePaths := map[ast.Node][]string{}
+	var visitDecls func(decls []ast.Decl, path []string)
+	visitDecls = func(decls []ast.Decl, path []string) {
+		for _, d := range decls {
+			field, ok := d.(*ast.Field)
+			if !ok {
+				continue
+			}
+			name, _, err := ast.LabelName(field.Label)
+			if err != nil {
+				continue
+			}
+			fieldPath := append(path[:len(path):len(path)], selector(name))
+			valuePaths[field.Value] = fieldPath
+			for _, s := range structs(field.Value) {
+				visitDecls(s.Elts, fieldPath)
+			}
+		}
+	}
+
+	// Identifiers that are not references.
+	decls := map[*ast.Ident]bool{}
+
+	for _, f := range p.files {
+		visitDecls(f.Decls, nil)
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Field:
+				fields[x.Value] = x
+				label := x.Label
+				if a, ok := label.(*ast.Alias); ok {
+					decls[a.Ident] = true
+					label, _ = a.Expr.(ast.Label)
+				}
+				if id, ok := label.(*ast.Ident); ok {
+					decls[id] = true
+				}
+			case *ast.SelectorExpr:
+				if id, ok := x.Sel.(*ast.Ident); ok {
+					decls[id] = true
+				}
+			case *ast.Alias:
+				decls[x.Ident] = true
+			case *ast.LetClause:
+				decls[x.Ident] = true
+			case *ast.ImportSpec:
+				if x.Name != nil {
+					decls[x.Name] = true
+				}
+			case *ast.ForClause:
+				if x.Key != nil {
+					decls[x.Key] = true
+				}
+				decls[x.Value] = true
+			case *ast.Package:
+				decls[x.Name] = true
+			}
+			return true
+		}, nil)
+	}
+
+	// base returns the import path of the package and the path within it of
+	// the value that x refers to, if known.
+	var base func(x ast.Expr) (string, []string, bool)
+	base = func(x ast.Expr) (string, []string, bool) {
+		switch x := x.(type) {
+		case *ast.Ident:
+			switch n := x.Node.(type) {
+			case nil:
+				if !decls[x] && p.top[x.Name] {
+					return p.inst.ImportPath, []string{selector(x.Name)}, true
+				}
+			case *ast.ImportSpec:
+				s, err := strconv.Unquote(n.Path.Value)
+				return s, nil, err == nil
+			default:
+				if path, ok := valuePaths[n]; ok {
+					return p.inst.ImportPath, path, true
+				}
+			}
+		case *ast.SelectorExpr:
+			pkg, path, ok := base(x.X)
+			name, _, err := ast.LabelName(x.Sel)
+			if ok && err == nil {
+				return pkg, append(path[:len(path):len(path)], selector(name)), true
+			}
+		case *ast.ParenExpr:
+			return base(x.X)
+		}
+		return "", nil, false
+	}
+
+	for _, f := range p.files {
+		var idents []*ast.Ident
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Field:
+				if r.labels[positionOf(x.Pos())] {
+					r.renameLabel(f, x)
+				}
+			case *ast.SelectorExpr:
+				id, ok := x.Sel.(*ast.Ident)
+				if !ok || id.Name != r.old {
+					break
+				}
+				if pkg, path, ok := base(x); ok && r.paths[pkg][strings.Join(path, ".")] {
+					idents = append(idents, id)
+				}
+			case *ast.Ident:
+				if decls[x] || x.Name != r.old {
+					break
+				}
+				if field, ok := fields[x.Node]; ok && r.labels[positionOf(field.Pos())] {
+					idents = append(idents, x)
+				} else if x.Node == nil && r.paths[p.inst.ImportPath][selector(x.Name)] {
+					idents = append(idents, x)
+				}
+			}
+			return true
+		}, nil)
+		for _, x := range idents {
+			x.Name = r.name
+			r.modified[f] = true
+		}
+	}
+}
+
+func (r *renamer) renameLabel(f *ast.File, x *ast.Field) {
+	label := x.Label
+	if a, ok := label.(*ast.Alias); ok {
+		label, _ = a.Expr.(ast.Label)
+	}
+	switch l := label.(type) {
+	case *ast.Ident:
+		l.Name = r.name
+	case *ast.BasicLit:
+		l.Value = strconv.Quote(r.name)
+	default:
+		return
+	}
+	r.modified[f] = true
+}
+
+func (r *renamer) renameIdents(f *ast.File, match func(x *ast.Ident) bool) {
+	ast.Walk(f, func(n ast.Node) bool {
+		if x, ok := n.(*ast.Ident); ok && match(x) {
+			x.Name = r.name
+		}
+		return true
+	}, nil)
+}
+
+// labelName returns the name of the field selected by sel.
+func labelName(sel cue.Selector) (string, errors.Error) {
+	s := sel.String()
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u, nil
+		}
+	}
+	if !ast.IsValidIdent(s) {
+		return "", errors.Newf(token.NoPos, "cannot rename %s: not a field", s)
+	}
+	return s, nil
+}
+
+// selector returns the representation of the field name in a cue.Path.
+func selector(name string) string {
+	if ast.IsValidIdent(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// structs returns the struct literals that are unified to form x.
+func structs(x ast.Expr) []*ast.StructLit {
+	switch x := x.(type) {
+	case *ast.StructLit:
+		return []*ast.StructLit{x}
+	case *ast.ParenExpr:
+		return structs(x.X)
+	case *ast.BinaryExpr:
+		if x.Op == token.AND {
+			return append(structs(x.X), structs(x.Y)...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refactor
+
+import (
+	"fmt"
+
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/internal/cuetest"
+	"cuelang.org/go/internal/cuetxtar"
+	_ "cuelang.org/go/pkg"
+)
+
+func TestRename(t *testing.T) {
+	test := cuetxtar.TxTarTest{
+		Root:   "./testdata",
+		Name:   "rename",
+		Update: cuetest.UpdateGoldenFiles,
+	}
+
+	test.Run(t, func(t *cuetxtar.Test) {
+		target, _ := t.Value("target")
+		path, _ := t.Value("path")
+		name, _ := t.Value("name")
+
+		a := t.ValidInstances("./...")
+		var inst = t.ValidInstances(target)[0]
+
+		p := cue.MakePath()
+		if path != "" {
+			p = cue.ParsePath(path)
+		}
+		files, err := Rename(inst, p, name, a)
+		t.WriteErrors(err)
+		for _, f := range files {
+			b, err := format.Node(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintln(t, "---", t.Rel(f.Filename))
+			fmt.Fprint(t, string(b))
+		}
+	})
+}